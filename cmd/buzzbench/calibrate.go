@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/internal/config"
+	"github.com/lazarkap/buzzbench.io/internal/mockserver"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+	"github.com/lazarkap/buzzbench.io/pkg/runner"
+)
+
+// Calibration runs: an echo run as fast as the generator goes, then a paced
+// run against a fixed server delay, whose excess latency is the generator's
+const (
+	calibrationRequests    = 20000
+	calibrationConcurrency = 50
+	calibrationDelayMs     = 10
+	calibrationDelayReqs   = 1000
+	calibrationTick        = time.Millisecond
+)
+
+// Thresholds above which calibrate warns that the machine distorts results
+const (
+	maxCalibrationOverheadMs = 5.0
+	maxCalibrationJitterMs   = 5.0
+)
+
+// runCalibrate measures the load generator on this machine against an
+// in-process target: its maximum local throughput, the latency it adds on top
+// of the server's, and how late the Go scheduler wakes timers under load.
+func runCalibrate(ctx context.Context, cfg *config.Config, testRunner *runner.Runner) error {
+	mock, err := mockserver.New([]mockserver.Endpoint{
+		{Path: "/echo", Echo: true},
+		{Path: "/delay", LatencyMs: calibrationDelayMs},
+	})
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: mock}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	base := "http://" + ln.Addr().String()
+	fmt.Printf("Calibrating against an in-process target on %s\n\n", base)
+
+	jitter := startJitterProbe()
+	throughput, err := testRunner.RunTest(ctx, api.TestConfiguration{
+		Name:            "Calibration: throughput",
+		URL:             base + "/echo",
+		Method:          "POST",
+		Body:            strings.Repeat("x", cfg.MockSize),
+		Requests:        calibrationRequests,
+		Concurrency:     calibrationConcurrency,
+		TimeoutSecs:     10,
+		RequestIDHeader: cfg.RequestIDHeader,
+	})
+	lateness := jitter.stop()
+	if err != nil {
+		return fmt.Errorf("throughput run: %w", err)
+	}
+
+	delayed, err := testRunner.RunTest(ctx, api.TestConfiguration{
+		Name:            "Calibration: overhead",
+		URL:             base + "/delay",
+		Method:          "GET",
+		Requests:        calibrationDelayReqs,
+		Concurrency:     10,
+		TimeoutSecs:     10,
+		RequestIDHeader: cfg.RequestIDHeader,
+	})
+	if err != nil {
+		return fmt.Errorf("overhead run: %w", err)
+	}
+
+	overheadP50 := delayed.P50ResponseTime - calibrationDelayMs
+	overheadP99 := delayed.P99ResponseTime - calibrationDelayMs
+	jitterP50, jitterP99, jitterMax := percentileMs(lateness, 50), percentileMs(lateness, 99), percentileMs(lateness, 100)
+
+	fmt.Println("\n=== CALIBRATION ===")
+	fmt.Printf("Max local throughput: %.0f req/s  (%d workers, p50 %.2f ms, p99 %.2f ms)\n",
+		throughput.RequestsPerSecond, calibrationConcurrency, throughput.P50ResponseTime, throughput.P99ResponseTime)
+	fmt.Printf("Generator overhead:   p50 %.2f ms, p99 %.2f ms  (over a %d ms server delay)\n",
+		overheadP50, overheadP99, calibrationDelayMs)
+	fmt.Printf("Scheduler jitter:     p50 %.2f ms, p99 %.2f ms, max %.2f ms  (%s timer under load)\n",
+		jitterP50, jitterP99, jitterMax, calibrationTick)
+	if g := throughput.Generator; g != nil {
+		fmt.Printf("Generator CPU:        %.0f%% average, %.0f%% peak\n", g.CPUPercent, g.PeakCPUPercent)
+	}
+
+	if throughput.SuccessRate < 100 {
+		fmt.Printf("\nWarning: %.2f%% of local requests failed; check open-file and port limits with buzzbench doctor\n",
+			100-throughput.SuccessRate)
+	}
+	if overheadP99 > maxCalibrationOverheadMs || jitterP99 > maxCalibrationJitterMs {
+		fmt.Printf("\nWarning: this machine adds up to %.1f ms to measured latencies; differences smaller than that\n"+
+			"are noise, and targets faster than that can't be measured reliably from here\n", max(overheadP99, jitterP99))
+	} else {
+		fmt.Println("\nThe generator adds little latency on this machine; tests are limited by the target, up to the throughput above.")
+	}
+	return nil
+}
+
+// jitterProbe records how late a goroutine sleeping calibrationTick at a
+// time wakes up, a measure of scheduler delay for everything else in the process
+type jitterProbe struct {
+	done     chan struct{}
+	wg       sync.WaitGroup
+	lateness []time.Duration
+}
+
+func startJitterProbe() *jitterProbe {
+	p := &jitterProbe{done: make(chan struct{})}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for {
+			select {
+			case <-p.done:
+				return
+			default:
+			}
+			start := time.Now()
+			time.Sleep(calibrationTick)
+			p.lateness = append(p.lateness, time.Since(start)-calibrationTick)
+		}
+	}()
+	return p
+}
+
+// stop ends the probe and returns the recorded wake-up delays.
+func (p *jitterProbe) stop() []time.Duration {
+	close(p.done)
+	p.wg.Wait()
+	return p.lateness
+}
+
+// percentileMs returns the pth percentile of ds in milliseconds, 0 when empty.
+func percentileMs(ds []time.Duration, p float64) float64 {
+	if len(ds) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(p / 100 * float64(len(sorted)-1))
+	return float64(sorted[i]) / float64(time.Millisecond)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/lazarkap/buzzbench.io/internal/config"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+	"github.com/lazarkap/buzzbench.io/pkg/results"
+)
+
+// runCompare compares a baseline and a candidate results file test by test.
+// Results are matched by test configuration ID, falling back to their position.
+// It reports whether any test regressed beyond -max-regression with a
+// statistically significant latency difference, or without samples to test.
+func runCompare(cfg *config.Config) (bool, error) {
+	baseline, err := results.LoadFile(cfg.Args[0])
+	if err != nil {
+		return false, err
+	}
+	candidate, err := results.LoadFile(cfg.Args[1])
+	if err != nil {
+		return false, err
+	}
+
+	regressed := false
+	for i, cand := range candidate {
+		base, ok := matchResult(baseline, cand, i)
+		if !ok {
+			fmt.Printf("\n%s: no baseline result to compare against\n", cand.URL)
+			continue
+		}
+
+		if compareResults(cfg, fmt.Sprintf("COMPARE: %s %s", cand.Method, cand.URL), base, cand) {
+			regressed = true
+		}
+	}
+
+	return regressed, nil
+}
+
+// compareResults prints the metric deltas from base to cand and tests the
+// latency difference for significance. It reports whether p95 regressed
+// beyond -max-regression with a significant difference, or at all when
+// either side lacks the raw samples to test.
+func compareResults(cfg *config.Config, heading string, base, cand api.TestResult) bool {
+	analyzer := results.NewAnalyzer(cand)
+	if cfg.MaxRegression > 0 {
+		analyzer.RegressionPct = cfg.MaxRegression
+	}
+	comparison := analyzer.CompareWith(base)
+	fmt.Printf("\n=== %s ===\n", heading)
+	fmt.Print(comparison)
+
+	// Without samples there's nothing to test, so the percent change alone
+	// decides
+	significant := true
+	if mw := comparison.Significance; mw != nil {
+		significant = mw.PValue < cfg.Alpha
+		verdict := "not significant (likely noise)"
+		if significant {
+			verdict = "significant"
+		}
+		fmt.Printf("  Mann-Whitney U: U=%.0f z=%.2f p=%.4f -> %s at alpha=%.2f\n", mw.U, mw.Z, mw.PValue, verdict, cfg.Alpha)
+	} else if cfg.MaxRegression > 0 {
+		fmt.Println("  Warning: no raw samples to test significance; gating on the p95 change alone (rerun both sides with -samples N)")
+	} else {
+		fmt.Println("  Significance test skipped: rerun both sides with -samples N to keep raw samples")
+	}
+
+	if p95, _ := comparison.Delta("p95 response"); cfg.MaxRegression > 0 && significant && p95.Regressed {
+		fmt.Printf("  REGRESSION: p95 increased by more than %.1f%%\n", cfg.MaxRegression)
+		return true
+	}
+	return false
+}
+
+// matchResult finds the baseline result for a candidate by ID, or by position
+func matchResult(baseline []api.TestResult, cand api.TestResult, index int) (api.TestResult, bool) {
+	if cand.TestConfigurationID != "" {
+		for _, b := range baseline {
+			if b.TestConfigurationID == cand.TestConfigurationID && b.URL == cand.URL {
+				return b, true
+			}
+		}
+	}
+	if index < len(baseline) {
+		return baseline[index], true
+	}
+	return api.TestResult{}, false
+}
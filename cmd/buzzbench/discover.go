@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/internal/config"
+	"github.com/lazarkap/buzzbench.io/internal/discover"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// runDiscover prints a local config with one multi-endpoint test covering
+// the pages of a site. A URL ending in .xml is read as a sitemap; otherwise
+// the site's /sitemap.xml is tried before falling back to a shallow crawl.
+func runDiscover(ctx context.Context, cfg *config.Config) error {
+	target, err := url.Parse(cfg.Args[0])
+	if err != nil || target.Host == "" {
+		return fmt.Errorf("invalid url %q", cfg.Args[0])
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var pages []discover.Page
+	if strings.HasSuffix(target.Path, ".xml") {
+		pages, err = discover.Sitemap(ctx, client, target.String(), cfg.MaxURLs)
+	} else {
+		sitemapURL := target.ResolveReference(&url.URL{Path: "/sitemap.xml"}).String()
+		pages, err = discover.Sitemap(ctx, client, sitemapURL, cfg.MaxURLs)
+		if err != nil || len(pages) == 0 {
+			fmt.Fprintf(os.Stderr, "No sitemap at %s, crawling %s\n", sitemapURL, target)
+			pages, err = discover.Crawl(ctx, client, target.String(), cfg.CrawlDepth, cfg.MaxURLs)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(pages) == 0 {
+		return fmt.Errorf("no pages found at %s", target)
+	}
+	fmt.Fprintf(os.Stderr, "Discovered %d pages\n", len(pages))
+
+	site := target.Scheme + "://" + target.Host
+	test := localTest{
+		Name:        "Site: " + target.Host,
+		URL:         site + "/",
+		Method:      "GET",
+		Requests:    len(pages) * 10,
+		Concurrency: 10,
+		TimeoutSecs: 30,
+	}
+	for _, p := range pages {
+		// Same-site pages become paths so the test can be pointed elsewhere
+		u := strings.TrimPrefix(p.URL, site)
+		if u == "" {
+			u = "/"
+		}
+		test.Endpoints = append(test.Endpoints, api.Endpoint{URL: u, Weight: p.Weight})
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode([]localTest{test})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/internal/config"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+	"github.com/lazarkap/buzzbench.io/pkg/runner"
+)
+
+// maxClockSkew is the clock difference above which doctor warns; result
+// timestamps and token expiry checks drift beyond it
+const maxClockSkew = 2 * time.Second
+
+// doctor collects the outcome of each environment check
+type doctor struct {
+	warnings, failures int
+}
+
+func (d *doctor) ok(format string, v ...interface{}) {
+	fmt.Printf("[ OK ] "+format+"\n", v...)
+}
+
+func (d *doctor) warn(format string, v ...interface{}) {
+	d.warnings++
+	fmt.Printf("[WARN] "+format+"\n", v...)
+}
+
+func (d *doctor) fail(format string, v ...interface{}) {
+	d.failures++
+	fmt.Printf("[FAIL] "+format+"\n", v...)
+}
+
+// runDoctor checks the environment for problems that would otherwise make a
+// large run fail part-way through. It returns an error when any check fails.
+func runDoctor(ctx context.Context, cfg *config.Config, client *api.Client) error {
+	d := &doctor{}
+
+	// API connectivity and authentication
+	if cfg.UsesAPI() {
+		if cfg.APIKey == "" {
+			d.fail("No API key: run buzzbench login, set BUZZBENCH_API_KEY or use -api-key")
+		} else if identity, err := client.WhoAmI(ctx); err != nil {
+			d.fail("API %s: %v", cfg.BaseURL, err)
+		} else {
+			d.ok("API %s authenticated as %s (project %s)", cfg.BaseURL, identity.Email, identity.Project)
+		}
+	}
+
+	// Clock skew against the API server
+	if serverTime, err := client.ServerTime(ctx); err != nil {
+		d.warn("Could not read the clock of %s: %v", cfg.BaseURL, err)
+	} else if skew := time.Since(serverTime).Round(time.Second); skew > maxClockSkew || skew < -maxClockSkew {
+		d.warn("System clock is off by %s; enable NTP so result timestamps line up", skew)
+	} else {
+		d.ok("System clock within %s of the API server", maxClockSkew)
+	}
+
+	// Targets: DNS resolution and the largest planned concurrency
+	maxConns := 0
+	if tests, err := loadTests(ctx, cfg, client); err != nil {
+		d.warn("Could not load tests: %v", err)
+	} else {
+		checked := make(map[string]bool)
+		for _, test := range tests {
+			if test.Concurrency > maxConns {
+				maxConns = test.Concurrency
+			}
+			u, err := url.Parse(test.URL)
+			if err != nil || u.Hostname() == "" {
+				d.fail("%s: invalid URL %q", test.Name, test.URL)
+				continue
+			}
+			host := u.Hostname()
+			if checked[host] {
+				continue
+			}
+			checked[host] = true
+			d.checkDNS(ctx, host)
+		}
+	}
+
+	// Open file limit: every connection needs a descriptor
+	if limit, err := runner.OpenFileLimit(); err != nil {
+		d.warn("Could not read the open file limit: %v", err)
+	} else if need := runner.FilesNeeded(maxConns); limit < need {
+		d.warn("Open file limit is %d but concurrency %d needs about %d; raise it with ulimit -n %d or run with -raise-nofile", limit, maxConns, need, need)
+	} else {
+		d.ok("Open file limit %d", limit)
+	}
+
+	// Ephemeral ports: each new outgoing connection uses one
+	if low, high, err := runner.EphemeralPortRange(); err != nil {
+		d.warn("Could not read the ephemeral port range: %v", err)
+	} else if ports := high - low + 1; ports < maxConns*2 {
+		d.warn("Only %d ephemeral ports (%d-%d) for concurrency %d; widen net.ipv4.ip_local_port_range", ports, low, high, maxConns)
+	} else {
+		d.ok("%d ephemeral ports available (%d-%d)", ports, low, high)
+	}
+
+	fmt.Printf("\n%d warning(s), %d failure(s)\n", d.warnings, d.failures)
+	if d.failures > 0 {
+		return fmt.Errorf("%d check(s) failed", d.failures)
+	}
+	return nil
+}
+
+// checkDNS resolves a target host and reports how long it took
+func (d *doctor) checkDNS(ctx context.Context, host string) {
+	if net.ParseIP(host) != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	elapsed := time.Since(start)
+	switch {
+	case err != nil:
+		d.fail("DNS lookup for %s failed: %v", host, err)
+	case elapsed > time.Second:
+		d.warn("DNS lookup for %s took %s; slow resolution inflates connection times", host, elapsed.Round(time.Millisecond))
+	default:
+		d.ok("%s resolves to %v", host, addrs)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/lazarkap/buzzbench.io/internal/accesslog"
+	"github.com/lazarkap/buzzbench.io/internal/config"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// runImport prints a local config with one test replaying the requests of an
+// access log. The test's rate is the log's average rate times -rate-scale,
+// or with -speed the recorded gaps are kept, sped up by that factor.
+func runImport(cfg *config.Config) error {
+	path := cfg.Args[0]
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	entries, skipped, err := accesslog.Parse(f, cfg.LogFormat)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no requests found in %s (%d lines skipped)", path, skipped)
+	}
+
+	target := cfg.LocalURL
+	if target == "" {
+		if entries[0].Origin == "" {
+			return fmt.Errorf("%s doesn't record the host; pass -url to set the target", path)
+		}
+		target = entries[0].Origin + "/"
+	}
+
+	start := entries[0].Time
+	test := localTest{
+		Name:        "Replay: " + filepath.Base(path),
+		URL:         target,
+		Method:      "GET",
+		Requests:    len(entries),
+		Concurrency: cfg.LocalConc,
+		TimeoutSecs: cfg.LocalTO,
+		Replay:      make([]api.ReplayRequest, len(entries)),
+	}
+	for i, e := range entries {
+		u := e.URL
+		if cfg.LocalURL == "" && e.Origin != entries[0].Origin && e.Origin != "" {
+			// Keep requests to other hosts going to those hosts
+			u = e.Origin + e.URL
+		}
+		offset := e.Time.Sub(start).Seconds()
+		test.Replay[i] = api.ReplayRequest{Method: e.Method, URL: u, Offset: math.Round(offset*1000) / 1000}
+	}
+
+	span := entries[len(entries)-1].Time.Sub(start).Seconds()
+	rate := 0.0
+	if span > 0 {
+		// Paced at this rate, the last request starts span seconds in
+		rate = float64(len(entries)-1) / span
+		test.RateRPS = math.Round(rate*cfg.RateScale*100) / 100
+	}
+	if cfg.Speed > 0 {
+		test.RateRPS = 0
+		test.ReplaySpeed = cfg.Speed
+	}
+	if cfg.Users > 0 {
+		test.ReplayUsers = cfg.Users
+		test.Requests *= cfg.Users
+		test.RateRPS *= float64(cfg.Users)
+	}
+	fmt.Fprintf(os.Stderr, "Imported %d requests over %.1fs (%.2f req/s)", len(entries), span, rate)
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, ", skipped %d unparsed lines", skipped)
+	}
+	fmt.Fprintln(os.Stderr)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode([]localTest{test})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lazarkap/buzzbench.io/internal/config"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// runList prints the project's tests so their IDs can be used with -test -id
+func runList(ctx context.Context, cfg *config.Config, client *api.Client) error {
+	tests, err := client.ListTests(ctx, cfg.TestFilter())
+	if err != nil {
+		return fmt.Errorf("list tests: %w", err)
+	}
+
+	if cfg.OutputJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(tests)
+	}
+
+	if len(tests) == 0 {
+		fmt.Println("No tests found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tURL\tREQUESTS\tCONCURRENCY\tTAGS\tPIPELINE")
+	for _, t := range tests {
+		pipeline := "no"
+		if t.RunInPipeline {
+			pipeline = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s %s\t%d\t%d\t%s\t%s\n",
+			t.ID, t.Name, t.Method, t.URL, t.Requests, t.Concurrency, strings.Join(t.Tags, ","), pipeline)
+	}
+	return w.Flush()
+}
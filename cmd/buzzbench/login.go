@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/lazarkap/buzzbench.io/internal/config"
+	"github.com/lazarkap/buzzbench.io/internal/credentials"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// runLogin exchanges the account's email and password for an API key and
+// stores the key in the OS keychain, where later commands pick it up.
+func runLogin(ctx context.Context, cfg *config.Config, client *api.Client, logger *log.Logger) error {
+	email := cfg.Email
+	if email == "" {
+		var err error
+		if email, err = prompt("Email: "); err != nil {
+			return fmt.Errorf("read email: %w", err)
+		}
+	}
+	if email == "" {
+		return fmt.Errorf("an email is required (use -email)")
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		return fmt.Errorf("read password: %w", err)
+	}
+
+	resp, err := client.Login(ctx, email, password)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	if resp.APIKey == "" {
+		return fmt.Errorf("login: server returned no API key")
+	}
+
+	if err := credentials.Save(cfg.BaseURL, resp.APIKey); err != nil {
+		return fmt.Errorf("store API key in keychain: %w", err)
+	}
+
+	logger.Printf("Logged in to %s", cfg.BaseURL)
+	printIdentity(resp.Identity)
+	return nil
+}
+
+// runWhoAmI shows who the active API key belongs to and where it came from
+func runWhoAmI(ctx context.Context, cfg *config.Config, client *api.Client) error {
+	if cfg.APIKey == "" {
+		return fmt.Errorf("not logged in: run buzzbench login or set BUZZBENCH_API_KEY")
+	}
+
+	identity, err := client.WhoAmI(ctx)
+	if err != nil {
+		return fmt.Errorf("whoami: %w", err)
+	}
+
+	printIdentity(*identity)
+	fmt.Printf("API:      %s\n", cfg.BaseURL)
+	fmt.Printf("Key from: %s\n", cfg.APIKeySource)
+	return nil
+}
+
+func printIdentity(identity api.Identity) {
+	fmt.Printf("Email:    %s\n", identity.Email)
+	fmt.Printf("Account:  %s\n", identity.Account)
+	fmt.Printf("Project:  %s\n", identity.Project)
+}
+
+// readPassword takes the password from BUZZBENCH_PASSWORD, or prompts for it
+// without echo when stdin is a terminal
+func readPassword() (string, error) {
+	if pw := os.Getenv("BUZZBENCH_PASSWORD"); pw != "" {
+		return pw, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return prompt("")
+	}
+
+	fmt.Print("Password: ")
+	pw, err := term.ReadPassword(fd)
+	fmt.Println()
+	return string(pw), err
+}
+
+// stdin is shared by prompts so buffered input isn't lost between them
+var stdin = bufio.NewReader(os.Stdin)
+
+// prompt prints label and reads one line from stdin
+func prompt(label string) (string, error) {
+	fmt.Print(label)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
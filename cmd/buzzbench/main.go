@@ -1,16 +1,34 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/lazarkap/buzzbench.io/internal/api"
 	"github.com/lazarkap/buzzbench.io/internal/config"
-	"github.com/lazarkap/buzzbench.io/internal/runner"
+	"github.com/lazarkap/buzzbench.io/internal/rotate"
+	"github.com/lazarkap/buzzbench.io/internal/runmeta"
+	"github.com/lazarkap/buzzbench.io/internal/samplesdb"
+	"github.com/lazarkap/buzzbench.io/internal/testcache"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
 	"github.com/lazarkap/buzzbench.io/pkg/results"
+	"github.com/lazarkap/buzzbench.io/pkg/runner"
 )
 
 func main() {
@@ -19,21 +37,348 @@ func main() {
 	cfg := config.New()
 	cfg.ParseFlags()
 
+	// An interrupt cancels API calls and running tests; a second one kills
+	// the process as usual
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	client := api.NewClient(cfg.BaseURL, cfg.APIKey)
+	client.Project = cfg.Project
+	client.Workspace = cfg.Workspace
+	client.SigningSecret = cfg.SigningSecret
 	testRunner := runner.NewRunner(cfg.Verbose, logger)
+	testRunner.SampleLimit = cfg.Samples
+	testRunner.OutlierPercentile = cfg.OutlierPct
+	testRunner.Secrets = []string{cfg.APIKey, cfg.SigningSecret}
+	testRunner.RedactParams = cfg.RedactParams()
+	testRunner.RaiseFileLimit = cfg.RaiseNoFile
+	if !cfg.OutputJSON {
+		// Keep stdout valid JSON
+		testRunner.ProgressInterval = cfg.Interval
+	}
+	if cfg.MetricsOut != "" {
+		// Appended to, so a sidecar tailing the file keeps its place
+		f, err := os.OpenFile(cfg.MetricsOut, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			logger.Fatalf("Error opening metrics file: %v", err)
+		}
+		defer f.Close()
+		testRunner.MetricsOut = f
+	}
+	if cfg.RequestLog != "" {
+		f, err := rotate.Open(cfg.RequestLog, rotate.Options{
+			MaxBytes: int64(cfg.RotateMB) << 20,
+			Keep:     cfg.RotateKeep,
+			Compress: cfg.RotateGzip,
+		})
+		if err != nil {
+			logger.Fatalf("Error opening request log: %v", err)
+		}
+		defer f.Close()
+		testRunner.RequestLog = f
+	}
+	if cfg.SamplesDB != "" {
+		labels, _ := cfg.LabelMap() // validated by ParseFlags
+		db, err := samplesdb.Open(cfg.SamplesDB, samplesdb.Options{
+			Metadata:     runMetadata(),
+			Labels:       labels,
+			Secrets:      testRunner.Secrets,
+			RedactParams: testRunner.RedactParams,
+		})
+		if err != nil {
+			logger.Fatalf("Error opening samples database: %v", err)
+		}
+		defer func() {
+			if err := db.Close(); err != nil {
+				logger.Printf("Error writing samples database: %v", err)
+			}
+		}()
+		runner.WithMetricsSink(db)(testRunner)
+	}
+
+	// discover and import print a config to stdout, and merge its results,
+	// so they get no banner either
+	if !cfg.OutputJSON && cfg.Command != "discover" && cfg.Command != "import" && cfg.Command != "merge" {
+		fmt.Println("BuzzBench - API Performance Testing Tool")
+		fmt.Println("----------------------------------------")
+	}
+
+	if cfg.Pprof != "" {
+		if err := startPprof(cfg.Pprof, logger); err != nil {
+			logger.Fatalf("Error starting pprof: %v", err)
+		}
+	}
+
+	switch cfg.Command {
+	case "schedule":
+		if err := runSchedule(ctx, cfg, client, testRunner, logger); err != nil {
+			logger.Fatalf("Error: %v", err)
+		}
+		return
+	case "monitor":
+		if err := runMonitor(ctx, cfg, client, testRunner, logger); err != nil {
+			logger.Fatalf("Error: %v", err)
+		}
+		return
+	case "compare":
+		regressed, err := runCompare(cfg)
+		if err != nil {
+			logger.Fatalf("Error: %v", err)
+		}
+		if regressed {
+			os.Exit(1)
+		}
+		return
+	case "merge":
+		if err := runMerge(cfg, logger); err != nil {
+			logger.Fatalf("Error: %v", err)
+		}
+		return
+	case "list":
+		if err := runList(ctx, cfg, client); err != nil {
+			logger.Fatalf("Error: %v", err)
+		}
+		return
+	case "login":
+		if err := runLogin(ctx, cfg, client, logger); err != nil {
+			logger.Fatalf("Error: %v", err)
+		}
+		return
+	case "whoami":
+		if err := runWhoAmI(ctx, cfg, client); err != nil {
+			logger.Fatalf("Error: %v", err)
+		}
+		return
+	case "doctor":
+		if err := runDoctor(ctx, cfg, client); err != nil {
+			logger.Fatalf("Error: %v", err)
+		}
+		return
+	case "discover":
+		if err := runDiscover(ctx, cfg); err != nil {
+			logger.Fatalf("Error: %v", err)
+		}
+		return
+	case "import":
+		if err := runImport(cfg); err != nil {
+			logger.Fatalf("Error: %v", err)
+		}
+		return
+	case "mockserver":
+		if err := runMockserver(ctx, cfg, logger); err != nil {
+			logger.Fatalf("Error: %v", err)
+		}
+		return
+	case "calibrate":
+		if err := runCalibrate(ctx, cfg, testRunner); err != nil {
+			logger.Fatalf("Error: %v", err)
+		}
+		return
+	case "self-update":
+		if err := runSelfUpdate(ctx, cfg, client, logger); err != nil {
+			logger.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	tests, err := loadTests(ctx, cfg, client)
+	if err != nil {
+		logger.Fatalf("Error loading tests: %v", err)
+	}
+
+	if len(tests) == 0 {
+		logger.Println("No tests to run. Exiting.")
+		os.Exit(0)
+	}
+
+	if err := runTests(ctx, cfg, client, testRunner, tests, logger); err != nil {
+		logger.Fatalf("Error: %v", err)
+	}
+}
+
+// loadTests resolves the tests to run and applies the CLI-wide defaults to them.
+func loadTests(ctx context.Context, cfg *config.Config, client *api.Client) ([]api.TestConfiguration, error) {
+	tests, err := sourceTests(ctx, cfg, client)
+	if err != nil {
+		return nil, err
+	}
+	// Tests fetched from the platform, or cached from it, may only read
+	// BUZZBENCH_ variables, or they could send the machine's environment
+	// to a host of their choosing
+	local := cfg.LocalURL != "" || cfg.ConfigFile != ""
+	for i := range tests {
+		if err := expandEnv(&tests[i], local); err != nil {
+			return nil, fmt.Errorf("test %q: %w", tests[i].Name, err)
+		}
+	}
+	applyDefaults(cfg, tests)
+	if err := applyOverrides(tests, cfg.Overrides); err != nil {
+		return nil, err
+	}
+	return tests, nil
+}
+
+// envRe matches ${NAME} references, and $${ as an escaped literal ${
+var envRe = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${NAME} in a test's URL, body, header values and
+// variable definitions with environment variables, so hosts and secrets can
+// come from CI. Referencing an unset variable is an error, as is one without
+// the api.SecretEnvPrefix in a test that isn't local.
+func expandEnv(t *api.TestConfiguration, local bool) error {
+	var missing []string
+	var denied error
+	expand := func(s string, quote bool) string {
+		return envRe.ReplaceAllStringFunc(s, func(m string) string {
+			if m == "$${" {
+				return "${"
+			}
+			name := m[2 : len(m)-1]
+			if !local {
+				if err := api.CheckSecretEnv(name); err != nil {
+					if denied == nil {
+						denied = err
+					}
+					return m
+				}
+			}
+			v, ok := os.LookupEnv(name)
+			if !ok {
+				missing = append(missing, name)
+				return m
+			}
+			if quote {
+				// Keep the variables JSON valid whatever the value holds
+				b, _ := json.Marshal(v)
+				v = string(b[1 : len(b)-1])
+			}
+			return v
+		})
+	}
+
+	t.URL = expand(t.URL, false)
+	t.Body = expand(t.Body, false)
+	t.AuthToken = expand(t.AuthToken, false)
+	if a := t.IntegratedAuth; a != nil {
+		a.Username = expand(a.Username, false)
+		a.Password = expand(a.Password, false)
+	}
+	t.HostHeader = expand(t.HostHeader, false)
+	for k, v := range t.Headers {
+		t.Headers[k] = expand(v, false)
+	}
+	for k, v := range t.QueryParams {
+		t.QueryParams[k] = expand(v, false)
+	}
+	for i := range t.Endpoints {
+		t.Endpoints[i].URL = expand(t.Endpoints[i].URL, false)
+		t.Endpoints[i].Body = expand(t.Endpoints[i].Body, false)
+	}
+	for i := range t.Steps {
+		t.Steps[i].URL = expand(t.Steps[i].URL, false)
+		t.Steps[i].Body = expand(t.Steps[i].Body, false)
+	}
+	for i := range t.VUSetup {
+		t.VUSetup[i].URL = expand(t.VUSetup[i].URL, false)
+		t.VUSetup[i].Body = expand(t.VUSetup[i].Body, false)
+	}
+	t.Variables = expand(t.Variables, true)
+
+	if denied != nil {
+		return denied
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("environment variable not set: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// applyOverrides applies each -override key=value to every test, e.g. to
+// rerun a platform test at reduced load without editing it.
+func applyOverrides(tests []api.TestConfiguration, overrides []string) error {
+	for _, o := range overrides {
+		key, value, ok := strings.Cut(o, "=")
+		if !ok {
+			return fmt.Errorf("invalid override %q (want key=value)", o)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case "requests", "concurrency", "timeout", "rate", "url", "host":
+		default:
+			return fmt.Errorf("unknown override %q (want requests, concurrency, timeout, rate, url or host)", key)
+		}
 
-	fmt.Println("BuzzBench - API Performance Testing Tool")
-	fmt.Println("----------------------------------------")
+		for i := range tests {
+			t := &tests[i]
+			var err error
+			switch key {
+			case "requests":
+				t.Requests, err = positiveInt(value)
+			case "concurrency":
+				t.Concurrency, err = positiveInt(value)
+			case "timeout":
+				t.TimeoutSecs, err = positiveInt(value)
+			case "rate":
+				t.RateRPS, err = strconv.ParseFloat(value, 64)
+			case "url":
+				t.URL = value
+			case "host":
+				t.URL, err = replaceHost(t.URL, value)
+			}
+			if err != nil {
+				return fmt.Errorf("override %s: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
 
-	var (
-		tests []api.TestConfiguration
-		err   error
-	)
+func positiveInt(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err == nil && n <= 0 {
+		err = fmt.Errorf("must be positive, got %d", n)
+	}
+	return n, err
+}
 
+// replaceHost swaps the host (and port) of a test URL, keeping its path and
+// query. The rest of the URL is kept as written, since a round trip through
+// url.URL would escape {{var}} placeholders.
+func replaceHost(rawURL, host string) (string, error) {
+	if host == "" || strings.ContainsAny(host, "/?#@") {
+		return "", fmt.Errorf("invalid host %q", host)
+	}
+	scheme, rest, ok := strings.Cut(rawURL, "://")
+	if !ok || scheme == "" {
+		return "", fmt.Errorf("%q has no scheme://host to replace", rawURL)
+	}
+	end := strings.IndexAny(rest, "/?#")
+	if end < 0 {
+		end = len(rest)
+	}
+	// Keep any user:password@ before the host
+	start := strings.LastIndex(rest[:end], "@") + 1
+	return scheme + "://" + rest[:start] + host + rest[end:], nil
+}
+
+// sourceTests reads the tests from CLI flags, a local config file, or the API.
+func sourceTests(ctx context.Context, cfg *config.Config, client *api.Client) ([]api.TestConfiguration, error) {
 	switch {
 	case cfg.LocalURL != "":
 		// Mode 1: single test from CLI flags
-		tests = []api.TestConfiguration{{
+		var script string
+		if cfg.LocalScript != "" {
+			data, err := os.ReadFile(cfg.LocalScript)
+			if err != nil {
+				return nil, fmt.Errorf("read script: %w", err)
+			}
+			script = string(data)
+		}
+		return []api.TestConfiguration{{
 			ID:          "local",
 			Name:        cfg.LocalName,
 			URL:         cfg.LocalURL,
@@ -43,64 +388,246 @@ func main() {
 			TimeoutSecs: cfg.LocalTO,
 			Body:        cfg.LocalBody,
 			AuthToken:   cfg.LocalAuth,
-		}}
+			HostHeader:  cfg.LocalHost,
+			SNIName:     cfg.LocalSNI,
+			Mode:        cfg.LocalMode,
+			Bandwidth:   cfg.LocalBW,
+			DelayMs:     cfg.LocalDelay,
+			JitterMs:    cfg.LocalJitter,
+			RateRPS:     cfg.LocalRate,
+			Script:      script,
+			LuaAssert:   cfg.LocalLuaAssert,
+			CacheBust:   cfg.LocalCacheBust,
+			Origin:      cfg.LocalOrigin,
+			Backoff:     cfg.LocalBackoff,
+			RetryAfter:  cfg.LocalRetryAfter,
+
+			DisableKeepAlives:   cfg.LocalNoKeepAlive,
+			MaxIdleConnsPerHost: cfg.LocalMaxIdleConns,
+			IdleConnTimeoutSecs: cfg.LocalIdleTimeout,
+			MaxConnsPerHost:     cfg.LocalMaxConns,
+
+			Timeouts: localTimeouts(cfg),
+
+			IntegratedAuth: localIntegratedAuth(cfg),
+		}}, nil
 
 	case cfg.ConfigFile != "":
 		// Mode 2: tests from a local JSON file
-		tests, err = loadConfigFile(cfg.ConfigFile)
+		tests, err := loadConfigFile(cfg.ConfigFile)
 		if err != nil {
-			logger.Fatalf("Error loading config file: %v", err)
+			return nil, fmt.Errorf("load config file: %w", err)
 		}
+		return tests, nil
 
 	case cfg.SingleTest:
 		// Mode 3a: fetch a single test from the API by ID
-		var test *api.TestConfiguration
-		test, err = client.FetchTestByID(cfg.TestID)
-		if err != nil {
-			logger.Fatalf("Error fetching test: %v", err)
-		}
-		tests = []api.TestConfiguration{*test}
+		return fetchTests(cfg, "test "+cfg.TestID, func() ([]api.TestConfiguration, error) {
+			test, err := client.FetchTestByID(ctx, cfg.TestID)
+			if err != nil {
+				return nil, fmt.Errorf("fetch test: %w", err)
+			}
+			return []api.TestConfiguration{*test}, nil
+		})
 
 	default:
 		// Mode 3b: fetch all pipeline tests from the API
-		tests, err = client.FetchPipelineTests()
-		if err != nil {
-			logger.Fatalf("Error fetching pipeline tests: %v", err)
+		filter := cfg.TestFilter()
+		what := "pipeline tests"
+		if filter.Environment != "" {
+			what += ", environment " + filter.Environment
 		}
+		if len(filter.Tags) > 0 {
+			tags := append([]string(nil), filter.Tags...)
+			sort.Strings(tags)
+			what += ", tags " + strings.Join(tags, ",")
+		}
+		return fetchTests(cfg, what, func() ([]api.TestConfiguration, error) {
+			tests, err := client.FetchPipelineTests(ctx, filter)
+			if err != nil {
+				return nil, fmt.Errorf("fetch pipeline tests: %w", err)
+			}
+			return tests, nil
+		})
 	}
+}
 
-	if len(tests) == 0 {
-		logger.Println("No tests to run. Exiting.")
-		os.Exit(0)
+// staleCacheAge is the age from which running cached tests comes with a
+// warning that they may no longer match the platform
+const staleCacheAge = 24 * time.Hour
+
+// fetchTests fetches tests from the API and caches them. With -offline the
+// tests come from the cache instead, and with -cached the cache stands in
+// when the fetch fails. what describes the fetch, as the cache key.
+func fetchTests(cfg *config.Config, what string, fetch func() ([]api.TestConfiguration, error)) ([]api.TestConfiguration, error) {
+	source := testcache.Source(cfg.BaseURL, cfg.Project, cfg.Workspace, what)
+	if !cfg.Offline {
+		tests, err := fetch()
+		if err == nil {
+			if err := testcache.Save(source, tests, time.Now()); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not cache the fetched tests: %v\n", err)
+			}
+			return tests, nil
+		}
+		if !cfg.Cached {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %v; running the cached tests instead\n", err)
 	}
 
-	var allResults []api.TestResult
+	tests, fetchedAt, err := testcache.Load(source)
+	if err != nil {
+		return nil, err
+	}
+	fetched := fetchedAt.Local().Format("2006-01-02 15:04")
+	if age := time.Since(fetchedAt); age >= staleCacheAge {
+		days := int(age / (24 * time.Hour))
+		fmt.Fprintf(os.Stderr, "Warning: the cached tests were fetched %d day(s) ago, at %s, and may no longer match the platform\n", days, fetched)
+	} else {
+		fmt.Fprintf(os.Stderr, "Running the cached tests fetched at %s\n", fetched)
+	}
+	return tests, nil
+}
 
-	for i, test := range tests {
-		fmt.Printf("\n[%d/%d] %s\n", i+1, len(tests), test.Name)
+// localTimeouts returns the per-phase timeouts of -dial-timeout, -tls-timeout
+// and -header-timeout, nil without any
+func localTimeouts(cfg *config.Config) *api.Timeouts {
+	t := api.Timeouts{
+		DialMs:           cfg.LocalDialTimeout,
+		TLSHandshakeMs:   cfg.LocalTLSTimeout,
+		ResponseHeaderMs: cfg.LocalHeaderTimeout,
+	}
+	if t == (api.Timeouts{}) {
+		return nil
+	}
+	return &t
+}
 
-		result, err := testRunner.RunTest(test)
-		if err != nil {
-			logger.Printf("Error running test: %v", err)
-			continue
+// localIntegratedAuth returns the integrated authentication of -auth-scheme,
+// nil without it
+func localIntegratedAuth(cfg *config.Config) *api.IntegratedAuth {
+	if cfg.LocalAuthScheme == "" {
+		return nil
+	}
+	return &api.IntegratedAuth{
+		Scheme:   cfg.LocalAuthScheme,
+		Username: cfg.LocalAuthUser,
+		Password: cfg.LocalAuthPassword,
+	}
+}
+
+// applyDefaults fills per-test options that were left unset with the CLI-wide defaults.
+func applyDefaults(cfg *config.Config, tests []api.TestConfiguration) {
+	chaos, _ := cfg.ChaosConfig() // validated by ParseFlags
+	for i := range tests {
+		if len(tests[i].UserAgents) == 0 {
+			tests[i].UserAgents = cfg.UserAgents
+		}
+		if tests[i].Chaos == nil && chaos != nil {
+			c := *chaos
+			tests[i].Chaos = &c
+		}
+		if tests[i].RequestIDHeader == "" {
+			tests[i].RequestIDHeader = cfg.RequestIDHeader
+		}
+		if tests[i].ResponseIDHeader == "" {
+			tests[i].ResponseIDHeader = cfg.ResponseIDHeader
+		}
+		if tests[i].IPFamily == "" {
+			tests[i].IPFamily = cfg.IPFamily()
+		}
+		if tests[i].LocalAddr == "" && len(tests[i].LocalAddrPool) == 0 {
+			tests[i].LocalAddrPool = cfg.LocalAddrPool()
+		}
+		if cfg.Speed > 0 && len(tests[i].Replay) > 0 {
+			tests[i].ReplaySpeed = cfg.Speed
 		}
+		if cfg.Users > 0 && len(tests[i].Replay) > 0 {
+			// rate_rps is the total rate; keep each user's pace
+			tests[i].RateRPS *= float64(cfg.Users) / float64(max(tests[i].ReplayUsers, 1))
+			tests[i].ReplayUsers = cfg.Users
+		}
+	}
+}
 
-		analyzer := results.NewAnalyzer(result)
+// runTests executes each test in turn, printing or collecting results and
+// submitting them to the API when in API mode.
+func runTests(ctx context.Context, cfg *config.Config, client *api.Client, testRunner *runner.Runner, tests []api.TestConfiguration, logger *log.Logger) error {
+	sweep, err := cfg.SweepLevels()
+	if err != nil {
+		return err
+	}
+	encodings := cfg.EncodingList()
+	variants := cfg.Variants()
 
-		if cfg.OutputJSON {
-			allResults = append(allResults, result)
-		} else {
-			analyzer.PrintSummary()
+	var allResults []api.TestResult
+	regressed := false
+
+	// Tests in one invocation share workers and connections
+	testRunner.BeginSuite()
+	defer testRunner.EndSuite()
+
+	for i, test := range tests {
+		if ctx.Err() != nil {
+			logger.Printf("Interrupted; skipping the remaining %d test(s)", len(tests)-i)
+			break
 		}
+		fmt.Printf("\n[%d/%d] %s\n", i+1, len(tests), test.Name)
 
-		// Only submit results to the API when in API mode and not doing JSON-only output
-		if !cfg.IsLocalMode() && !cfg.OutputJSON {
-			logger.Printf("Submitting test results to %s", cfg.BaseURL)
-			if err := client.SubmitTestResult(result); err != nil {
-				logger.Printf("Error submitting results: %v", err)
-			} else {
-				logger.Printf("Test results submitted successfully")
+		switch {
+		case len(sweep) > 0:
+			var levels []api.TestResult
+			for _, conc := range sweep {
+				test.Concurrency = conc
+				fmt.Printf("\n--- concurrency %d ---\n", conc)
+				levels = append(levels, runRepeated(ctx, cfg, client, testRunner, test, logger)...)
+			}
+			if !cfg.OutputJSON && len(levels) > 0 {
+				results.PrintSweepTable(test.Name, levels)
+			}
+			allResults = append(allResults, levels...)
+		case len(encodings) > 0:
+			var runs []api.TestResult
+			for _, encoding := range encodings {
+				test.AcceptEncoding = encoding
+				fmt.Printf("\n--- Accept-Encoding: %s ---\n", encoding)
+				runs = append(runs, runRepeated(ctx, cfg, client, testRunner, test, logger)...)
+			}
+			if !cfg.OutputJSON && len(runs) > 0 {
+				results.PrintEncodingTable(test.Name, runs)
+			}
+			allResults = append(allResults, runs...)
+		case len(variants) > 0:
+			var runs []api.TestResult
+			for _, v := range variants {
+				vt := test
+				vt.Headers = make(map[string]string, len(test.Headers)+len(v.Headers))
+				for name, value := range test.Headers {
+					if _, replaced := v.Headers[http.CanonicalHeaderKey(name)]; !replaced {
+						vt.Headers[name] = value
+					}
+				}
+				for name, value := range v.Headers {
+					vt.Headers[name] = value
+				}
+				fmt.Printf("\n--- %s ---\n", v.Label)
+				for _, r := range runRepeated(ctx, cfg, client, testRunner, vt, logger) {
+					r.Variant = v.Label
+					runs = append(runs, r)
+				}
+			}
+			if !cfg.OutputJSON && len(runs) > 0 {
+				results.PrintVariantTable(test.Name, runs)
 			}
+			allResults = append(allResults, runs...)
+		default:
+			// Look up the previous run before this one is submitted
+			prev, hasPrev := previousResult(ctx, cfg, client, test, logger)
+			runs := runRepeated(ctx, cfg, client, testRunner, test, logger)
+			if hasPrev && len(runs) > 0 && compareResults(cfg, "VS PREVIOUS RUN", prev, runs[len(runs)-1]) {
+				regressed = true
+			}
+			allResults = append(allResults, runs...)
 		}
 
 		if i < len(tests)-1 {
@@ -118,33 +645,314 @@ func main() {
 			output, err = json.MarshalIndent(allResults, "", "  ")
 		}
 		if err != nil {
-			logger.Fatalf("Error encoding JSON: %v", err)
+			return fmt.Errorf("encode JSON: %w", err)
 		}
 
 		if cfg.JSONOutFile != "" {
 			if err := os.WriteFile(cfg.JSONOutFile, output, 0644); err != nil {
-				logger.Fatalf("Error writing output file: %v", err)
+				return fmt.Errorf("write output file: %w", err)
 			}
 			logger.Printf("Results saved to %s", cfg.JSONOutFile)
 		} else {
 			fmt.Println(string(output))
 		}
 	}
+
+	if regressed {
+		return fmt.Errorf("p95 regressed by more than %.1f%% against the previous run", cfg.MaxRegression)
+	}
+	return nil
+}
+
+// previousResult fetches the test's last submitted result in API mode, for
+// comparison with this run
+func previousResult(ctx context.Context, cfg *config.Config, client *api.Client, test api.TestConfiguration, logger *log.Logger) (api.TestResult, bool) {
+	if cfg.IsLocalMode() || cfg.OutputJSON || test.ID == "" {
+		return api.TestResult{}, false
+	}
+	prev, err := client.FetchTestResults(ctx, test.ID, 1)
+	if err != nil {
+		logger.Printf("Could not fetch the previous result: %v", err)
+		return api.TestResult{}, false
+	}
+	if len(prev) == 0 {
+		return api.TestResult{}, false
+	}
+	return prev[0], true
+}
+
+// runRepeated runs a test cfg.Repeat times and, for more than one run,
+// prints the statistics across runs.
+func runRepeated(ctx context.Context, cfg *config.Config, client *api.Client, testRunner *runner.Runner, test api.TestConfiguration, logger *log.Logger) []api.TestResult {
+	var runs []api.TestResult
+	for n := 1; n <= cfg.Repeat && ctx.Err() == nil; n++ {
+		if cfg.Repeat > 1 {
+			fmt.Printf("\n--- run %d/%d ---\n", n, cfg.Repeat)
+		}
+		if result, ok := runOne(ctx, cfg, client, testRunner, test, logger); ok {
+			runs = append(runs, result)
+		}
+	}
+
+	if cfg.Repeat > 1 && !cfg.OutputJSON && len(runs) > 0 {
+		results.PrintRepeatSummary(test.Name, runs)
+	}
+	return runs
+}
+
+// runMetadata collects the metadata attached to every result, once per
+// invocation
+var runMetadata = sync.OnceValue(func() *api.RunMetadata {
+	return runmeta.Collect(config.Version)
+})
+
+// runOne runs a single test, prints its summary unless JSON output was requested,
+// and submits the result in API mode. ok is false when the test could not run.
+func runOne(ctx context.Context, cfg *config.Config, client *api.Client, testRunner *runner.Runner, test api.TestConfiguration, logger *log.Logger) (result api.TestResult, ok bool) {
+	var err error
+	switch {
+	case cfg.Capacity:
+		result, err = discoverCapacity(ctx, cfg, testRunner, test)
+	case cfg.TargetP95 > 0:
+		result, err = tuneConcurrency(ctx, cfg, testRunner, test)
+	case cfg.ErrorBudget > 0:
+		result, err = holdErrorBudget(ctx, cfg, testRunner, test)
+	default:
+		result, err = testRunner.RunTest(ctx, test)
+	}
+	if err != nil {
+		logger.Printf("Error running test: %v", err)
+		return result, false
+	}
+	result.Metadata = runMetadata()
+	result.Labels, _ = cfg.LabelMap() // validated by ParseFlags
+
+	if !cfg.OutputJSON {
+		analyzer := results.NewAnalyzer(result)
+		analyzer.Thresholds, _ = cfg.ColorThresholds() // validated by ParseFlags
+		analyzer.PrintSummary()
+	}
+	if cfg.Charts != "" {
+		saveCharts(cfg, test, result, logger)
+	}
+
+	// Only submit results to the API when in API mode and not doing JSON-only output
+	if !cfg.IsLocalMode() && !cfg.OutputJSON {
+		submitResult(ctx, cfg, client, test, result, logger)
+	}
+
+	return result, true
+}
+
+// chartNameRe matches the characters kept in chart file names
+var chartNameRe = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// saveCharts writes the latency distribution and timeline charts of a test
+// into the -charts directory. A chart that can't be drawn is reported and
+// skipped.
+func saveCharts(cfg *config.Config, test api.TestConfiguration, result api.TestResult, logger *log.Logger) {
+	if err := os.MkdirAll(cfg.Charts, 0755); err != nil {
+		logger.Printf("Error saving charts: %v", err)
+		return
+	}
+	name := test.ID
+	if name == "" {
+		name = test.Name
+	}
+	name = strings.Trim(chartNameRe.ReplaceAllString(name, "-"), "-")
+	if name == "" {
+		name = "test"
+	}
+
+	analyzer := results.NewAnalyzer(result)
+	for _, c := range []struct {
+		kind string
+		save func(string) error
+	}{
+		{"latency", analyzer.SaveLatencyChart},
+		{"timeline", analyzer.SaveTimelineChart},
+	} {
+		path := filepath.Join(cfg.Charts, name+"-"+c.kind+"."+cfg.ChartFormat)
+		if err := c.save(path); err != nil {
+			logger.Printf("Chart %s not saved: %v", path, err)
+			continue
+		}
+		if cfg.Verbose {
+			logger.Printf("Saved %s", path)
+		}
+	}
+}
+
+// submitResult sends a result to the API, honouring the test's submission
+// routing and the -submit-url / -no-submit flags
+func submitResult(ctx context.Context, cfg *config.Config, client *api.Client, test api.TestConfiguration, result api.TestResult, logger *log.Logger) {
+	route := api.SubmitConfig{BaseURL: cfg.SubmitURL}
+	if test.Submit != nil {
+		if err := checkSubmitRoute(cfg, test.Submit); err != nil {
+			logger.Printf("Error submitting results: %v", err)
+			return
+		}
+		route.Skip = test.Submit.Skip
+		route.APIKeyEnv = test.Submit.APIKeyEnv
+		if test.Submit.BaseURL != "" {
+			route.BaseURL = test.Submit.BaseURL
+		}
+	}
+	if cfg.NoSubmit || route.Skip {
+		logger.Printf("Result submission skipped")
+		return
+	}
+
+	if route.BaseURL != "" || route.APIKeyEnv != "" {
+		baseURL, apiKey := client.BaseURL, client.APIKey
+		if route.BaseURL != "" {
+			baseURL = strings.TrimRight(route.BaseURL, "/")
+		}
+		if route.APIKeyEnv != "" {
+			if apiKey = os.Getenv(route.APIKeyEnv); apiKey == "" {
+				logger.Printf("Error submitting results: %s is not set", route.APIKeyEnv)
+				return
+			}
+		}
+		scoped := client
+		client = api.NewClient(baseURL, apiKey)
+		if route.APIKeyEnv == "" {
+			// Same credentials, same project and workspace
+			client.Project, client.Workspace = scoped.Project, scoped.Workspace
+			client.SigningSecret = scoped.SigningSecret
+		}
+	}
+
+	logger.Printf("Submitting test results to %s", client.BaseURL)
+	resultID, err := client.SubmitTestResult(ctx, result)
+	if err != nil {
+		logger.Printf("Error submitting results: %v", err)
+		return
+	}
+	logger.Printf("Test results submitted successfully")
+
+	if paths := cfg.ArtifactPaths(); len(paths) > 0 {
+		if resultID == "" {
+			logger.Printf("Artifacts not uploaded: the API returned no result ID")
+			return
+		}
+		for _, path := range paths {
+			if err := uploadArtifact(ctx, client, resultID, path); err != nil {
+				logger.Printf("Error uploading %s: %v", path, err)
+			} else {
+				logger.Printf("Uploaded %s", path)
+			}
+		}
+	}
+}
+
+// checkSubmitRoute vets a test's submit block. Fetched tests come from the
+// platform, so their block may only send results to the API hosts of the
+// flags, and read a key from a BUZZBENCH_ variable. The account's own key
+// and signing secret never go to a host -base-url or -submit-url didn't name.
+func checkSubmitRoute(cfg *config.Config, route *api.SubmitConfig) error {
+	if route.APIKeyEnv != "" {
+		if err := api.CheckSecretEnv(route.APIKeyEnv); err != nil {
+			return fmt.Errorf("submit api_key_env: %w", err)
+		}
+	}
+	if route.BaseURL == "" {
+		return nil
+	}
+	u, err := url.Parse(route.BaseURL)
+	if err != nil || u.Host == "" || u.Scheme != "https" {
+		return fmt.Errorf("invalid submit base_url %q (want an https URL)", route.BaseURL)
+	}
+	host := strings.ToLower(u.Host)
+	switch {
+	case slices.Contains(cfg.APIHosts(), host):
+		return nil
+	case !slices.Contains(cfg.SubmitAllowList(), host):
+		return fmt.Errorf("submit base_url host %s is not allowed (add it with -submit-allow)", u.Host)
+	case route.APIKeyEnv == "":
+		return fmt.Errorf("submit base_url %s requires an api_key_env", u.Host)
+	}
+	return nil
+}
+
+// uploadArtifact attaches one file to a submitted result
+func uploadArtifact(ctx context.Context, client *api.Client, resultID, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return client.UploadArtifact(ctx, resultID, filepath.Base(path), bytes.NewReader(data))
+}
+
+// discoverCapacity runs the capacity search for a test and prints the probe table.
+func discoverCapacity(ctx context.Context, cfg *config.Config, testRunner *runner.Runner, test api.TestConfiguration) (api.TestResult, error) {
+	opts := runner.DefaultCapacityOptions()
+	opts.MinRPS = cfg.CapacityMin
+	opts.StepSeconds = cfg.CapacityStep
+	opts.MaxErrorRate = cfg.MaxErrorRate
+	opts.MaxP95 = cfg.MaxP95
+
+	result, probes, err := testRunner.DiscoverCapacity(ctx, test, opts)
+	if !cfg.OutputJSON && len(probes) > 0 {
+		results.PrintCapacityTable(test.Name, probes, result.CapacityRPS)
+	}
+	return result, err
+}
+
+// tuneConcurrency runs a concurrency tuning search for the test and prints
+// its steps
+func tuneConcurrency(ctx context.Context, cfg *config.Config, testRunner *runner.Runner, test api.TestConfiguration) (api.TestResult, error) {
+	opts := runner.DefaultTuneOptions()
+	opts.TargetP95 = cfg.TargetP95
+	opts.MaxErrorRate = cfg.MaxErrorRate
+	opts.MaxConcurrency = cfg.TuneMax
+	opts.StepSeconds = cfg.TuneStep
+
+	result, steps, err := testRunner.TuneConcurrency(ctx, test, opts)
+	if !cfg.OutputJSON && len(steps) > 0 {
+		results.PrintTuneTable(test.Name, steps, result)
+	}
+	return result, err
+}
+
+// holdErrorBudget runs the error budget controller for the test and prints
+// its windows
+func holdErrorBudget(ctx context.Context, cfg *config.Config, testRunner *runner.Runner, test api.TestConfiguration) (api.TestResult, error) {
+	opts := runner.DefaultBudgetOptions()
+	opts.ErrorBudget = cfg.ErrorBudget
+	opts.StartRPS = cfg.BudgetStart
+	opts.WindowSeconds = cfg.BudgetWindow
+	opts.Windows = cfg.BudgetDuration / cfg.BudgetWindow
+
+	result, windows, err := testRunner.HoldErrorBudget(ctx, test, opts)
+	if !cfg.OutputJSON && len(windows) > 0 {
+		results.PrintBudgetTable(test.Name, windows, result)
+	}
+	return result, err
 }
 
 // localVariable mirrors api.Variable but is used only for local config file parsing,
 // where variables are a proper JSON array instead of an escaped JSON string.
 type localVariable struct {
-	Name       string `json:"name"`
-	Type       string `json:"type"`
-	Strategy   string `json:"strategy"`
-	Value      string `json:"value,omitempty"`
-	StartValue int    `json:"startValue,omitempty"`
-	EndValue   int    `json:"endValue,omitempty"`
-	Increment  int    `json:"increment,omitempty"`
-	MinValue   int    `json:"minValue,omitempty"`
-	MaxValue   int    `json:"maxValue,omitempty"`
-	Template   string `json:"template,omitempty"`
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Strategy   string   `json:"strategy"`
+	Value      string   `json:"value,omitempty"`
+	StartValue int      `json:"startValue,omitempty"`
+	EndValue   int      `json:"endValue,omitempty"`
+	Increment  int      `json:"increment,omitempty"`
+	Format     string   `json:"format,omitempty"`
+	Offset     string   `json:"offset,omitempty"`
+	MinValue   int      `json:"minValue,omitempty"`
+	MaxValue   int      `json:"maxValue,omitempty"`
+	Template   string   `json:"template,omitempty"`
+	Length     int      `json:"length,omitempty"`
+	Charset    string   `json:"charset,omitempty"`
+	Values     []string `json:"values,omitempty"`
+	Selection  string   `json:"selection,omitempty"`
+	Sensitive  bool     `json:"sensitive,omitempty"`
+	Scope      string   `json:"scope,omitempty"`
+	Reuse      int      `json:"reuse,omitempty"`
 }
 
 // localTest is the schema for entries in a local config file.
@@ -162,9 +970,75 @@ type localTest struct {
 	Body        string          `json:"body,omitempty"`
 	Variables   []localVariable `json:"variables,omitempty"`
 	Description string          `json:"description,omitempty"`
+
+	Headers     map[string]string `json:"headers,omitempty"`
+	QueryParams map[string]string `json:"query_params,omitempty"`
+
+	RequestIDHeader  string `json:"request_id_header,omitempty"`
+	ResponseIDHeader string `json:"response_id_header,omitempty"`
+	HostHeader       string `json:"host_header,omitempty"`
+	SNIName          string `json:"sni_name,omitempty"`
+	IPFamily         string `json:"ip_family,omitempty"`
+	LocalAddr        string `json:"local_addr,omitempty"`
+
+	LocalAddrPool []string `json:"local_addr_pool,omitempty"`
+
+	DisableKeepAlives   bool `json:"disable_keep_alives,omitempty"`
+	MaxIdleConnsPerHost int  `json:"max_idle_conns_per_host,omitempty"`
+	IdleConnTimeoutSecs int  `json:"idle_conn_timeout_seconds,omitempty"`
+	MaxConnsPerHost     int  `json:"max_conns_per_host,omitempty"`
+
+	Timeouts *api.Timeouts `json:"timeouts,omitempty"`
+
+	Mode      string `json:"mode,omitempty"`
+	Bandwidth string `json:"bandwidth,omitempty"`
+	CacheBust string `json:"cache_bust,omitempty"`
+	Origin    string `json:"origin,omitempty"`
+	DelayMs   int    `json:"delay_ms,omitempty"`
+	JitterMs  int    `json:"jitter_ms,omitempty"`
+
+	RateRPS float64 `json:"rate_rps,omitempty"`
+	Backoff bool    `json:"backoff,omitempty"`
+
+	RetryAfter int `json:"retry_after,omitempty"`
+
+	Chaos *api.ChaosConfig `json:"chaos,omitempty"`
+
+	UserAgents []string `json:"user_agents,omitempty"`
+
+	IntegratedAuth *api.IntegratedAuth `json:"integrated_auth,omitempty"`
+
+	AcceptEncoding string `json:"accept_encoding,omitempty"`
+
+	Prometheus *api.PrometheusConfig `json:"prometheus,omitempty"`
+	ClickHouse *api.ClickHouseConfig `json:"clickhouse,omitempty"`
+	Kafka      *api.KafkaConfig      `json:"kafka,omitempty"`
+
+	Script     string `json:"script,omitempty"`
+	ScriptFile string `json:"script_file,omitempty"` // relative to the config file
+	LuaAssert  string `json:"lua_assert,omitempty"`
+
+	Each      *localEach          `json:"each,omitempty"`
+	Endpoints []api.Endpoint      `json:"endpoints,omitempty"`
+	Steps     []api.Step          `json:"steps,omitempty"`
+	VUSetup   []api.Step          `json:"vu_setup,omitempty"`
+	Replay    []api.ReplayRequest `json:"replay,omitempty"`
+
+	ReplaySpeed float64 `json:"replay_speed,omitempty"`
+	ReplayUsers int     `json:"replay_users,omitempty"`
 }
 
-func (lt localTest) toTestConfiguration() (api.TestConfiguration, error) {
+// localEach is a test's each list; File, relative to the config file, holds
+// one value per line, or a table when it is a .csv file with a header row
+type localEach struct {
+	Variable string     `json:"variable,omitempty"`
+	Values   []string   `json:"values,omitempty"`
+	Columns  []string   `json:"columns,omitempty"`
+	Rows     [][]string `json:"rows,omitempty"`
+	File     string     `json:"file,omitempty"`
+}
+
+func (lt localTest) toTestConfiguration(baseDir string) (api.TestConfiguration, error) {
 	tc := api.TestConfiguration{
 		ID:          lt.ID,
 		Name:        lt.Name,
@@ -176,6 +1050,95 @@ func (lt localTest) toTestConfiguration() (api.TestConfiguration, error) {
 		AuthToken:   lt.AuthToken,
 		Body:        lt.Body,
 		Description: lt.Description,
+		Headers:     lt.Headers,
+		QueryParams: lt.QueryParams,
+
+		RequestIDHeader:  lt.RequestIDHeader,
+		ResponseIDHeader: lt.ResponseIDHeader,
+		HostHeader:       lt.HostHeader,
+		SNIName:          lt.SNIName,
+		IPFamily:         lt.IPFamily,
+		LocalAddr:        lt.LocalAddr,
+		LocalAddrPool:    lt.LocalAddrPool,
+
+		DisableKeepAlives:   lt.DisableKeepAlives,
+		MaxIdleConnsPerHost: lt.MaxIdleConnsPerHost,
+		IdleConnTimeoutSecs: lt.IdleConnTimeoutSecs,
+		MaxConnsPerHost:     lt.MaxConnsPerHost,
+
+		Timeouts: lt.Timeouts,
+
+		Mode:      lt.Mode,
+		Bandwidth: lt.Bandwidth,
+		CacheBust: lt.CacheBust,
+		Origin:    lt.Origin,
+		DelayMs:   lt.DelayMs,
+		JitterMs:  lt.JitterMs,
+
+		RateRPS: lt.RateRPS,
+		Backoff: lt.Backoff,
+
+		RetryAfter: lt.RetryAfter,
+
+		Chaos: lt.Chaos,
+
+		UserAgents: lt.UserAgents,
+
+		IntegratedAuth: lt.IntegratedAuth,
+
+		AcceptEncoding: lt.AcceptEncoding,
+
+		Prometheus: lt.Prometheus,
+		ClickHouse: lt.ClickHouse,
+		Kafka:      lt.Kafka,
+		Script:     lt.Script,
+		LuaAssert:  lt.LuaAssert,
+		Endpoints:  lt.Endpoints,
+		Steps:      lt.Steps,
+		VUSetup:    lt.VUSetup,
+		Replay:     lt.Replay,
+
+		ReplaySpeed: lt.ReplaySpeed,
+		ReplayUsers: lt.ReplayUsers,
+	}
+
+	if lt.ScriptFile != "" {
+		path := lt.ScriptFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		script, err := os.ReadFile(path)
+		if err != nil {
+			return tc, fmt.Errorf("read script: %w", err)
+		}
+		tc.Script = string(script)
+	}
+
+	if lt.Each != nil {
+		each := &api.EachConfig{Variable: lt.Each.Variable, Values: lt.Each.Values, Columns: lt.Each.Columns, Rows: lt.Each.Rows}
+		if lt.Each.File != "" {
+			path := lt.Each.File
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(baseDir, path)
+			}
+			if strings.EqualFold(filepath.Ext(path), ".csv") {
+				columns, rows, err := readCSV(path)
+				if err != nil {
+					return tc, fmt.Errorf("read each file: %w", err)
+				}
+				each.Columns, each.Rows = columns, append(each.Rows, rows...)
+			} else {
+				lines, err := readLines(path)
+				if err != nil {
+					return tc, fmt.Errorf("read each file: %w", err)
+				}
+				each.Values = append(each.Values, lines...)
+			}
+		}
+		if len(each.Rows) > 0 && len(each.Columns) == 0 {
+			return tc, fmt.Errorf("each: rows need columns")
+		}
+		tc.Each = each
 	}
 
 	if len(lt.Variables) > 0 {
@@ -190,6 +1153,42 @@ func (lt localTest) toTestConfiguration() (api.TestConfiguration, error) {
 	return tc, nil
 }
 
+// readLines returns the non-blank lines of a file, skipping # comments
+func readLines(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// readCSV reads a CSV file whose first record names the columns
+func readCSV(path string) (columns []string, rows [][]string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("%s is empty", path)
+	}
+	return records[0], records[1:], nil
+}
+
 // loadConfigFile reads a JSON file containing an array of localTest definitions
 // and converts them to api.TestConfiguration values the runner understands.
 func loadConfigFile(path string) ([]api.TestConfiguration, error) {
@@ -205,7 +1204,7 @@ func loadConfigFile(path string) ([]api.TestConfiguration, error) {
 
 	tests := make([]api.TestConfiguration, 0, len(raw))
 	for _, lt := range raw {
-		tc, err := lt.toTestConfiguration()
+		tc, err := lt.toTestConfiguration(filepath.Dir(path))
 		if err != nil {
 			return nil, fmt.Errorf("test %q: %w", lt.Name, err)
 		}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/lazarkap/buzzbench.io/internal/config"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+	"github.com/lazarkap/buzzbench.io/pkg/results"
+)
+
+// runMerge combines the result files of one run made from several machines
+// into a single result per test. Results are matched against the first
+// file's by test configuration ID, falling back to their position. The
+// merged results are written to -out / -o, or printed to stdout.
+func runMerge(cfg *config.Config, logger *log.Logger) error {
+	files := make([][]api.TestResult, len(cfg.Args))
+	for i, path := range cfg.Args {
+		list, err := results.LoadFile(path)
+		if err != nil {
+			return err
+		}
+		files[i] = list
+	}
+
+	merged := make([]api.TestResult, 0, len(files[0]))
+	for i, first := range files[0] {
+		parts := []api.TestResult{first}
+		for j, list := range files[1:] {
+			part, ok := matchResult(list, first, i)
+			if !ok {
+				return fmt.Errorf("%s has no result for %s %s", cfg.Args[j+1], first.Method, first.URL)
+			}
+			parts = append(parts, part)
+		}
+
+		result, exact := results.Merge(parts)
+		if !exact {
+			fmt.Fprintf(os.Stderr, "Warning: %s %s: not every file has raw samples, so percentiles are approximated; rerun with -samples N for exact ones\n",
+				first.Method, first.URL)
+		}
+		merged = append(merged, result)
+	}
+
+	var output []byte
+	var err error
+	if len(merged) == 1 {
+		output, err = json.MarshalIndent(merged[0], "", "  ")
+	} else {
+		output, err = json.MarshalIndent(merged, "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("encode JSON: %w", err)
+	}
+
+	if cfg.JSONOutFile == "" {
+		fmt.Println(string(output))
+		return nil
+	}
+	if err := os.WriteFile(cfg.JSONOutFile, output, 0644); err != nil {
+		return fmt.Errorf("write output file: %w", err)
+	}
+	logger.Printf("Merged %d result files into %s", len(cfg.Args), cfg.JSONOutFile)
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/internal/config"
+	"github.com/lazarkap/buzzbench.io/internal/mockserver"
+)
+
+// runMockserver serves a mock target until SIGINT/SIGTERM. Without -endpoints
+// every path answers according to the -latency, -error-rate and -size flags.
+func runMockserver(ctx context.Context, cfg *config.Config, logger *log.Logger) error {
+	eps := []mockserver.Endpoint{{
+		Path:      "/",
+		LatencyMs: cfg.MockLatency,
+		JitterMs:  cfg.MockJitter,
+		ErrorRate: cfg.MockErrorRate,
+		Size:      cfg.MockSize,
+	}}
+	if cfg.MockEndpoints != "" {
+		var err error
+		if eps, err = mockserver.Load(cfg.MockEndpoints); err != nil {
+			return err
+		}
+	}
+	mock, err := mockserver.New(eps)
+	if err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", cfg.MockListen)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: mock, ReadHeaderTimeout: 10 * time.Second}
+
+	logger.Printf("Mock server listening on http://%s", ln.Addr())
+	for _, ep := range mock.Endpoints() {
+		method := ep.Method
+		if method == "" {
+			method = "*"
+		}
+		logger.Printf("  %-6s %-24s %d, %s, %g%% errors (%d), %d bytes",
+			method, ep.Path, ep.Status, latencyLabel(ep), ep.ErrorRate, ep.ErrorStatus, ep.Size)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdown); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	logger.Println("Mock server stopped.")
+	return nil
+}
+
+// latencyLabel describes an endpoint's latency, e.g. "50±10 ms".
+func latencyLabel(ep mockserver.Endpoint) string {
+	if ep.JitterMs > 0 {
+		return fmt.Sprintf("%d±%d ms", ep.LatencyMs, ep.JitterMs)
+	}
+	return fmt.Sprintf("%d ms", ep.LatencyMs)
+}
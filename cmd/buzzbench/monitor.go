@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/internal/config"
+	"github.com/lazarkap/buzzbench.io/internal/redact"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+	"github.com/lazarkap/buzzbench.io/pkg/runner"
+)
+
+// runMonitor turns each test into a low-rate availability probe: every interval
+// it sends a single request per test and reports the outcome to stdout, the
+// platform (API mode) and an optional webhook. It returns on SIGINT/SIGTERM.
+func runMonitor(ctx context.Context, cfg *config.Config, client *api.Client, testRunner *runner.Runner, logger *log.Logger) error {
+	if cfg.MonitorInterval <= 0 {
+		return fmt.Errorf("monitor interval must be positive, got %s", cfg.MonitorInterval)
+	}
+
+	tests, err := loadTests(ctx, cfg, client)
+	if err != nil {
+		return fmt.Errorf("load tests: %w", err)
+	}
+	if len(tests) == 0 {
+		logger.Println("No tests to monitor. Exiting.")
+		return nil
+	}
+
+	webhook := &http.Client{Timeout: 10 * time.Second}
+	ticker := time.NewTicker(cfg.MonitorInterval)
+	defer ticker.Stop()
+
+	logger.Printf("Monitoring %d test(s) every %s", len(tests), cfg.MonitorInterval)
+
+	for {
+		for _, test := range tests {
+			probe := runProbe(ctx, testRunner, test)
+
+			state := "UP"
+			if !probe.Up {
+				state = "DOWN"
+			}
+			fmt.Printf("%s  %-4s  %-30s  %4d  %8.2f ms  %s\n",
+				probe.Timestamp.Format(time.RFC3339), state, test.Name, probe.Status, probe.ResponseTime, probe.Error)
+
+			if !cfg.IsLocalMode() {
+				if err := client.SubmitProbeResult(ctx, probe); err != nil {
+					logger.Printf("Error submitting probe result: %v", err)
+				}
+			}
+
+			if cfg.WebhookURL != "" {
+				if err := postWebhook(webhook, cfg.WebhookURL, probe); err != nil {
+					logger.Printf("Error posting to webhook: %v", err)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Println("Monitor stopped.")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// runProbe executes a single request for the test and condenses the result.
+// Tests that size themselves from a list, each and replay, probe its first
+// entry only.
+func runProbe(ctx context.Context, testRunner *runner.Runner, test api.TestConfiguration) api.ProbeResult {
+	test.Requests = 1
+	test.Concurrency = 1
+	if each := test.Each; each != nil {
+		first := *each
+		first.Values = first.Values[:min(len(first.Values), 1)]
+		first.Rows = first.Rows[:min(len(first.Rows), 1)]
+		test.Each = &first
+	}
+	if len(test.Replay) > 0 {
+		test.Replay = test.Replay[:1]
+		test.ReplayUsers = 0
+		test.ReplaySpeed = 0
+	}
+
+	// Probes go to the platform and any webhook, so they are masked like
+	// submitted results
+	secrets := append([]string{test.AuthToken}, testRunner.Secrets...)
+	red := redact.New(secrets, append(redact.DefaultParams, testRunner.RedactParams...))
+	probe := api.ProbeResult{
+		TestConfigurationID: test.ID,
+		Name:                test.Name,
+		URL:                 red.String(test.URL),
+		Timestamp:           time.Now(),
+	}
+
+	result, err := testRunner.RunTest(ctx, test)
+	if err != nil {
+		probe.Error = red.String(err.Error())
+		return probe
+	}
+
+	probe.Up = result.SuccessRate == 100
+	probe.ResponseTime = result.AvgResponseTime
+	probe.Status = probeStatus(result.StatusCodes)
+	if len(result.Errors) > 0 {
+		probe.Error = red.String(result.Errors[0].Message)
+	}
+
+	return probe
+}
+
+// probeStatus picks the status a probe reports: the lowest failing (4xx or
+// 5xx) code if any, otherwise the most frequent one, the lower on a tie
+func probeStatus(counts map[string]int) int {
+	status, failing, most := 0, false, 0
+	for code, n := range counts {
+		c, err := strconv.Atoi(code)
+		if err != nil {
+			continue
+		}
+		switch {
+		case c >= 400 && (!failing || c < status):
+			status, failing = c, true
+		case failing:
+		case n > most || n == most && c < status:
+			status, most = c, n
+		}
+	}
+	return status
+}
+
+// postWebhook sends v as a JSON POST body to url.
+func postWebhook(client *http.Client, url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode webhook body: %w", err)
+	}
+
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
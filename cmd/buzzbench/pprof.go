@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// startPprof serves the runtime profiling endpoints on addr in the background.
+// A bare port, or one without a host, binds to the loopback interface; any
+// other host must be a loopback one, since the endpoints expose the command
+// line and memory of a process that holds API keys.
+func startPprof(addr string, logger *log.Logger) error {
+	if !strings.Contains(addr, ":") {
+		addr = "127.0.0.1:" + addr
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	switch ip := net.ParseIP(host); {
+	case host == "":
+		addr = net.JoinHostPort("127.0.0.1", port)
+	case host == "localhost", ip != nil && ip.IsLoopback():
+	default:
+		return fmt.Errorf("pprof must listen on a loopback address, not %q", host)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	logger.Printf("pprof listening on http://%s/debug/pprof/", ln.Addr())
+
+	go func() {
+		if err := http.Serve(ln, mux); err != nil {
+			logger.Printf("pprof server stopped: %v", err)
+		}
+	}()
+	return nil
+}
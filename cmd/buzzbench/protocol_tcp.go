@@ -0,0 +1,6 @@
+//go:build tcp
+
+package main
+
+// Raw TCP protocol, linked in with -tags tcp
+import _ "github.com/lazarkap/buzzbench.io/internal/protocols/tcp"
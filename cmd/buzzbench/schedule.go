@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/internal/config"
+	"github.com/lazarkap/buzzbench.io/internal/schedule"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+	"github.com/lazarkap/buzzbench.io/pkg/runner"
+)
+
+// runSchedule keeps the process alive and runs the configured tests every time
+// the cron expression fires. Tests are reloaded on each run so changes made on
+// the platform are picked up without restarting. It returns on SIGINT/SIGTERM.
+func runSchedule(ctx context.Context, cfg *config.Config, client *api.Client, testRunner *runner.Runner, logger *log.Logger) error {
+	sched, err := schedule.Parse(cfg.CronExpr)
+	if err != nil {
+		return err
+	}
+
+	for {
+		next := sched.Next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("cron expression %q never fires", cfg.CronExpr)
+		}
+		logger.Printf("Next scheduled run at %s", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logger.Println("Scheduler stopped.")
+			return nil
+		case <-timer.C:
+		}
+
+		tests, err := loadTests(ctx, cfg, client)
+		if err != nil {
+			logger.Printf("Error loading tests: %v", err)
+			continue
+		}
+		if len(tests) == 0 {
+			logger.Println("No tests to run.")
+			continue
+		}
+
+		if err := runTests(ctx, cfg, client, testRunner, tests, logger); err != nil {
+			logger.Printf("Error: %v", err)
+		}
+	}
+}
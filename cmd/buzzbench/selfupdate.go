@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/lazarkap/buzzbench.io/internal/config"
+	"github.com/lazarkap/buzzbench.io/internal/update"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// runSelfUpdate replaces the running binary with the latest release for this
+// platform after verifying its signed checksum.
+func runSelfUpdate(ctx context.Context, cfg *config.Config, client *api.Client, logger *log.Logger) error {
+	release, err := client.LatestRelease(ctx, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return fmt.Errorf("check for updates: %w", err)
+	}
+
+	if !update.Newer(release.Version, config.Version) {
+		logger.Printf("buzzbench %s is up to date", config.Version)
+		return nil
+	}
+	logger.Printf("New release available: %s (current %s)", release.Version, config.Version)
+	if cfg.CheckOnly {
+		return nil
+	}
+
+	if config.UpdatePublicKey == "" {
+		return fmt.Errorf("this build has no release signing key; download %s manually", release.URL)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate executable: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("locate executable: %w", err)
+	}
+
+	download, cancel := context.WithTimeout(ctx, update.DownloadTimeout)
+	defer cancel()
+	data, err := client.Download(download, release.URL)
+	if err != nil {
+		return fmt.Errorf("download release: %w", err)
+	}
+	if err := update.Verify(data, release.Version, runtime.GOOS, runtime.GOARCH, release.SHA256, release.Signature, config.UpdatePublicKey); err != nil {
+		return fmt.Errorf("verify release: %w", err)
+	}
+	if err := update.Replace(exe, data); err != nil {
+		return fmt.Errorf("install release: %w", err)
+	}
+
+	logger.Printf("Updated %s to %s", exe, release.Version)
+	return nil
+}
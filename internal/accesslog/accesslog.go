@@ -0,0 +1,229 @@
+// Package accesslog parses web server and load balancer access logs into the
+// requests they record, for replaying as a test.
+package accesslog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Log formats
+const (
+	FormatAuto       = ""
+	FormatCombined   = "combined"   // nginx / Apache combined or common log format
+	FormatCloudFront = "cloudfront" // CloudFront standard (tab-separated) logs
+	FormatALB        = "alb"        // Application Load Balancer logs
+)
+
+// Entry is one recorded request. URL is the path and query; Origin is the
+// scheme and host, when the format records them.
+type Entry struct {
+	Time   time.Time
+	Method string
+	Origin string
+	URL    string
+}
+
+// Parse reads a log in the given format, detecting it from the first line
+// when format is FormatAuto, and returns its entries sorted by time.
+// Lines that don't parse are counted in skipped.
+func Parse(r io.Reader, format string) (entries []Entry, skipped int, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var cf *cloudFrontFields
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if format == FormatAuto {
+			format = Detect(line)
+		}
+
+		var e Entry
+		var ok bool
+		switch format {
+		case FormatCombined:
+			e, ok = parseCombined(line)
+		case FormatCloudFront:
+			if strings.HasPrefix(line, "#") {
+				if fields, found := strings.CutPrefix(line, "#Fields:"); found {
+					cf = newCloudFrontFields(strings.Fields(fields))
+				}
+				continue
+			}
+			if cf == nil {
+				cf = newCloudFrontFields(defaultCloudFrontFields)
+			}
+			e, ok = cf.parse(line)
+		case FormatALB:
+			e, ok = parseALB(line)
+		default:
+			return nil, 0, fmt.Errorf("unknown log format %q", format)
+		}
+		if !ok {
+			skipped++
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// Workers write their lines out of order around the same second
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries, skipped, nil
+}
+
+// Detect guesses the format of a log from one of its lines
+func Detect(line string) string {
+	if strings.HasPrefix(line, "#Version") || strings.HasPrefix(line, "#Fields") || strings.Count(line, "\t") >= 10 {
+		return FormatCloudFront
+	}
+	if kind, rest, ok := strings.Cut(line, " "); ok {
+		switch kind {
+		case "http", "https", "h2", "grpcs", "ws", "wss":
+			ts, _, _ := strings.Cut(rest, " ")
+			if _, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+				return FormatALB
+			}
+		}
+	}
+	return FormatCombined
+}
+
+// combinedRe matches the start of the common and combined formats:
+// host ident user [time] "METHOD target PROTO" status
+var combinedRe = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "(\S+) (\S+)[^"]*" \d{3}`)
+
+func parseCombined(line string) (Entry, bool) {
+	m := combinedRe.FindStringSubmatch(line)
+	if m == nil {
+		return Entry{}, false
+	}
+	t, err := time.Parse("02/Jan/2006:15:04:05 -0700", m[1])
+	if err != nil {
+		return Entry{}, false
+	}
+	return Entry{Time: t, Method: m[2], URL: m[3]}, true
+}
+
+// defaultCloudFrontFields is the column order of CloudFront standard logs,
+// used when a file lacks its #Fields header
+var defaultCloudFrontFields = []string{"date", "time", "x-edge-location", "sc-bytes", "c-ip", "cs-method",
+	"cs(Host)", "cs-uri-stem", "sc-status", "cs(Referer)", "cs(User-Agent)", "cs-uri-query"}
+
+// cloudFrontFields maps the columns a CloudFront log needs to their positions
+type cloudFrontFields struct {
+	date, time, method, host, stem, query, protocol int
+}
+
+func newCloudFrontFields(names []string) *cloudFrontFields {
+	f := &cloudFrontFields{date: -1, time: -1, method: -1, host: -1, stem: -1, query: -1, protocol: -1}
+	for i, name := range names {
+		switch name {
+		case "date":
+			f.date = i
+		case "time":
+			f.time = i
+		case "cs-method":
+			f.method = i
+		case "x-host-header":
+			f.host = i
+		case "cs(Host)":
+			if f.host < 0 {
+				f.host = i
+			}
+		case "cs-uri-stem":
+			f.stem = i
+		case "cs-uri-query":
+			f.query = i
+		case "cs-protocol":
+			f.protocol = i
+		}
+	}
+	return f
+}
+
+func (f *cloudFrontFields) parse(line string) (Entry, bool) {
+	cols := strings.Split(line, "\t")
+	get := func(i int) string {
+		if i < 0 || i >= len(cols) || cols[i] == "-" {
+			return ""
+		}
+		return cols[i]
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", get(f.date)+" "+get(f.time))
+	if err != nil || get(f.method) == "" || get(f.stem) == "" {
+		return Entry{}, false
+	}
+	target := get(f.stem)
+	if q := get(f.query); q != "" {
+		target += "?" + q
+	}
+	e := Entry{Time: t, Method: get(f.method), URL: target}
+	if host := get(f.host); host != "" {
+		scheme := get(f.protocol)
+		if scheme != "http" {
+			scheme = "https"
+		}
+		e.Origin = scheme + "://" + host
+	}
+	return e, true
+}
+
+// parseALB reads an Application Load Balancer log line: type time elb
+// client target three timings two statuses two byte counts "request" ...
+func parseALB(line string) (Entry, bool) {
+	fields := splitQuoted(line)
+	if len(fields) < 13 {
+		return Entry{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, fields[1])
+	if err != nil {
+		return Entry{}, false
+	}
+	parts := strings.Fields(fields[12])
+	if len(parts) < 2 {
+		return Entry{}, false
+	}
+	u, err := url.Parse(parts[1])
+	if err != nil {
+		return Entry{}, false
+	}
+	host := strings.TrimSuffix(strings.TrimSuffix(u.Host, ":443"), ":80")
+	return Entry{Time: t, Method: parts[0], Origin: u.Scheme + "://" + host, URL: u.RequestURI()}, true
+}
+
+// splitQuoted splits a line on spaces, keeping double-quoted fields whole
+func splitQuoted(line string) []string {
+	var fields []string
+	for line != "" {
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			break
+		}
+		if line[0] == '"' {
+			end := strings.Index(line[1:], `"`)
+			if end < 0 {
+				fields = append(fields, line[1:])
+				break
+			}
+			fields = append(fields, line[1:end+1])
+			line = line[end+2:]
+			continue
+		}
+		field, rest, _ := strings.Cut(line, " ")
+		fields = append(fields, field)
+		line = rest
+	}
+	return fields
+}
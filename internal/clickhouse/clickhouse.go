@@ -0,0 +1,174 @@
+// Package clickhouse inserts rows into a ClickHouse table over its HTTP
+// interface, in batches sent in the background.
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Writer batches rows as JSONEachRow and inserts each batch with one HTTP
+// request. A batch is sent once it holds BatchSize rows or FlushEvery has
+// passed since the last one.
+type Writer struct {
+	Endpoint   string // e.g. http://clickhouse:8123
+	Table      string // optionally database-qualified
+	Username   string
+	Password   string
+	BatchSize  int
+	FlushEvery time.Duration
+	HTTPClient *http.Client
+
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	rows    int
+	batches chan batch
+	stop    chan struct{}
+	ticking sync.WaitGroup // the periodic flusher
+	done    sync.WaitGroup // the sender
+
+	stats Stats
+}
+
+// batch is a body of JSONEachRow lines ready to send
+type batch struct {
+	body []byte
+	rows int
+}
+
+// Stats counts what a Writer inserted
+type Stats struct {
+	Rows   int   // rows inserted
+	Failed int   // rows in batches that failed
+	Err    error // first failure
+}
+
+// NewWriter returns a started writer inserting into table
+func NewWriter(endpoint, table, username, password string, batchSize int, flushEvery time.Duration) *Writer {
+	w := &Writer{
+		Endpoint:   strings.TrimRight(endpoint, "/"),
+		Table:      table,
+		Username:   username,
+		Password:   password,
+		BatchSize:  batchSize,
+		FlushEvery: flushEvery,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		batches:    make(chan batch, 4),
+		stop:       make(chan struct{}),
+	}
+
+	w.done.Add(1)
+	go func() {
+		defer w.done.Done()
+		for b := range w.batches {
+			w.send(b)
+		}
+	}()
+	w.ticking.Add(1)
+	go func() {
+		defer w.ticking.Done()
+		ticker := time.NewTicker(w.FlushEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.flush()
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+	return w
+}
+
+// Add queues a row, any value that marshals to a JSON object whose keys
+// are the table's columns. It blocks only while earlier batches back up.
+func (w *Writer) Add(row any) error {
+	line, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.buf.Write(line)
+	w.buf.WriteByte('\n')
+	w.rows++
+	full := w.rows >= w.BatchSize
+	w.mu.Unlock()
+	if full {
+		w.flush()
+	}
+	return nil
+}
+
+// flush hands the rows queued so far to the sender
+func (w *Writer) flush() {
+	w.mu.Lock()
+	if w.rows == 0 {
+		w.mu.Unlock()
+		return
+	}
+	b := batch{body: bytes.Clone(w.buf.Bytes()), rows: w.rows}
+	w.buf.Reset()
+	w.rows = 0
+	w.mu.Unlock()
+	w.batches <- b
+}
+
+// Close sends the remaining rows, waits for every batch and returns what
+// was inserted
+func (w *Writer) Close() Stats {
+	// A periodic flush may be sending; it must finish before batches closes
+	close(w.stop)
+	w.ticking.Wait()
+	w.flush()
+	close(w.batches)
+	w.done.Wait()
+	return w.stats
+}
+
+// send inserts one batch
+func (w *Writer) send(b batch) {
+	err := w.insert(b.body)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil {
+		w.stats.Failed += b.rows
+		if w.stats.Err == nil {
+			w.stats.Err = err
+		}
+		return
+	}
+	w.stats.Rows += b.rows
+}
+
+func (w *Writer) insert(body []byte) error {
+	query := url.Values{"query": {"INSERT INTO " + w.Table + " FORMAT JSONEachRow"}}
+	ctx, cancel := context.WithTimeout(context.Background(), w.HTTPClient.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.Endpoint+"/?"+query.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if w.Username != "" {
+		req.Header.Set("X-ClickHouse-User", w.Username)
+		req.Header.Set("X-ClickHouse-Key", w.Password)
+	}
+	resp, err := w.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("insert into %s: %s: %s", w.Table, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -4,22 +4,80 @@ import (
 	"bufio"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/internal/credentials"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+	"github.com/lazarkap/buzzbench.io/pkg/results"
 )
 
 // Config holds the application configuration
 type Config struct {
+	// Subcommand selected by the first CLI argument (default "run")
+	Command string
+	// Positional arguments left after flag parsing
+	Args []string
+
 	// API mode
 	APIKey     string
 	BaseURL    string
 	SingleTest bool
 	TestID     string
+	// Where APIKey came from: "flag", "environment", "keychain" or "embedded"
+	APIKeySource string
+	// Platform scoping for accounts with several projects or workspaces
+	Project   string
+	Workspace string
+	// Server-side filters for the tests fetched from the API
+	Env  string
+	Tags string
+	// key=value changes applied to every loaded test (-override, repeatable)
+	Overrides stringList
+	// Result submission: default endpoint for results, or none at all,
+	// files to attach to each submitted result and key=value labels stored
+	// on every result (-label, repeatable)
+	SubmitURL string
+	NoSubmit  bool
+	SubmitAllow string
+	Artifacts string
+	Labels    stringList
+	// Shared secret submitted results are signed with (-signing-secret)
+	SigningSecret string
+	// Fetched tests are cached: -cached runs the cache when the API can't be
+	// reached, -offline always runs it and never calls the API
+	Cached  bool
+	Offline bool
+
+	// Login
+	Email string
+
+	// Self-update
+	CheckOnly bool
 
 	// Output
 	Verbose     bool
 	OutputJSON  bool
 	JSONOutFile string
+	Samples     int
+	OutlierPct  float64
+	Redact      string
+	Pprof       string
+	Interval    time.Duration
+	MetricsOut  string
+	RequestLog  string
+	RotateMB    int
+	RotateKeep  int
+	RotateGzip  bool
+	SamplesDB   string
+	ColorSuccess string
+	ColorP95     string
+	ColorRPS     string
+	Charts       string
+	ChartFormat  string
 
 	// Local flag mode (-url ...)
 	LocalURL    string
@@ -30,14 +88,138 @@ type Config struct {
 	LocalBody   string
 	LocalAuth   string
 	LocalName   string
+	LocalHost   string
+	LocalSNI    string
+	LocalMode   string
+	LocalBW     string
+	LocalDelay  int
+	LocalJitter int
+	LocalRate   float64
+	LocalScript string
+	LocalLuaAssert string
+	LocalCacheBust string
+	LocalOrigin    string
+	LocalBackoff   bool
+	LocalRetryAfter int
+
+	// Local flag mode Windows integrated authentication
+	LocalAuthScheme   string
+	LocalAuthUser     string
+	LocalAuthPassword string
+
+	// Local flag mode connection pool tuning
+	LocalNoKeepAlive  bool
+	LocalMaxIdleConns int
+	LocalIdleTimeout  int
+	LocalMaxConns     int
+
+	// Local flag mode per-phase timeouts, in ms
+	LocalDialTimeout   int
+	LocalTLSTimeout    int
+	LocalHeaderTimeout int
+
+	// Default correlation headers applied to tests that don't set their own
+	RequestIDHeader  string
+	ResponseIDHeader string
+
+	// Network defaults applied to tests that don't set their own
+	ForceIPv4 bool
+	ForceIPv6 bool
+	LocalAddr string
 
 	// Local config-file mode (-config ...)
 	ConfigFile string
+
+	// Run modifiers
+	Sweep       string
+	Encodings   string
+	Accept      stringList
+	AcceptLang  stringList
+	Repeat      int
+	RaiseNoFile bool
+	Speed       float64
+	Users       int
+	Chaos       string
+	ChaosDelay  int
+	UserAgents  stringList
+
+	// Capacity discovery
+	Capacity        bool
+	CapacityMin     float64
+	CapacityMax     float64
+	CapacityStep    int
+	MaxErrorRate    float64
+	MaxP95          float64
+
+	// Concurrency tuning: the p95 to hold (enables it), the highest
+	// concurrency to try and the seconds per step
+	TargetP95 float64
+	TuneMax   int
+	TuneStep  int
+
+	// Error budget controller: the rolling error rate to stay under
+	// (enables it), the first window's rate and the seconds per window and
+	// in all
+	ErrorBudget    float64
+	BudgetStart    float64
+	BudgetWindow   int
+	BudgetDuration int
+
+	// Schedule mode
+	CronExpr string
+
+	// Monitor mode
+	MonitorInterval time.Duration
+	WebhookURL      string
+
+	// Compare
+	Alpha         float64
+	MaxRegression float64
+
+	// Discover
+	MaxURLs    int
+	CrawlDepth int
+
+	// Import
+	LogFormat string
+	RateScale float64
+
+	// Mockserver
+	MockListen    string
+	MockLatency   int
+	MockJitter    int
+	MockErrorRate float64
+	MockSize      int
+	MockEndpoints string
+}
+
+// Commands lists the subcommands accepted as the first CLI argument.
+var Commands = []string{"run", "schedule", "monitor", "compare", "merge", "list", "login", "whoami", "self-update", "doctor", "discover", "import", "mockserver", "calibrate"}
+
+// isCommand reports whether name is a known subcommand.
+func isCommand(name string) bool {
+	for _, cmd := range Commands {
+		if cmd == name {
+			return true
+		}
+	}
+	return false
 }
 
 // IsLocalMode returns true when no BuzzBench API calls should be made.
 func (c *Config) IsLocalMode() bool {
-	return c.LocalURL != "" || c.ConfigFile != ""
+	return c.LocalURL != "" || c.ConfigFile != "" || c.Offline
+}
+
+// offlineCommands never talk to the BuzzBench API
+var offlineCommands = map[string]bool{"compare": true, "merge": true, "discover": true, "import": true, "mockserver": true, "calibrate": true}
+
+// keylessCommands call the BuzzBench API but work without an API key
+var keylessCommands = map[string]bool{"login": true, "self-update": true, "doctor": true}
+
+// UsesAPI reports whether the selected command will call the BuzzBench API.
+func (c *Config) UsesAPI() bool {
+	return !offlineCommands[c.Command] && !c.IsLocalMode()
 }
 
 // DefaultBaseURL is the default API endpoint
@@ -46,22 +228,39 @@ const DefaultBaseURL = "https://buzzbench.io/api"
 // EmbeddedApiKey can be set at compile time using -ldflags
 var EmbeddedApiKey string
 
+// Version is the release version, set at compile time using -ldflags
+var Version = "dev"
+
+// UpdatePublicKey is the base64 ed25519 key releases are signed
+// with, set at compile time using -ldflags. Without it self-update refuses to run.
+var UpdatePublicKey string
+
 // New creates a new configuration with defaults loaded from .env / environment.
 func New() *Config {
 	if EmbeddedApiKey != "" {
 		return &Config{
-			APIKey:  EmbeddedApiKey,
-			BaseURL: DefaultBaseURL,
+			APIKey:       EmbeddedApiKey,
+			APIKeySource: "embedded",
+			BaseURL:      DefaultBaseURL,
 		}
 	}
 
 	loadEnvFile(".env")
 
 	cfg := &Config{
-		BaseURL: getEnv("BUZZBENCH_API_URL", DefaultBaseURL),
-		APIKey:  getEnv("BUZZBENCH_API_KEY", ""),
+		BaseURL:   getEnv("BUZZBENCH_API_URL", DefaultBaseURL),
+		APIKey:    getEnv("BUZZBENCH_API_KEY", ""),
+		Project:   getEnv("BUZZBENCH_PROJECT", ""),
+		Workspace: getEnv("BUZZBENCH_WORKSPACE", ""),
+
+		SigningSecret: getEnv("BUZZBENCH_SIGNING_SECRET", ""),
+
+		LocalAuthPassword: getEnv("BUZZBENCH_AUTH_PASSWORD", ""),
 	}
 	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.APIKey != "" {
+		cfg.APIKeySource = "environment"
+	}
 
 	return cfg
 }
@@ -72,7 +271,29 @@ func (c *Config) ParseFlags() {
 		fmt.Fprintf(os.Stderr, `BuzzBench - API Performance Testing Tool
 
 USAGE:
-  buzzbench [FLAGS]
+  buzzbench [COMMAND] [FLAGS]
+
+COMMANDS:
+  run        Run tests once (default)
+  schedule   Keep running and trigger runs on a cron expression (requires -cron)
+  monitor    Probe each test with a single request at a fixed interval, indefinitely
+  compare    Compare two result files: buzzbench compare [FLAGS] baseline.json candidate.json
+  merge      Combine the result files of one run made from several machines:
+             buzzbench merge [FLAGS] a.json b.json ... -o merged.json
+  list       List the project's tests with their IDs (-json for machine-readable output)
+  login      Exchange account credentials for an API key and store it in the OS keychain
+  whoami     Show the account and project of the active API key
+  self-update
+             Download, verify and install the latest release in place of this binary
+  doctor     Check API access, target DNS, clock skew and local limits before a big run
+  discover   Print a multi-endpoint test config for a site from its sitemap or a shallow crawl:
+             buzzbench discover [FLAGS] https://example.com > site.json
+  import     Print a test config replaying an nginx / Apache, CloudFront or ALB access log:
+             buzzbench import [FLAGS] access.log > replay.json
+  mockserver Serve a mock target with configurable latency, error rate and payload size,
+             for demos, calibration and integration tests
+  calibrate  Measure this machine's maximum local throughput, the latency the generator
+             adds and scheduler jitter against an in-process target
 
 MODES:
 
@@ -95,31 +316,230 @@ MODES:
        buzzbench
        buzzbench -test -id <test-id>
 
+  4. Schedule mode
+       Stay alive and run the selected tests on a cron schedule.
+
+       buzzbench schedule -cron "0 2 * * *"
+       buzzbench schedule -cron "*/15 * * * *" -config tests.json
+
+  5. Monitor mode
+       Send one request per test every interval and report availability and latency.
+
+       buzzbench monitor -every 30s
+       buzzbench monitor -every 1m -config tests.json -webhook https://hooks.example.com/probe
+
 FLAGS:
 
   Local test flags:
     -url string        Target URL to test (enables local flag mode)
     -name string       Label shown in the test summary  (default "Quick Test")
-    -method string     HTTP method: GET POST PUT DELETE PATCH  (default "GET")
+    -method string     HTTP method, e.g. GET POST PUT PATCH DELETE HEAD OPTIONS, or any
+                       custom method such as PURGE  (default "GET")
     -requests int      Total number of requests to send  (default 100)
     -concurrency int   Number of concurrent workers  (default 10)
     -timeout int       Per-request timeout in seconds  (default 30)
     -body string       Request body (for POST / PUT / PATCH)
     -auth string       Authorization header value
+    -host string       Override the Host header sent to the target
+    -sni string        Override the TLS server name (SNI) used for the handshake
+    -mode string       Benchmark mode: "connect" opens a new TCP+TLS connection
+                       per request and reports connect / handshake latency;
+                       "handshake" performs only the TCP connect and TLS handshake;
+                       "cache" revalidates responses with If-None-Match /
+                       If-Modified-Since and reports the 304 hit ratio;
+                       "preflight" sends the CORS preflight for each request instead
+                       and fails responses that don't allow it (needs -origin);
+                       plugin protocols (e.g. "tcp") add their own modes
+    -bandwidth string  Per-connection bandwidth cap: modem, 2g, 3g, 4g, or e.g. 512kbps / 2mbps
+    -delay int         Client-side delay in ms injected before each request
+    -jitter int        Random ± variation in ms applied to -delay
+    -rate float        Start requests at this fixed rate per second (open model);
+                       -concurrency then caps requests in flight
+    -script string     JavaScript file with setup / beforeRequest / afterResponse hooks
+    -lua-assert string Lua expression every response must satisfy,
+                       e.g. 'status == 200 and duration_ms < 250'
+    -origin string     Origin header sent with every request, as from a browser on that site
+    -cache-bust string "unique" appends a unique _bb query parameter to every request
+                       to bypass intermediary caches; "reuse" expands each URL's
+                       variables once so every request repeats it (cache-warm)
+    -backoff           Honor rate limiting: on a 429 or Retry-After response, slow
+                       requests to that host down and pause for Retry-After
+    -retry-after int   Retry a 429 or 503 response up to N times, each after its
+                       Retry-After period (1s when missing); retries are reported
+    -auth-scheme string
+                       Windows integrated authentication: "negotiate" (Kerberos via
+                       SPNEGO) or "ntlm"; the password comes from BUZZBENCH_AUTH_PASSWORD,
+                       and without one negotiate uses the kinit ticket cache
+    -auth-user string  User for -auth-scheme, e.g. alice@CORP.EXAMPLE.COM or CORP\alice
+    -no-keepalive      Open a new connection for every request
+    -max-idle-conns int
+                       Idle connections kept per host  (default: Go default of 2)
+    -idle-timeout int  Seconds an idle connection is kept  (default: Go default of 90)
+    -max-conns int     Maximum connections per host, 0 for unlimited
+    -dial-timeout int  Milliseconds to establish a TCP connection  (default: 30000)
+    -tls-timeout int   Milliseconds for the TLS handshake  (default: 10000)
+    -header-timeout int
+                       Milliseconds from sending a request to its response headers;
+                       -timeout still bounds the whole request, body included
+    -request-id-header string
+                       Header carrying a unique ID per request; "" disables  (default "X-Request-ID")
+    -response-id-header string
+                       Response header recorded on failed requests, e.g. X-Amzn-RequestId
+
+  Network flags:
+    -ipv4              Connect over IPv4 only
+    -ipv6              Connect over IPv6 only
+    -local-addr string Bind outgoing connections to this source IP; a comma-separated
+                       list or CIDR range makes connections rotate through the pool
 
   Config-file flag:
     -config string     Path to a JSON test config file
 
   Output flags:
-    -out string        Save results as JSON to this file
+    -out, -o string    Save results as JSON to this file
     -json              Print results as JSON to stdout
     -verbose           Enable verbose logging
+    -samples int       Keep up to N raw latency samples per result (needed by compare)
+    -outlier-percentile float
+                       Latencies above this percentile count as outliers for the
+                       trimmed / winsorized means; 0 disables  (default 99)
+    -redact string     Extra comma-separated query parameter names whose values are
+                       masked in logs, JSON output and submitted results
+    -pprof string      Serve Go profiling endpoints on this port or a loopback host:port
+    -interval duration Print a one-line summary of a running test this often: requests
+                       done, and RPS, p95 and error rate over the last interval; 0 disables
+    -metrics-out string
+                       Append a JSON line per interval (-interval, or every second) with
+                       the running test's throughput, latency and errors to this file
+    -requests-log string
+                       Append every request (time, latency, status, error) as a JSON
+                       line to this file
+    -rotate-mb int     Rotate the request log once it reaches this many MB; 0 never rotates
+    -rotate-keep int   Keep this many rotated request logs, deleting the oldest; 0 keeps all
+    -rotate-gzip       Gzip rotated request logs
+    -samples-sqlite string
+                       Add every request (samples table) and test run (runs table) to
+                       this SQLite database
+    -color-success string
+                       Success rate in %% colored green at or above, then amber at or
+                       above: good,warn  (default "99,95")
+    -color-p95 string  p95 in ms colored green at or below, then amber at or below:
+                       good,warn  (default "100,1000")
+    -color-rps string  Requests per second colored green at or above, then amber at
+                       or above: good,warn  (default: uncolored)
+                       Colors are off when stdout isn't a terminal or NO_COLOR is set
+    -charts string     Save a latency distribution chart (needs -samples) and a
+                       throughput / latency timeline chart of each test in this directory
+    -chart-format string
+                       Chart image format: png or svg  (default "png")
 
   API flags:
     -api-key string    BuzzBench API key  (env: BUZZBENCH_API_KEY)
     -base-url string   BuzzBench API base URL  (env: BUZZBENCH_API_URL)
+    -project string    Project to use when the account has several  (env: BUZZBENCH_PROJECT)
+    -workspace string  Workspace to use when the account has several  (env: BUZZBENCH_WORKSPACE)
+    -env string        Only fetch tests for this environment, e.g. staging
+    -tags string       Only fetch tests carrying all of these comma-separated tags
+    -override key=value
+                       Change a fetched test before it runs; repeatable. Keys: requests,
+                       concurrency, timeout, rate, url, host (e.g. -override host=localhost:8080)
     -test              Run a single API test by ID (requires -id)
     -id string         Test ID to run
+    -email string      Account email for login (password is prompted, or BUZZBENCH_PASSWORD)
+    -submit-url string Submit results to this API base URL unless a test routes them itself
+    -no-submit         Run API tests without submitting any results
+    -submit-allow string
+                       Comma-separated API hosts a fetched test's submit block may send
+                       its result to, besides -base-url and -submit-url
+    -artifact string   Comma-separated files (e.g. HTML / CSV reports) attached to each
+                       submitted result and linked from the dashboard
+    -label key=value   Store a label on every result, e.g. -label release=2.4.1, so runs
+                       can be filtered by it on the dashboard; repeatable
+    -signing-secret string
+                       Sign submitted results with HMAC-SHA256 using this shared secret,
+                       so the platform can verify them  (env: BUZZBENCH_SIGNING_SECRET)
+    -cached            Run the tests cached by the last fetch when the API can't be reached
+    -offline           Run the cached tests without calling the API; nothing is submitted
+
+  Run flags:
+    -sweep string      Run each test once per concurrency level and print a comparison,
+                       e.g. -sweep 1,10,50,100,200
+    -encodings string  Run each test once per Accept-Encoding and compare response sizes
+                       and latency, e.g. -encodings identity,gzip,br
+    -accept string     Run each test once per Accept header (repeatable) and compare
+                       the variants and the representations served
+    -accept-language string
+                       Same for Accept-Language; combined with -accept, every pair is run
+    -repeat int        Run each test N times and report mean, stddev and 95%% CI  (default 1)
+    -raise-nofile      Raise the soft open-file limit when concurrency needs more descriptors
+    -chaos string      Inject client-side faults into a percentage of requests, e.g.
+                       drop=2,delay=5,truncate=1; tests with their own chaos keep it
+    -chaos-delay int   Milliseconds a request delayed by -chaos stalls  (default 1000)
+    -user-agent string User-Agent to send, or a preset: chrome, edge, firefox, safari,
+                       chrome-android, safari-ios, googlebot, curl, or the weighted mixes
+                       browsers, desktop and mobile; repeat it to rotate per request.
+                       Tests with their own user_agents keep them
+    -speed float       Replay recorded requests at their original gaps, N times as fast
+                       (e.g. 5 for 5x the recorded traffic); also honoured by import
+    -users int         Replay recorded requests as N virtual users, each sending the
+                       whole recording; also honoured by import
+    -capacity          Search for the highest arrival rate that stays within thresholds
+    -capacity-min float
+                       Lowest rate to probe, in RPS  (default 10)
+    -capacity-max float
+                       Highest rate to probe, in RPS  (default 10000)
+    -capacity-step int Seconds per capacity probe  (default 10)
+    -max-error-rate float
+                       Highest acceptable error rate in percent  (default 1)
+    -max-p95 float     Highest acceptable p95 latency in ms  (default 500)
+    -target-p95 float  Tune concurrency to hold this p95 latency in ms and report the
+                       throughput reached there; errors stay under -max-error-rate
+    -tune-max int      Highest concurrency to try when tuning  (default 1000)
+    -tune-step int     Seconds per tuning step  (default 10)
+    -error-budget float
+                       Drive the arrival rate as high as a rolling error rate under this
+                       percentage allows, and report the equilibrium throughput
+    -budget-start float
+                       Rate of the first error budget window, in RPS  (default 10)
+    -budget-window int Seconds per error budget window  (default 5)
+    -budget-duration int
+                       Seconds the error budget controller runs for  (default 60)
+
+  Schedule flags:
+    -cron string       Cron expression for schedule mode, e.g. "0 2 * * *" or "@hourly"
+
+  Monitor flags:
+    -every duration    Probe interval for monitor mode  (default 30s)
+    -webhook string    POST each probe result as JSON to this URL
+
+  Self-update flags:
+    -check             Only report whether a newer release is available
+
+  Discover flags:
+    -max-urls int      Most endpoints to include  (default 100)
+    -crawl-depth int   Link levels followed when the site has no sitemap  (default 1)
+
+  Import flags:
+    -log-format string Access log format: combined, cloudfront or alb  (default: detected)
+    -rate-scale float  Replay at this multiple of the log's average rate; 0 sends
+                       as fast as -concurrency allows  (default 1)
+    -url string        Replay against this target instead of the host in the log
+
+  Mockserver flags:
+    -listen string     Address to serve on  (default "127.0.0.1:8080")
+    -latency int       Response latency in ms
+    -latency-jitter int
+                       Random ± variation in ms applied to -latency
+    -error-rate float  Percentage of requests answered with a 500
+    -size int          Response body size in bytes; calibrate's request body size  (default 128)
+    -endpoints string  JSON file describing several endpoints; replaces the flags above
+
+  Compare flags:
+    -alpha float       Significance level for the Mann-Whitney U test  (default 0.05)
+    -max-regression float
+                       Exit with status 1 when p95 rises by more than this percentage
+                       and the difference is significant (0 disables the gate); also
+                       applies to API runs against each test's previous run
 
 `)
 	}
@@ -127,37 +547,185 @@ FLAGS:
 	// API flags
 	flag.StringVar(&c.APIKey,     "api-key",  c.APIKey,  "API key for BuzzBench (env: BUZZBENCH_API_KEY)")
 	flag.StringVar(&c.BaseURL,    "base-url", c.BaseURL, "Base URL for the BuzzBench API (env: BUZZBENCH_API_URL)")
+	flag.StringVar(&c.Project,    "project",   c.Project,   "Project to use (env: BUZZBENCH_PROJECT)")
+	flag.StringVar(&c.Workspace,  "workspace", c.Workspace, "Workspace to use (env: BUZZBENCH_WORKSPACE)")
+	flag.StringVar(&c.Env,        "env",      "",        "Only fetch tests for this environment")
+	flag.StringVar(&c.Tags,       "tags",     "",        "Only fetch tests with all of these comma-separated tags")
+	flag.Var      (&c.Overrides,  "override",                "Override a test parameter, key=value (repeatable)")
 	flag.BoolVar  (&c.SingleTest, "test",     false,     "Run a single test by ID (API mode)")
 	flag.StringVar(&c.TestID,     "id",       "",        "Test ID to run (requires -test)")
+	flag.StringVar(&c.Email,      "email",    "",        "Account email for login")
+	flag.StringVar(&c.SubmitURL,  "submit-url", "",      "API base URL results are submitted to")
+	flag.BoolVar  (&c.NoSubmit,   "no-submit",  false,   "Don't submit results")
+	flag.StringVar(&c.SubmitAllow, "submit-allow", "",   "API hosts a test's submit block may route results to")
+	flag.StringVar(&c.Artifacts,  "artifact",   "",      "Comma-separated files to attach to submitted results")
+	flag.Var      (&c.Labels,     "label",                   "Label stored on every result, key=value (repeatable)")
+	flag.StringVar(&c.SigningSecret, "signing-secret", c.SigningSecret, "Sign submitted results with this shared secret (env: BUZZBENCH_SIGNING_SECRET)")
+	flag.BoolVar  (&c.Cached,     "cached",     false,   "Run cached tests when the API can't be reached")
+	flag.BoolVar  (&c.Offline,    "offline",    false,   "Run cached tests without calling the API")
 
 	// Output flags
 	flag.BoolVar  (&c.Verbose,     "verbose", false, "Enable verbose output")
 	flag.BoolVar  (&c.OutputJSON,  "json",    false, "Print results as JSON to stdout")
 	flag.StringVar(&c.JSONOutFile, "out",     "",    "Save results as JSON to file")
+	flag.StringVar(&c.JSONOutFile, "o",       "",    "Shorthand for -out")
+	flag.IntVar   (&c.Samples,     "samples", 0,     "Keep up to N raw latency samples per result")
+	flag.Float64Var(&c.OutlierPct, "outlier-percentile", 99, "Latency percentile above which samples are outliers")
+	flag.StringVar(&c.Redact,      "redact",  "",    "Extra query parameter names to mask in output")
+	flag.StringVar(&c.Pprof,       "pprof",   "",    "Serve pprof endpoints on this port or a loopback host:port")
+	flag.DurationVar(&c.Interval,  "interval", 0,    "Print a rolling summary of running tests this often")
+	flag.StringVar(&c.MetricsOut,  "metrics-out", "", "Write JSON-line snapshots of running tests to this file")
+	flag.StringVar(&c.RequestLog,  "requests-log", "", "Write every request as a JSON line to this file")
+	flag.IntVar   (&c.RotateMB,    "rotate-mb",   0,  "Rotate the request log at this size in MB")
+	flag.IntVar   (&c.RotateKeep,  "rotate-keep", 0,  "Rotated request logs to keep")
+	flag.BoolVar  (&c.RotateGzip,  "rotate-gzip", false, "Gzip rotated request logs")
+	flag.StringVar(&c.SamplesDB,   "samples-sqlite", "", "Write every request and run to this SQLite database")
+	flag.StringVar(&c.ColorSuccess, "color-success", "", "Success rate colored green, then amber, at or above: good,warn")
+	flag.StringVar(&c.ColorP95,     "color-p95",     "", "p95 (ms) colored green, then amber, at or below: good,warn")
+	flag.StringVar(&c.ColorRPS,     "color-rps",     "", "Requests per second colored green, then amber, at or above: good,warn")
+	flag.StringVar(&c.Charts,       "charts",        "", "Save latency and timeline charts of each test in this directory")
+	flag.StringVar(&c.ChartFormat,  "chart-format",  "png", "Chart image format: png or svg")
 
 	// Local flag mode
 	flag.StringVar(&c.LocalURL,    "url",         "",           "Target URL (enables local flag mode)")
 	flag.StringVar(&c.LocalName,   "name",        "Quick Test", "Test label")
-	flag.StringVar(&c.LocalMethod, "method",      "GET",        "HTTP method")
+	flag.StringVar(&c.LocalMethod, "method",      "GET",        "HTTP method, including HEAD, OPTIONS and custom ones")
 	flag.IntVar   (&c.LocalReqs,   "requests",    100,          "Number of requests")
 	flag.IntVar   (&c.LocalConc,   "concurrency", 10,           "Concurrent workers")
 	flag.IntVar   (&c.LocalTO,     "timeout",     30,           "Per-request timeout (seconds)")
 	flag.StringVar(&c.LocalBody,   "body",        "",           "Request body JSON")
 	flag.StringVar(&c.LocalAuth,   "auth",        "",           "Authorization header value")
+	flag.StringVar(&c.LocalHost,   "host",        "",           "Host header override")
+	flag.StringVar(&c.LocalSNI,    "sni",         "",           "TLS server name (SNI) override")
+	flag.StringVar(&c.LocalMode,   "mode",        "",           "Benchmark mode (connect, handshake, cache, preflight)")
+	flag.StringVar(&c.LocalBW,     "bandwidth",   "",           "Per-connection bandwidth cap (modem, 2g, 3g, 4g, 512kbps, 2mbps)")
+	flag.IntVar   (&c.LocalDelay,  "delay",       0,            "Client-side delay in ms before each request")
+	flag.IntVar   (&c.LocalJitter, "jitter",      0,            "Random ± variation in ms applied to -delay")
+	flag.Float64Var(&c.LocalRate,  "rate",        0,            "Fixed arrival rate in requests per second")
+	flag.StringVar(&c.LocalScript, "script",      "",           "JavaScript hooks file")
+	flag.StringVar(&c.LocalLuaAssert, "lua-assert", "",         "Lua assertion checked against every response")
+	flag.StringVar(&c.LocalCacheBust, "cache-bust", "",         "Cache busting: unique or reuse")
+	flag.StringVar(&c.LocalOrigin,    "origin",     "",         "Origin header to send; required by -mode preflight")
+	flag.BoolVar  (&c.LocalBackoff,   "backoff",    false,      "Slow down per host on 429 or Retry-After responses")
+	flag.IntVar   (&c.LocalRetryAfter, "retry-after", 0,         "Retry 429 and 503 responses up to N times after their Retry-After")
+	flag.StringVar(&c.LocalAuthScheme, "auth-scheme", "",        "Windows integrated authentication: negotiate (Kerberos) or ntlm")
+	flag.StringVar(&c.LocalAuthUser,   "auth-user",   "",        "User for -auth-scheme: user@REALM or DOMAIN\\user")
+
+	flag.BoolVar(&c.LocalNoKeepAlive,  "no-keepalive",   false, "Open a new connection for every request")
+	flag.IntVar (&c.LocalMaxIdleConns, "max-idle-conns", 0,     "Idle connections kept per host")
+	flag.IntVar (&c.LocalIdleTimeout,  "idle-timeout",   0,     "Seconds an idle connection is kept")
+	flag.IntVar (&c.LocalMaxConns,     "max-conns",      0,     "Maximum connections per host")
+
+	flag.IntVar(&c.LocalDialTimeout,   "dial-timeout",   0, "Milliseconds to establish a TCP connection")
+	flag.IntVar(&c.LocalTLSTimeout,    "tls-timeout",    0, "Milliseconds for the TLS handshake")
+	flag.IntVar(&c.LocalHeaderTimeout, "header-timeout", 0, "Milliseconds from sending a request to its response headers")
+
+	flag.StringVar(&c.RequestIDHeader,  "request-id-header",  "X-Request-ID", "Header carrying a unique ID per request (empty disables)")
+	flag.StringVar(&c.ResponseIDHeader, "response-id-header", "",             "Response header recorded on failed requests")
+
+	// Network
+	flag.BoolVar  (&c.ForceIPv4, "ipv4",       false, "Connect over IPv4 only")
+	flag.BoolVar  (&c.ForceIPv6, "ipv6",       false, "Connect over IPv6 only")
+	flag.StringVar(&c.LocalAddr, "local-addr", "",    "Source IP, comma-separated IPs or CIDR range to bind connections to")
 
 	// Local config-file mode
 	flag.StringVar(&c.ConfigFile, "config", "", "Path to JSON test config file")
 
-	flag.Parse()
+	// Run modifiers
+	flag.StringVar(&c.Sweep,  "sweep",  "", "Comma-separated concurrency levels to sweep")
+	flag.StringVar(&c.Encodings, "encodings", "", "Comma-separated Accept-Encoding values to compare")
+	flag.Var(&c.Accept,     "accept",          "Accept header variant to compare (repeatable)")
+	flag.Var(&c.AcceptLang, "accept-language", "Accept-Language header variant to compare (repeatable)")
+	flag.IntVar   (&c.Repeat, "repeat", 1,  "Run each test N times and report statistics across runs")
+	flag.BoolVar  (&c.RaiseNoFile, "raise-nofile", false, "Raise the soft open-file limit when needed")
+	flag.Float64Var(&c.Speed,      "speed",        0,     "Replay recorded requests at their original gaps, N times as fast")
+	flag.IntVar    (&c.Users,      "users",        0,     "Replay recorded requests as N virtual users")
+	flag.StringVar (&c.Chaos,      "chaos",        "",    "Client-side faults to inject, e.g. drop=2,delay=5,truncate=1 (percent of requests)")
+	flag.IntVar    (&c.ChaosDelay, "chaos-delay",  1000,  "Milliseconds a request delayed by -chaos stalls")
+	flag.Var       (&c.UserAgents, "user-agent",                 "User-Agent or preset to rotate through per request (repeatable)")
+
+	// Capacity discovery
+	flag.BoolVar   (&c.Capacity,     "capacity",       false, "Search for the maximum sustainable arrival rate")
+	flag.Float64Var(&c.CapacityMin,  "capacity-min",   10,    "Lowest rate to probe (RPS)")
+	flag.Float64Var(&c.CapacityMax,  "capacity-max",   10000, "Highest rate to probe (RPS)")
+	flag.IntVar    (&c.CapacityStep, "capacity-step",  10,    "Seconds per capacity probe")
+	flag.Float64Var(&c.MaxErrorRate, "max-error-rate", 1,     "Highest acceptable error rate (%)")
+	flag.Float64Var(&c.MaxP95,       "max-p95",        500,   "Highest acceptable p95 latency (ms)")
+	flag.Float64Var(&c.TargetP95,    "target-p95",     0,     "Tune concurrency to hold this p95 latency (ms)")
+	flag.IntVar    (&c.TuneMax,      "tune-max",       1000,  "Highest concurrency to try when tuning")
+	flag.IntVar    (&c.TuneStep,     "tune-step",      10,    "Seconds per tuning step")
+	flag.Float64Var(&c.ErrorBudget,    "error-budget",    0,  "Drive the rate as high as a rolling error rate under this percentage allows")
+	flag.Float64Var(&c.BudgetStart,    "budget-start",    10, "Rate of the first error budget window (RPS)")
+	flag.IntVar    (&c.BudgetWindow,   "budget-window",   5,  "Seconds per error budget window")
+	flag.IntVar    (&c.BudgetDuration, "budget-duration", 60, "Seconds the error budget controller runs for")
+
+	// Schedule mode
+	flag.StringVar(&c.CronExpr, "cron", "", "Cron expression for schedule mode")
+
+	// Monitor mode
+	flag.DurationVar(&c.MonitorInterval, "every",   30*time.Second, "Probe interval for monitor mode")
+	flag.StringVar  (&c.WebhookURL,      "webhook", "",             "POST each probe result as JSON to this URL")
+
+	// Self-update
+	flag.BoolVar(&c.CheckOnly, "check", false, "Only check for a newer release")
+
+	// Compare
+	flag.Float64Var(&c.Alpha,         "alpha",          0.05, "Significance level for compare")
+	flag.Float64Var(&c.MaxRegression, "max-regression", 0,    "Fail when p95 regresses by more than this percentage")
+
+	// Discover
+	flag.IntVar(&c.MaxURLs,    "max-urls",    100, "Most endpoints discover includes")
+	flag.IntVar(&c.CrawlDepth, "crawl-depth", 1,   "Link levels discover follows without a sitemap")
+
+	// Import
+	flag.StringVar (&c.LogFormat, "log-format", "", "Access log format for import (default: detected)")
+	flag.Float64Var(&c.RateScale, "rate-scale", 1,  "Multiple of the log's average rate to replay at")
+
+	// Mockserver
+	flag.StringVar (&c.MockListen,    "listen",         "127.0.0.1:8080", "Address the mock server listens on")
+	flag.IntVar    (&c.MockLatency,   "latency",        0,                "Mock response latency in ms")
+	flag.IntVar    (&c.MockJitter,    "latency-jitter", 0,                "Random variation in ms applied to -latency")
+	flag.Float64Var(&c.MockErrorRate, "error-rate",     0,                "Percentage of mock responses that fail")
+	flag.IntVar    (&c.MockSize,      "size",           128,              "Mock response body size in bytes")
+	flag.StringVar (&c.MockEndpoints, "endpoints",      "",               "JSON file describing the mock endpoints")
+
+	args := os.Args[1:]
+	c.Command = "run"
+	if len(args) > 0 && isCommand(args[0]) {
+		c.Command = args[0]
+		args = args[1:]
+	}
+
+	flag.CommandLine.Parse(args)
+	c.Args = flag.Args()
 
 	// -out implies -json
 	if c.JSONOutFile != "" {
 		c.OutputJSON = true
 	}
 
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "api-key" {
+			c.APIKeySource = "flag"
+		}
+	})
+
+	// Fall back to the key stored by `buzzbench login`
+	if c.UsesAPI() && c.APIKey == "" {
+		if key, err := credentials.Load(c.BaseURL); err == nil && key != "" {
+			c.APIKey = key
+			c.APIKeySource = "keychain"
+		}
+	}
+
 	// API key is only required in API mode
-	if !c.IsLocalMode() && c.APIKey == "" {
-		fmt.Fprintln(os.Stderr, "Warning: No API key provided. Set BUZZBENCH_API_KEY or use -api-key flag.")
+	if c.UsesAPI() && c.APIKey == "" && !keylessCommands[c.Command] {
+		fmt.Fprintln(os.Stderr, "Warning: No API key provided. Run buzzbench login, set BUZZBENCH_API_KEY or use -api-key flag.")
+	}
+
+	if c.ForceIPv4 && c.ForceIPv6 {
+		fmt.Fprintln(os.Stderr, "Error: -ipv4 and -ipv6 are mutually exclusive")
+		os.Exit(1)
 	}
 
 	if c.SingleTest && c.TestID == "" {
@@ -165,6 +733,375 @@ FLAGS:
 		flag.Usage()
 		os.Exit(1)
 	}
+
+	if _, err := c.SweepLevels(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if _, err := c.LabelMap(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if _, err := c.ChaosConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if _, err := c.ColorThresholds(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if c.ChartFormat != "png" && c.ChartFormat != "svg" {
+		fmt.Fprintln(os.Stderr, "Error: -chart-format must be png or svg")
+		os.Exit(1)
+	}
+
+	if c.LocalAuthScheme != "" && c.LocalAuthScheme != api.AuthNegotiate && c.LocalAuthScheme != api.AuthNTLM {
+		fmt.Fprintf(os.Stderr, "Error: -auth-scheme must be %q or %q\n", api.AuthNegotiate, api.AuthNTLM)
+		os.Exit(1)
+	}
+
+	if c.Repeat < 1 {
+		fmt.Fprintln(os.Stderr, "Error: -repeat must be at least 1")
+		os.Exit(1)
+	}
+
+	if c.Speed < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -speed must be positive")
+		os.Exit(1)
+	}
+
+	if c.Users < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -users must be positive")
+		os.Exit(1)
+	}
+
+	if c.LocalDialTimeout < 0 || c.LocalTLSTimeout < 0 || c.LocalHeaderTimeout < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -dial-timeout, -tls-timeout and -header-timeout can't be negative")
+		os.Exit(1)
+	}
+
+	if c.Capacity && c.Sweep != "" {
+		fmt.Fprintln(os.Stderr, "Error: -capacity and -sweep are mutually exclusive")
+		os.Exit(1)
+	}
+
+	if c.TargetP95 < 0 || c.TuneMax < 1 || c.TuneStep < 1 {
+		fmt.Fprintln(os.Stderr, "Error: -target-p95, -tune-max and -tune-step must be positive")
+		os.Exit(1)
+	}
+
+	if c.TargetP95 > 0 && (c.Capacity || c.Sweep != "" || c.Encodings != "" || len(c.Variants()) > 0) {
+		fmt.Fprintln(os.Stderr, "Error: -target-p95 can't be combined with -capacity, -sweep, -encodings or -accept / -accept-language")
+		os.Exit(1)
+	}
+
+	if c.ErrorBudget < 0 || c.ErrorBudget >= 100 {
+		fmt.Fprintln(os.Stderr, "Error: -error-budget must be a percentage below 100")
+		os.Exit(1)
+	}
+
+	if c.BudgetStart <= 0 || c.BudgetWindow < 1 || c.BudgetDuration < c.BudgetWindow {
+		fmt.Fprintln(os.Stderr, "Error: -budget-start and -budget-window must be positive, and -budget-duration at least one window")
+		os.Exit(1)
+	}
+
+	if c.ErrorBudget > 0 && (c.Capacity || c.TargetP95 > 0 || c.Sweep != "" || c.Encodings != "" || len(c.Variants()) > 0) {
+		fmt.Fprintln(os.Stderr, "Error: -error-budget can't be combined with -capacity, -target-p95, -sweep, -encodings or -accept / -accept-language")
+		os.Exit(1)
+	}
+
+	if c.Interval < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -interval can't be negative")
+		os.Exit(1)
+	}
+
+	if c.RotateMB < 0 || c.RotateKeep < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -rotate-mb and -rotate-keep can't be negative")
+		os.Exit(1)
+	}
+
+	if c.RequestLog == "" && (c.RotateMB > 0 || c.RotateKeep > 0 || c.RotateGzip) {
+		fmt.Fprintln(os.Stderr, "Error: -rotate-mb, -rotate-keep and -rotate-gzip require -requests-log")
+		os.Exit(1)
+	}
+
+	if c.Encodings != "" && (c.Capacity || c.Sweep != "") {
+		fmt.Fprintln(os.Stderr, "Error: -encodings can't be combined with -capacity or -sweep")
+		os.Exit(1)
+	}
+
+	if len(c.Variants()) > 0 && (c.Capacity || c.Sweep != "" || c.Encodings != "") {
+		fmt.Fprintln(os.Stderr, "Error: -accept / -accept-language can't be combined with -capacity, -sweep or -encodings")
+		os.Exit(1)
+	}
+
+	if c.Command == "compare" && len(c.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "Error: compare requires two result files: buzzbench compare [FLAGS] baseline.json candidate.json")
+		os.Exit(1)
+	}
+
+	if c.Command == "merge" && len(c.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "Error: merge requires at least two result files: buzzbench merge [FLAGS] a.json b.json ...")
+		os.Exit(1)
+	}
+
+	if c.Command == "discover" && len(c.Args) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: discover requires a site or sitemap URL: buzzbench discover [FLAGS] https://example.com")
+		os.Exit(1)
+	}
+
+	if c.Command == "import" && len(c.Args) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: import requires an access log file: buzzbench import [FLAGS] access.log")
+		os.Exit(1)
+	}
+
+	if c.Command == "schedule" && c.CronExpr == "" {
+		fmt.Fprintln(os.Stderr, "Error: schedule command requires -cron parameter")
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// IPFamily returns "4" or "6" when an address family was forced, otherwise "".
+func (c *Config) IPFamily() string {
+	switch {
+	case c.ForceIPv4:
+		return "4"
+	case c.ForceIPv6:
+		return "6"
+	}
+	return ""
+}
+
+// LocalAddrPool splits -local-addr into its comma-separated entries.
+func (c *Config) LocalAddrPool() []string {
+	var pool []string
+	for _, a := range strings.Split(c.LocalAddr, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			pool = append(pool, a)
+		}
+	}
+	return pool
+}
+
+// RedactParams splits -redact into its comma-separated parameter names.
+func (c *Config) RedactParams() []string {
+	var params []string
+	for _, p := range strings.Split(c.Redact, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+// stringList is a flag that may be given several times
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// APIHosts returns the hosts of -base-url and -submit-url, which results
+// may be submitted to with the account's own key
+func (c *Config) APIHosts() []string {
+	var hosts []string
+	for _, u := range []string{c.BaseURL, c.SubmitURL} {
+		if parsed, err := url.Parse(u); err == nil && parsed.Host != "" {
+			hosts = append(hosts, strings.ToLower(parsed.Host))
+		}
+	}
+	return hosts
+}
+
+// SubmitAllowList splits -submit-allow into the further hosts a test's
+// submit block may send its result to, with a key of its own
+func (c *Config) SubmitAllowList() []string {
+	var hosts []string
+	for _, h := range strings.Split(c.SubmitAllow, ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// TestFilter returns the server-side filter selected by -env and -tags.
+func (c *Config) TestFilter() api.TestFilter {
+	filter := api.TestFilter{Environment: c.Env}
+	for _, t := range strings.Split(c.Tags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			filter.Tags = append(filter.Tags, t)
+		}
+	}
+	return filter
+}
+
+// ArtifactPaths splits -artifact into the files to upload.
+func (c *Config) ArtifactPaths() []string {
+	var paths []string
+	for _, p := range strings.Split(c.Artifacts, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// LabelMap parses the -label flags into the labels stored on every result;
+// nil when none were given. A later label replaces an earlier one with the
+// same key.
+func (c *Config) LabelMap() (map[string]string, error) {
+	if len(c.Labels) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(c.Labels))
+	for _, l := range c.Labels {
+		key, value, ok := strings.Cut(l, "=")
+		if key = strings.TrimSpace(key); !ok || key == "" {
+			return nil, fmt.Errorf("invalid -label %q (want key=value)", l)
+		}
+		labels[key] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
+
+// ChaosConfig parses -chaos into the faults injected into every test without
+// its own chaos settings; nil when -chaos wasn't given.
+func (c *Config) ChaosConfig() (*api.ChaosConfig, error) {
+	if c.Chaos == "" {
+		return nil, nil
+	}
+	chaos := &api.ChaosConfig{DelayMs: c.ChaosDelay}
+	for _, part := range strings.Split(c.Chaos, ",") {
+		fault, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		pct, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || err != nil || pct < 0 || pct > 100 {
+			return nil, fmt.Errorf("invalid -chaos %q (want fault=percent, e.g. drop=2)", part)
+		}
+		switch strings.TrimSpace(fault) {
+		case api.FaultDrop:
+			chaos.DropPercent = pct
+		case api.FaultDelay:
+			chaos.DelayPercent = pct
+		case api.FaultTruncate:
+			chaos.TruncatePercent = pct
+		default:
+			return nil, fmt.Errorf("unknown -chaos fault %q (want drop, delay or truncate)", fault)
+		}
+	}
+	if total := chaos.DropPercent + chaos.DelayPercent + chaos.TruncatePercent; total > 100 {
+		return nil, fmt.Errorf("-chaos percentages add up to %g, more than 100", total)
+	}
+	if c.ChaosDelay < 0 {
+		return nil, fmt.Errorf("-chaos-delay can't be negative")
+	}
+	return chaos, nil
+}
+
+// ColorThresholds parses -color-success, -color-p95 and -color-rps into the
+// thresholds coloring the summary, starting from the defaults
+func (c *Config) ColorThresholds() (results.Thresholds, error) {
+	t := results.DefaultThresholds
+	for _, f := range []struct {
+		name, value, example string
+		threshold            *results.Threshold
+		higherBetter         bool
+	}{
+		{"color-success", c.ColorSuccess, "99,95", &t.SuccessRate, true},
+		{"color-p95", c.ColorP95, "100,1000", &t.P95, false},
+		{"color-rps", c.ColorRPS, "500,200", &t.RPS, true},
+	} {
+		if f.value == "" {
+			continue
+		}
+		good, warn, ok := strings.Cut(f.value, ",")
+		g, err1 := strconv.ParseFloat(strings.TrimSpace(good), 64)
+		w, err2 := strconv.ParseFloat(strings.TrimSpace(warn), 64)
+		if !ok || err1 != nil || err2 != nil || g < 0 || w < 0 {
+			return t, fmt.Errorf("invalid -%s %q (want good,warn, e.g. %s)", f.name, f.value, f.example)
+		}
+		if f.higherBetter && g < w || !f.higherBetter && g > w {
+			return t, fmt.Errorf("invalid -%s %q: the good level must be past the warn level", f.name, f.value)
+		}
+		*f.threshold = results.Threshold{Good: g, Warn: w}
+	}
+	return t, nil
+}
+
+// SweepLevels parses -sweep into its concurrency levels; nil when no sweep was requested.
+func (c *Config) SweepLevels() ([]int, error) {
+	if c.Sweep == "" {
+		return nil, nil
+	}
+	var levels []int
+	for _, part := range strings.Split(c.Sweep, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid -sweep level %q", part)
+		}
+		levels = append(levels, n)
+	}
+	return levels, nil
+}
+
+// EncodingList splits -encodings into the Accept-Encoding values to run
+// each test with; nil when no matrix was requested.
+func (c *Config) EncodingList() []string {
+	var encodings []string
+	for _, part := range strings.Split(c.Encodings, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			encodings = append(encodings, part)
+		}
+	}
+	return encodings
+}
+
+// Variant is one combination of content-negotiation headers to run a test with
+type Variant struct {
+	Label   string
+	Headers map[string]string
+}
+
+// Variants crosses the -accept and -accept-language values into the
+// variants to run each test with; nil when neither flag was given.
+func (c *Config) Variants() []Variant {
+	if len(c.Accept) == 0 && len(c.AcceptLang) == 0 {
+		return nil
+	}
+	accepts, langs := []string(c.Accept), []string(c.AcceptLang)
+	if len(accepts) == 0 {
+		accepts = []string{""}
+	}
+	if len(langs) == 0 {
+		langs = []string{""}
+	}
+
+	var variants []Variant
+	for _, accept := range accepts {
+		for _, lang := range langs {
+			v := Variant{Headers: make(map[string]string)}
+			var label []string
+			if accept != "" {
+				v.Headers["Accept"] = accept
+				label = append(label, "Accept: "+accept)
+			}
+			if lang != "" {
+				v.Headers["Accept-Language"] = lang
+				label = append(label, "Accept-Language: "+lang)
+			}
+			v.Label = strings.Join(label, ", ")
+			variants = append(variants, v)
+		}
+	}
+	return variants
 }
 
 // Validate checks if the configuration is valid for API mode.
@@ -0,0 +1,29 @@
+// Package credentials stores BuzzBench API keys in the OS keychain
+// (macOS Keychain, Windows Credential Manager or the Secret Service on Linux).
+package credentials
+
+import (
+	"errors"
+
+	"github.com/zalando/go-keyring"
+)
+
+// service is the keychain service name entries are stored under
+const service = "buzzbench"
+
+// ErrNotFound is returned when no key is stored for a base URL
+var ErrNotFound = errors.New("no stored API key")
+
+// Save stores the API key for an API base URL, replacing any previous one
+func Save(baseURL, apiKey string) error {
+	return keyring.Set(service, baseURL, apiKey)
+}
+
+// Load returns the API key stored for an API base URL
+func Load(baseURL string) (string, error) {
+	key, err := keyring.Get(service, baseURL)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrNotFound
+	}
+	return key, err
+}
@@ -0,0 +1,161 @@
+// Package discover finds the pages of a site from its sitemap or a shallow
+// crawl, for turning into a multi-endpoint test.
+package discover
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxBodySize bounds how much of a sitemap or page is read
+const maxBodySize = 10 << 20
+
+// Page is a discovered URL and its relative weight
+type Page struct {
+	URL    string
+	Weight float64
+}
+
+// sitemap is a <urlset> or a <sitemapindex>
+type sitemap struct {
+	XMLName xml.Name
+	URLs    []struct {
+		Loc      string `xml:"loc"`
+		Priority string `xml:"priority"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// Sitemap fetches a sitemap, following a sitemap index one level, and returns
+// up to limit pages weighted by their priority (default 0.5)
+func Sitemap(ctx context.Context, client *http.Client, sitemapURL string, limit int) ([]Page, error) {
+	sm, err := fetchSitemap(ctx, client, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var pages []Page
+	add := func(sm *sitemap) {
+		for _, u := range sm.URLs {
+			if len(pages) == limit {
+				return
+			}
+			weight := 0.5
+			if p, err := strconv.ParseFloat(strings.TrimSpace(u.Priority), 64); err == nil && p > 0 {
+				weight = p
+			}
+			pages = append(pages, Page{URL: strings.TrimSpace(u.Loc), Weight: weight})
+		}
+	}
+	add(sm)
+	for _, child := range sm.Sitemaps {
+		if len(pages) == limit {
+			break
+		}
+		childMap, err := fetchSitemap(ctx, client, strings.TrimSpace(child.Loc))
+		if err != nil {
+			return nil, err
+		}
+		add(childMap)
+	}
+	return pages, nil
+}
+
+func fetchSitemap(ctx context.Context, client *http.Client, sitemapURL string) (*sitemap, error) {
+	body, err := fetch(ctx, client, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+	var sm sitemap
+	if err := xml.Unmarshal(body, &sm); err != nil {
+		return nil, fmt.Errorf("parse sitemap %s: %w", sitemapURL, err)
+	}
+	if sm.XMLName.Local != "urlset" && sm.XMLName.Local != "sitemapindex" {
+		return nil, fmt.Errorf("%s is not a sitemap", sitemapURL)
+	}
+	return &sm, nil
+}
+
+// hrefRe matches the target of href attributes
+var hrefRe = regexp.MustCompile(`(?i)href\s*=\s*["']([^"'#]+)`)
+
+// Crawl fetches baseURL and follows links to pages on the same host, depth
+// levels deep. It returns up to limit pages, each weighted by how many
+// crawled pages link to it.
+func Crawl(ctx context.Context, client *http.Client, baseURL string, depth, limit int) ([]Page, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+
+	links := map[string]int{base.String(): 1}
+	order := []string{base.String()}
+	frontier := []*url.URL{base}
+	for level := 0; level < depth && len(frontier) > 0; level++ {
+		var next []*url.URL
+		for _, page := range frontier {
+			body, err := fetch(ctx, client, page.String())
+			if err != nil {
+				if page == base {
+					return nil, err
+				}
+				continue // a broken link shouldn't end the crawl
+			}
+			for _, m := range hrefRe.FindAllSubmatch(body, -1) {
+				ref, err := url.Parse(strings.TrimSpace(string(m[1])))
+				if err != nil {
+					continue
+				}
+				target := page.ResolveReference(ref)
+				if target.Host != base.Host || (target.Scheme != "http" && target.Scheme != "https") {
+					continue
+				}
+				target.Fragment = ""
+				key := target.String()
+				if links[key] == 0 {
+					if len(order) == limit {
+						continue
+					}
+					order = append(order, key)
+					next = append(next, target)
+				}
+				links[key]++
+			}
+		}
+		frontier = next
+	}
+
+	pages := make([]Page, len(order))
+	for i, u := range order {
+		pages[i] = Page{URL: u, Weight: float64(links[u])}
+	}
+	sort.SliceStable(pages, func(i, j int) bool { return pages[i].Weight > pages[j].Weight })
+	return pages, nil
+}
+
+// fetch GETs a URL and returns its body, treating non-2xx statuses as errors
+func fetch(ctx context.Context, client *http.Client, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+}
@@ -0,0 +1,185 @@
+// Package mockserver implements the target behind `buzzbench mockserver`: an
+// HTTP server whose endpoints answer with a configured latency, error rate and
+// payload size, for demos, calibrating the load generator and integration tests.
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Endpoint describes how the server answers requests for one path. A path
+// ending in "/" also matches everything below it; an empty Method matches
+// any method. ErrorRate is the percentage of requests answered with
+// ErrorStatus instead. Echo answers with the request's own body and
+// Content-Type in place of Body.
+type Endpoint struct {
+	Path        string            `json:"path"`
+	Method      string            `json:"method,omitempty"`
+	Status      int               `json:"status,omitempty"`
+	LatencyMs   int               `json:"latency_ms,omitempty"`
+	JitterMs    int               `json:"jitter_ms,omitempty"`
+	ErrorRate   float64           `json:"error_rate,omitempty"`
+	ErrorStatus int               `json:"error_status,omitempty"`
+	Size        int               `json:"size,omitempty"`
+	Body        string            `json:"body,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Echo        bool              `json:"echo,omitempty"`
+}
+
+// endpoint is an Endpoint with its defaults applied and its body rendered.
+type endpoint struct {
+	Endpoint
+	body []byte
+}
+
+// Server serves the configured endpoints. Requests matching none get a 404.
+type Server struct {
+	endpoints []endpoint
+}
+
+// Load reads a JSON array of endpoints from path.
+func Load(path string) ([]Endpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var eps []Endpoint
+	if err := json.Unmarshal(data, &eps); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return eps, nil
+}
+
+// New validates the endpoints and returns a server for them. Endpoints are
+// matched in order, so more specific paths should come first.
+func New(eps []Endpoint) (*Server, error) {
+	if len(eps) == 0 {
+		return nil, fmt.Errorf("no endpoints configured")
+	}
+	s := &Server{}
+	for i, ep := range eps {
+		if !strings.HasPrefix(ep.Path, "/") {
+			return nil, fmt.Errorf("endpoint %d: path %q must start with /", i, ep.Path)
+		}
+		if ep.LatencyMs < 0 || ep.JitterMs < 0 || ep.Size < 0 {
+			return nil, fmt.Errorf("endpoint %s: latency, jitter and size can't be negative", ep.Path)
+		}
+		if ep.ErrorRate < 0 || ep.ErrorRate > 100 {
+			return nil, fmt.Errorf("endpoint %s: error rate must be between 0 and 100, got %g", ep.Path, ep.ErrorRate)
+		}
+		ep.Method = strings.ToUpper(ep.Method)
+		if ep.Status == 0 {
+			ep.Status = http.StatusOK
+		}
+		if ep.ErrorStatus == 0 {
+			ep.ErrorStatus = http.StatusInternalServerError
+		}
+
+		body := []byte(ep.Body)
+		if ep.Body == "" && !ep.Echo {
+			body = payload(ep.Size)
+			if ep.ContentType == "" {
+				ep.ContentType = "application/json"
+			}
+		}
+		ep.Size = len(body)
+		s.endpoints = append(s.endpoints, endpoint{Endpoint: ep, body: body})
+	}
+	return s, nil
+}
+
+// Endpoints returns the endpoints in match order, with defaults applied and
+// Size set to the length of the body served.
+func (s *Server) Endpoints() []Endpoint {
+	eps := make([]Endpoint, len(s.endpoints))
+	for i, ep := range s.endpoints {
+		eps[i] = ep.Endpoint
+	}
+	return eps
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ep := s.match(r)
+	if ep == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if d := ep.latency(); d > 0 {
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			t.Stop()
+			return
+		}
+	}
+
+	if ep.ErrorRate > 0 && rand.Float64()*100 < ep.ErrorRate {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(ep.ErrorStatus)
+		fmt.Fprintf(w, `{"error":"injected failure","status":%d}`, ep.ErrorStatus)
+		return
+	}
+
+	for k, v := range ep.Headers {
+		w.Header().Set(k, v)
+	}
+	if ep.Echo {
+		if ct := r.Header.Get("Content-Type"); ct != "" {
+			w.Header().Set("Content-Type", ct)
+		}
+		w.WriteHeader(ep.Status)
+		io.Copy(w, r.Body)
+		return
+	}
+	if ep.ContentType != "" {
+		w.Header().Set("Content-Type", ep.ContentType)
+	}
+	w.WriteHeader(ep.Status)
+	if r.Method != http.MethodHead {
+		w.Write(ep.body)
+	}
+}
+
+// match returns the first endpoint serving r, or nil.
+func (s *Server) match(r *http.Request) *endpoint {
+	for i := range s.endpoints {
+		ep := &s.endpoints[i]
+		if ep.Method != "" && ep.Method != r.Method {
+			continue
+		}
+		if r.URL.Path == ep.Path || (strings.HasSuffix(ep.Path, "/") && strings.HasPrefix(r.URL.Path, ep.Path)) {
+			return ep
+		}
+	}
+	return nil
+}
+
+// latency picks this response's delay: the configured latency ± a uniformly
+// random jitter, never below zero.
+func (ep *endpoint) latency() time.Duration {
+	ms := ep.LatencyMs
+	if ep.JitterMs > 0 {
+		ms += rand.Intn(2*ep.JitterMs+1) - ep.JitterMs
+	}
+	return time.Duration(max(ms, 0)) * time.Millisecond
+}
+
+// payload returns a JSON document of exactly size bytes, or filler when size
+// is too small to hold one.
+func payload(size int) []byte {
+	const wrapper = `{"data":""}`
+	if size < len(wrapper) {
+		return []byte(strings.Repeat("x", size))
+	}
+	return []byte(`{"data":"` + strings.Repeat("x", size-len(wrapper)) + `"}`)
+}
@@ -0,0 +1,138 @@
+// Package prometheus runs PromQL range queries against a Prometheus server.
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Series is one time series returned by a range query
+type Series struct {
+	Labels map[string]string
+	Points []Point
+}
+
+// Point is a single sample of a series
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Client queries one Prometheus server
+type Client struct {
+	Endpoint    string
+	BearerToken string
+	HTTPClient  *http.Client
+}
+
+// NewClient creates a client for a Prometheus base URL such as http://prometheus:9090
+func NewClient(endpoint, bearerToken string) *Client {
+	return &Client{
+		Endpoint:    strings.TrimRight(endpoint, "/"),
+		BearerToken: bearerToken,
+		HTTPClient:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// queryResponse is the envelope of /api/v1/query_range
+type queryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][2]interface{}  `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// QueryRange evaluates query over [start, end] at the given step
+func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) ([]Series, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", strconv.FormatFloat(float64(start.UnixMilli())/1000, 'f', 3, 64))
+	params.Set("end", strconv.FormatFloat(float64(end.UnixMilli())/1000, 'f', 3, 64))
+	params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.Endpoint+"/api/v1/query_range?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	if c.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.BearerToken)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response body: %w", err)
+	}
+
+	var qr queryResponse
+	if err := json.Unmarshal(body, &qr); err != nil {
+		return nil, fmt.Errorf("decode response (status %d): %w", resp.StatusCode, err)
+	}
+	if qr.Status != "success" {
+		return nil, fmt.Errorf("query failed: %s: %s", qr.ErrorType, qr.Error)
+	}
+	if qr.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("unexpected result type %q", qr.Data.ResultType)
+	}
+
+	series := make([]Series, 0, len(qr.Data.Result))
+	for _, r := range qr.Data.Result {
+		s := Series{Labels: r.Metric}
+		for _, v := range r.Values {
+			ts, ok := v[0].(float64)
+			raw, ok2 := v[1].(string)
+			if !ok || !ok2 {
+				continue
+			}
+			val, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				continue
+			}
+			s.Points = append(s.Points, Point{
+				Time:  time.UnixMilli(int64(ts * 1000)),
+				Value: val,
+			})
+		}
+		series = append(series, s)
+	}
+	return series, nil
+}
+
+// LabelString formats labels as {a="1",b="2"}, omitting __name__
+func LabelString(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if k != "__name__" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%q", k, labels[k])
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
@@ -0,0 +1,88 @@
+// Package tcp adds a raw TCP protocol: each request opens a connection to the
+// tcp://host:port URL, writes the test body and waits for the first bytes of
+// the reply. Link it in by building with -tags tcp.
+package tcp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+	"github.com/lazarkap/buzzbench.io/pkg/runner"
+)
+
+// Mode is the test mode selecting this protocol
+const Mode = "tcp"
+
+func init() {
+	runner.RegisterProtocol(Mode, func() runner.Protocol { return &protocol{} })
+}
+
+type protocol struct {
+	addr    string
+	payload []byte
+	timeout time.Duration
+	dialer  net.Dialer
+}
+
+// Setup parses the target address
+func (p *protocol) Setup(ctx context.Context, config api.TestConfiguration) error {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return fmt.Errorf("parse URL: %w", err)
+	}
+	if u.Scheme != "tcp" || u.Port() == "" {
+		return fmt.Errorf("tcp mode needs a tcp://host:port URL, got %q", config.URL)
+	}
+
+	p.addr = u.Host
+	p.payload = []byte(config.Body)
+	p.timeout = time.Duration(config.TimeoutSecs) * time.Second
+	return nil
+}
+
+// Teardown has nothing to release; every request closes its connection
+func (p *protocol) Teardown() error {
+	return nil
+}
+
+// Execute connects, sends the payload and reads the first reply bytes. With
+// an empty payload only the connection is measured.
+func (p *protocol) Execute(ctx context.Context, reqIdx int) api.RequestResult {
+	if ctx.Err() != nil {
+		return api.RequestResult{}
+	}
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	result := api.RequestResult{Timestamp: start}
+
+	conn, err := p.dialer.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		result.Duration = time.Since(start)
+		result.Error = err
+		return result
+	}
+	defer conn.Close()
+	result.ConnectDuration = time.Since(start)
+
+	if len(p.payload) > 0 {
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		if _, err = conn.Write(p.payload); err == nil {
+			_, err = conn.Read(make([]byte, 512))
+		}
+	}
+
+	result.Duration = time.Since(start)
+	result.Error = err
+	return result
+}
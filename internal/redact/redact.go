@@ -0,0 +1,64 @@
+// Package redact masks secrets in strings that end up in logs, JSON output
+// and submitted results.
+package redact
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Mask replaces every redacted value
+const Mask = "****"
+
+// DefaultParams are query parameter names whose values are always masked
+var DefaultParams = []string{
+	"access_token", "api_key", "apikey", "auth", "key",
+	"password", "secret", "sig", "signature", "token",
+}
+
+// userinfoRe matches the password part of user:password@ in URLs
+var userinfoRe = regexp.MustCompile(`(://[^/:@\s]+:)[^/@\s]+@`)
+
+// Redactor masks known secret values and sensitive query parameters.
+// A nil Redactor returns strings unchanged.
+type Redactor struct {
+	secrets []string
+	paramRe *regexp.Regexp
+}
+
+// New returns a redactor for the given secret values and query parameter
+// names. Empty secrets are ignored; parameter names match case-insensitively.
+func New(secrets, params []string) *Redactor {
+	r := &Redactor{}
+	for _, s := range secrets {
+		if s != "" {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+
+	var quoted []string
+	for _, p := range params {
+		if p = strings.TrimSpace(p); p != "" {
+			quoted = append(quoted, regexp.QuoteMeta(p))
+		}
+	}
+	if len(quoted) > 0 {
+		r.paramRe = regexp.MustCompile(`(?i)([?&](?:` + strings.Join(quoted, "|") + `)=)[^&#\s"]*`)
+	}
+	return r
+}
+
+// String returns s with secrets, sensitive query parameter values and URL
+// passwords replaced by Mask
+func (r *Redactor) String(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, Mask)
+	}
+	if r.paramRe != nil {
+		s = r.paramRe.ReplaceAllString(s, "${1}"+Mask)
+	}
+	return userinfoRe.ReplaceAllString(s, "${1}"+Mask+"@")
+}
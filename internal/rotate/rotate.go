@@ -0,0 +1,235 @@
+// Package rotate writes a log file that is rotated once it reaches a size,
+// optionally gzipping and pruning the rotated files, so logs of long runs
+// can't fill the disk.
+package rotate
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stampLayout is the time inserted into the names of rotated files
+const stampLayout = "20060102T150405.000"
+
+// Options controls when a File rotates and what happens to rotated files
+type Options struct {
+	MaxBytes int64 // rotate before the file grows past this; 0 never rotates
+	Keep     int   // rotated files to keep, oldest removed first; 0 keeps all
+	Compress bool  // gzip rotated files
+}
+
+// File is a buffered log file rotated by size. A rotated file is renamed
+// with the time of its rotation inserted before the extension, e.g.
+// requests-20261017T140210.123.jsonl, then compressed and pruned in the
+// background so writes aren't held up. Writes are never split across files.
+type File struct {
+	path string
+	opts Options
+
+	mu     sync.Mutex
+	f      *os.File // nil once closed, or after a failed rotation
+	w      *bufio.Writer
+	size   int64
+	closed bool
+
+	rotated chan string
+	done    sync.WaitGroup
+}
+
+// Open opens path for appending, creating it if needed
+func Open(path string, opts Options) (*File, error) {
+	if opts.MaxBytes < 0 || opts.Keep < 0 {
+		return nil, fmt.Errorf("invalid rotation options: max %d bytes, keep %d", opts.MaxBytes, opts.Keep)
+	}
+	r := &File{path: path, opts: opts, rotated: make(chan string, 16)}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	r.done.Add(1)
+	go func() {
+		defer r.done.Done()
+		for name := range r.rotated {
+			r.archive(name)
+		}
+	}()
+	return r, nil
+}
+
+func (r *File) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.w, r.size = f, bufio.NewWriterSize(f, 64<<10), info.Size()
+	return nil
+}
+
+// Write appends p, rotating first when it would take the file past
+// MaxBytes. A p larger than MaxBytes gets a file of its own.
+func (r *File) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	if r.opts.MaxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.opts.MaxBytes {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.w.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Flush writes buffered data to the file
+func (r *File) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	return r.w.Flush()
+}
+
+// Close flushes and closes the file, and waits for rotated files to be
+// compressed and pruned
+func (r *File) Close() error {
+	r.mu.Lock()
+	var err error
+	if r.f != nil {
+		err = r.w.Flush()
+		if cerr := r.f.Close(); err == nil {
+			err = cerr
+		}
+		r.f = nil
+	}
+	if !r.closed {
+		r.closed = true
+		close(r.rotated)
+	}
+	r.mu.Unlock()
+	r.done.Wait()
+	return err
+}
+
+// rotate moves the current file aside and starts a new one
+func (r *File) rotate() error {
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+	// From here on a failure leaves the File closed to writes
+	err := r.f.Close()
+	r.f = nil
+	if err != nil {
+		return err
+	}
+	// Rotations within the same millisecond mustn't overwrite each other
+	t := time.Now()
+	name := r.rotatedName(t)
+	for exists(name) || exists(name+".gz") {
+		t = t.Add(time.Millisecond)
+		name = r.rotatedName(t)
+	}
+	if err := os.Rename(r.path, name); err != nil {
+		return err
+	}
+	r.rotated <- name
+	return r.open()
+}
+
+// rotatedName inserts the time of rotation before the extension of path
+func (r *File) rotatedName(t time.Time) string {
+	ext := filepath.Ext(r.path)
+	return strings.TrimSuffix(r.path, ext) + "-" + t.Format(stampLayout) + ext
+}
+
+func exists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+// archive compresses a rotated file when asked to, then prunes the oldest.
+// Failures leave files behind rather than lose them, so they are only
+// reported on stderr.
+func (r *File) archive(name string) {
+	if r.opts.Compress {
+		if err := compress(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: compressing %s: %v\n", name, err)
+		}
+	}
+	if r.opts.Keep > 0 {
+		if err := r.prune(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: pruning rotated logs of %s: %v\n", r.path, err)
+		}
+	}
+}
+
+// compress gzips name into name.gz and removes it
+func compress(name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(name+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	return os.Remove(name)
+}
+
+// prune removes the oldest rotated files beyond Keep. The time in their
+// names sorts them; files that merely look alike are left alone.
+func (r *File) prune() error {
+	ext := filepath.Ext(r.path)
+	base := strings.TrimSuffix(r.path, ext) + "-"
+	matches, err := filepath.Glob(base + "*" + ext + "*")
+	if err != nil {
+		return err
+	}
+	var rotated []string
+	for _, m := range matches {
+		stamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(m, base), ".gz"), ext)
+		if _, err := time.Parse(stampLayout, stamp); err == nil {
+			rotated = append(rotated, m)
+		}
+	}
+	sort.Strings(rotated)
+	for len(rotated) > r.opts.Keep {
+		if err := os.Remove(rotated[0]); err != nil {
+			return err
+		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
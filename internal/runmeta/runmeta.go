@@ -0,0 +1,86 @@
+// Package runmeta collects where and from what code a run was made, so
+// results can be correlated with code changes.
+package runmeta
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// gitTimeout bounds each git invocation made when CI variables are missing
+const gitTimeout = 2 * time.Second
+
+// ciProvider names the environment variables a CI system sets for the
+// commit, the branch and the job's URL. url builds the URL when the system
+// doesn't set it directly.
+type ciProvider struct {
+	name   string
+	detect string
+	commit string
+	branch string
+	jobURL string
+	url    func() string
+}
+
+var providers = []ciProvider{
+	{name: "github", detect: "GITHUB_ACTIONS", commit: "GITHUB_SHA", branch: "GITHUB_REF_NAME", url: func() string {
+		if os.Getenv("GITHUB_RUN_ID") == "" {
+			return ""
+		}
+		return os.Getenv("GITHUB_SERVER_URL") + "/" + os.Getenv("GITHUB_REPOSITORY") + "/actions/runs/" + os.Getenv("GITHUB_RUN_ID")
+	}},
+	{name: "gitlab", detect: "GITLAB_CI", commit: "CI_COMMIT_SHA", branch: "CI_COMMIT_REF_NAME", jobURL: "CI_JOB_URL"},
+	{name: "circleci", detect: "CIRCLECI", commit: "CIRCLE_SHA1", branch: "CIRCLE_BRANCH", jobURL: "CIRCLE_BUILD_URL"},
+	{name: "buildkite", detect: "BUILDKITE", commit: "BUILDKITE_COMMIT", branch: "BUILDKITE_BRANCH", jobURL: "BUILDKITE_BUILD_URL"},
+	{name: "jenkins", detect: "JENKINS_URL", commit: "GIT_COMMIT", branch: "GIT_BRANCH", jobURL: "BUILD_URL"},
+}
+
+// Collect returns the metadata of this run: the buzzbench version, the
+// hostname, and the git commit, branch and CI job when known. CI variables
+// take precedence; otherwise git is asked about the working directory.
+func Collect(version string) *api.RunMetadata {
+	meta := &api.RunMetadata{Version: version}
+	meta.Hostname, _ = os.Hostname()
+
+	for _, p := range providers {
+		if os.Getenv(p.detect) == "" {
+			continue
+		}
+		meta.CI = p.name
+		meta.GitCommit = os.Getenv(p.commit)
+		meta.GitBranch = os.Getenv(p.branch)
+		if p.url != nil {
+			meta.CIJobURL = p.url()
+		} else {
+			meta.CIJobURL = os.Getenv(p.jobURL)
+		}
+		break
+	}
+
+	if meta.GitCommit == "" {
+		meta.GitCommit = git("rev-parse", "HEAD")
+	}
+	if meta.GitBranch == "" {
+		if branch := git("rev-parse", "--abbrev-ref", "HEAD"); branch != "HEAD" {
+			meta.GitBranch = branch // "HEAD" when detached
+		}
+	}
+	return meta
+}
+
+// git runs a git command and returns its trimmed output, or "" when git is
+// missing or fails, e.g. outside a repository
+func git(args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "git", args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
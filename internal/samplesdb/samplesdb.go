@@ -0,0 +1,19 @@
+// Package samplesdb exports every request of a run to a SQLite database: a
+// samples table with one row per request, indexed by run and time, and a
+// runs table describing each test run, so results can be queried with SQL
+// without a separate data pipeline. On platforms the pure-Go SQLite driver
+// doesn't support, Open fails.
+package samplesdb
+
+import "github.com/lazarkap/buzzbench.io/pkg/api"
+
+// Options describes what every run row records besides its test
+type Options struct {
+	Metadata *api.RunMetadata
+	Labels   map[string]string
+
+	// Secrets and RedactParams mask error messages, together with each
+	// test's auth token
+	Secrets      []string
+	RedactParams []string
+}
@@ -0,0 +1,249 @@
+//go:build (darwin && (amd64 || arm64)) || (freebsd && (386 || amd64 || arm || arm64)) || (linux && (386 || amd64 || arm || arm64 || loong64 || ppc64le || riscv64 || s390x)) || (openbsd && (amd64 || arm64)) || windows
+
+package samplesdb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+
+	"github.com/lazarkap/buzzbench.io/internal/redact"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// batchSize is how many samples are inserted per transaction
+const batchSize = 10000
+
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id           INTEGER PRIMARY KEY,
+	test_id      TEXT,
+	test         TEXT,
+	url          TEXT,
+	method       TEXT,
+	concurrency  INTEGER,
+	target_rps   REAL,
+	started_at   TEXT NOT NULL,
+	finished_at  TEXT,
+	requests     INTEGER,
+	success_rate REAL,
+	rps          REAL,
+	avg_ms       REAL,
+	p50_ms       REAL,
+	p90_ms       REAL,
+	p95_ms       REAL,
+	p99_ms       REAL,
+	max_ms       REAL,
+	version      TEXT,
+	hostname     TEXT,
+	git_commit   TEXT,
+	git_branch   TEXT,
+	ci_job_url   TEXT,
+	labels       TEXT
+);
+CREATE TABLE IF NOT EXISTS samples (
+	run_id      INTEGER NOT NULL REFERENCES runs(id),
+	ts_us       INTEGER NOT NULL,
+	duration_ms REAL NOT NULL,
+	status      INTEGER,
+	error       TEXT,
+	endpoint    INTEGER,
+	request_id  TEXT
+);
+CREATE INDEX IF NOT EXISTS samples_run_ts ON samples(run_id, ts_us);
+CREATE INDEX IF NOT EXISTS samples_run_status ON samples(run_id, status);
+`
+
+// DB is a runner.MetricsSink writing to a SQLite database. A run starts
+// with a test's first request and ends with its result.
+type DB struct {
+	db   *sql.DB
+	opts Options
+
+	mu     sync.Mutex
+	tx     *sql.Tx         // batches writes; nil between batches
+	insert *sql.Stmt       // inserts a sample in tx
+	n      int             // samples in tx
+	runs   map[string]*run // open runs by test ID
+	err    error           // first write error; later writes are skipped
+}
+
+// run is a test run being written
+type run struct {
+	id     int64
+	redact *redact.Redactor
+}
+
+// Open opens or creates the database at path. Runs are added to those
+// already in it.
+func Open(path string, opts Options) (*DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// All writes share one transaction on one connection
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create tables in %s: %w", path, err)
+	}
+	return &DB{db: db, opts: opts, runs: make(map[string]*run)}, nil
+}
+
+// ObserveRequest adds a sample to the test's run, starting the run on its
+// first request
+func (d *DB) ObserveRequest(config api.TestConfiguration, res api.RequestResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return
+	}
+	d.fail(d.add(config, res))
+}
+
+func (d *DB) add(config api.TestConfiguration, res api.RequestResult) error {
+	r, err := d.run(config, res.Timestamp)
+	if err != nil {
+		return err
+	}
+	var errMsg, requestID any
+	if res.Error != nil {
+		errMsg = r.redact.String(res.Error.Error())
+	}
+	if res.RequestID != "" {
+		requestID = res.RequestID
+	}
+	if _, err := d.insert.Exec(r.id, res.Timestamp.UnixMicro(), float64(res.Duration.Microseconds())/1000,
+		res.Status, errMsg, res.Endpoint, requestID); err != nil {
+		return err
+	}
+	if d.n++; d.n >= batchSize {
+		return d.commit()
+	}
+	return nil
+}
+
+// ObserveTest ends the test's run, recording the result's summary
+func (d *DB) ObserveTest(result api.TestResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return
+	}
+	d.fail(d.finish(result))
+}
+
+func (d *DB) finish(result api.TestResult) error {
+	config := api.TestConfiguration{
+		ID:          result.TestConfigurationID,
+		URL:         result.URL,
+		Method:      result.Method,
+		Concurrency: result.Concurrency,
+		RateRPS:     result.TargetRPS,
+	}
+	r, err := d.run(config, time.Now())
+	if err != nil {
+		return err
+	}
+	delete(d.runs, result.TestConfigurationID)
+	_, err = d.tx.Exec(`UPDATE runs SET url = ?, finished_at = ?, requests = ?, success_rate = ?, rps = ?,
+		avg_ms = ?, p50_ms = ?, p90_ms = ?, p95_ms = ?, p99_ms = ?, max_ms = ? WHERE id = ?`,
+		result.URL, time.Now().UTC().Format(time.RFC3339Nano), result.Requests, result.SuccessRate, result.RequestsPerSecond,
+		result.AvgResponseTime, result.P50ResponseTime, result.P90ResponseTime, result.P95ResponseTime, result.P99ResponseTime,
+		result.MaxResponseTime, r.id)
+	if err != nil {
+		return err
+	}
+	return d.commit()
+}
+
+// Close writes out what is pending and closes the database. It returns the
+// first error met while writing.
+func (d *DB) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err == nil {
+		d.fail(d.commit())
+	} else if d.tx != nil {
+		d.tx.Rollback()
+	}
+	d.fail(d.db.Close())
+	return d.err
+}
+
+func (d *DB) fail(err error) {
+	if err != nil && d.err == nil {
+		d.err = fmt.Errorf("samples database: %w", err)
+	}
+}
+
+// begin starts a batch, unless one is open
+func (d *DB) begin() error {
+	if d.tx != nil {
+		return nil
+	}
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	insert, err := tx.Prepare(`INSERT INTO samples (run_id, ts_us, duration_ms, status, error, endpoint, request_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	d.tx, d.insert = tx, insert
+	return nil
+}
+
+// commit writes out the current batch
+func (d *DB) commit() error {
+	if d.tx == nil {
+		return nil
+	}
+	d.insert.Close()
+	err := d.tx.Commit()
+	d.tx, d.insert, d.n = nil, nil, 0
+	return err
+}
+
+// run returns the test's open run, or starts one. It also makes sure a
+// batch is open.
+func (d *DB) run(config api.TestConfiguration, started time.Time) (*run, error) {
+	if err := d.begin(); err != nil {
+		return nil, err
+	}
+	if r := d.runs[config.ID]; r != nil {
+		return r, nil
+	}
+	var labels []byte
+	if len(d.opts.Labels) > 0 {
+		labels, _ = json.Marshal(d.opts.Labels)
+	}
+	meta := d.opts.Metadata
+	if meta == nil {
+		meta = &api.RunMetadata{}
+	}
+	secrets := append([]string{config.AuthToken}, d.opts.Secrets...)
+	red := redact.New(secrets, append(redact.DefaultParams, d.opts.RedactParams...))
+
+	res, err := d.tx.Exec(`INSERT INTO runs (test_id, test, url, method, concurrency, target_rps, started_at,
+		version, hostname, git_commit, git_branch, ci_job_url, labels) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		config.ID, config.Name, red.String(config.URL), config.Method, config.Concurrency, config.RateRPS,
+		started.UTC().Format(time.RFC3339Nano),
+		meta.Version, meta.Hostname, meta.GitCommit, meta.GitBranch, meta.CIJobURL, string(labels))
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	r := &run{id: id, redact: red}
+	d.runs[config.ID] = r
+	return r, nil
+}
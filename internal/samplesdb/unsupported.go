@@ -0,0 +1,27 @@
+//go:build !((darwin && (amd64 || arm64)) || (freebsd && (386 || amd64 || arm || arm64)) || (linux && (386 || amd64 || arm || arm64 || loong64 || ppc64le || riscv64 || s390x)) || (openbsd && (amd64 || arm64)) || windows)
+
+package samplesdb
+
+import (
+	"errors"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// DB stands in for the SQLite writer on platforms the pure-Go SQLite
+// driver doesn't support
+type DB struct{}
+
+// Open always fails: the SQLite driver isn't available on this platform
+func Open(path string, opts Options) (*DB, error) {
+	return nil, errors.New("-samples-sqlite is not supported on this platform")
+}
+
+// ObserveRequest does nothing
+func (d *DB) ObserveRequest(config api.TestConfiguration, res api.RequestResult) {}
+
+// ObserveTest does nothing
+func (d *DB) ObserveTest(result api.TestResult) {}
+
+// Close does nothing
+func (d *DB) Close() error { return nil }
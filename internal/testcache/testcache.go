@@ -0,0 +1,111 @@
+// Package testcache keeps the tests last fetched from the BuzzBench API on
+// disk, so they can be rerun while the API can't be reached.
+package testcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// entry is the file stored for one fetch
+type entry struct {
+	Source    string                  `json:"source"`
+	FetchedAt time.Time               `json:"fetched_at"`
+	Tests     []api.TestConfiguration `json:"tests"`
+}
+
+// Dir returns the directory the tests are cached in, under the user's cache
+// directory
+func Dir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "buzzbench", "tests"), nil
+}
+
+// path returns the file tests fetched from source are cached in. source
+// identifies the fetch: API, project, and the test ID or filter.
+func path(source string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(source))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// Save caches the tests fetched from source, replacing the previous fetch.
+// Tests can hold credentials, so only the user can read the file.
+func Save(source string, tests []api.TestConfiguration, fetchedAt time.Time) error {
+	file, err := path(source)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry{Source: source, FetchedAt: fetchedAt, Tests: tests})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+
+	// Write next to the old file and rename it into place, so a run that is
+	// interrupted never leaves a torn cache behind
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".tests-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// Load returns the tests last fetched from source and when they were fetched
+func Load(source string) ([]api.TestConfiguration, time.Time, error) {
+	file, err := path(source)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, time.Time{}, fmt.Errorf("no cached tests for %s; run once with the API reachable first", source)
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, time.Time{}, fmt.Errorf("read test cache %s: %w", file, err)
+	}
+	return e.Tests, e.FetchedAt, nil
+}
+
+// Source identifies a fetch of tests for the cache: the API and project it
+// went to, and what was asked for
+func Source(baseURL, project, workspace, what string) string {
+	parts := []string{strings.TrimRight(baseURL, "/")}
+	if project != "" {
+		parts = append(parts, "project "+project)
+	}
+	if workspace != "" {
+		parts = append(parts, "workspace "+workspace)
+	}
+	return strings.Join(append(parts, what), ", ")
+}
@@ -0,0 +1,133 @@
+// Package update verifies and installs new buzzbench releases.
+package update
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DownloadTimeout bounds downloading a release binary, which can take far
+// longer than an API call on a slow link
+const DownloadTimeout = 10 * time.Minute
+
+// Verify checks that data matches the hex SHA-256 checksum and that the
+// release carries a valid ed25519 signature from the release key over
+// SignedMessage, so a validly signed binary can't be served as another
+// version or platform. publicKey and signature are base64 encoded.
+func Verify(data []byte, version, goos, goarch, checksum, signature, publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid release public key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), SignedMessage(version, goos, goarch, checksum), sig) {
+		return fmt.Errorf("release signature does not match the release key")
+	}
+
+	sum := sha256.Sum256(data)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), checksum) {
+		return fmt.Errorf("checksum mismatch: download is corrupt or was tampered with")
+	}
+	return nil
+}
+
+// SignedMessage returns what a release's signature covers:
+// version|os|arch|sha256, with the checksum in lower-case hex
+func SignedMessage(version, goos, goarch, checksum string) []byte {
+	return []byte(version + "|" + goos + "|" + goarch + "|" + strings.ToLower(checksum))
+}
+
+// Replace atomically swaps the executable at path for data, keeping its
+// permissions. The new file is written next to the old one and renamed into
+// place; on Windows, where a running binary cannot be overwritten, the old one
+// is moved aside first.
+func Replace(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".new-*")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write new binary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write new binary: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if runtime.GOOS == "windows" {
+		old := path + ".old"
+		os.Remove(old)
+		if err := os.Rename(path, old); err != nil {
+			return fmt.Errorf("move old binary aside: %w", err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("install new binary: %w", err)
+	}
+	return nil
+}
+
+// Newer reports whether version a is newer than b. Versions are dotted
+// numbers with an optional leading "v"; anything else (e.g. "dev") is older
+// than every release.
+func Newer(a, b string) bool {
+	pa, okA := parseVersion(a)
+	pb, okB := parseVersion(b)
+	switch {
+	case !okA:
+		return false
+	case !okB:
+		return true
+	}
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return false
+}
+
+func parseVersion(v string) ([]int, bool) {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	var parts []int
+	for _, p := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
@@ -0,0 +1,371 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	neturl "net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client provides methods to interact with the BuzzBench API. Every call
+// takes a context for cancellation and deadlines; HTTPClient's timeout
+// still bounds each call. Tests can give HTTPClient a Transport that
+// simulates the API, and set Clock to fix signature timestamps.
+type Client struct {
+	BaseURL    string
+	APIKey     string
+	HTTPClient *http.Client
+
+	// Clock timestamps signed submissions; nil uses SystemClock
+	Clock Clock
+
+	// Project and Workspace scope every call for accounts with several of
+	// them; empty leaves the choice to the API key's defaults
+	Project   string
+	Workspace string
+
+	// SigningSecret, when set, signs every submitted result with a shared
+	// secret (see SignatureHeader) so the platform can tell it wasn't
+	// altered between the run and the submission
+	SigningSecret string
+}
+
+// NewClient creates a new API client
+func NewClient(baseURL, apiKey string) *Client {
+	return &Client{
+		BaseURL: baseURL,
+		APIKey:  apiKey,
+		HTTPClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// FetchPipelineTests retrieves the tests configured to run in the pipeline
+// that match filter; the zero filter matches all of them
+func (c *Client) FetchPipelineTests(ctx context.Context, filter TestFilter) ([]TestConfiguration, error) {
+	url := fmt.Sprintf("%s/tests/pipeline%s", c.BaseURL, filter.query())
+	req, err := c.newRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	var response APIResponse
+	if err := c.do(req, &response); err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+
+	return response.Tests, nil
+}
+
+// ListTests retrieves the project's tests that match filter, whether or not
+// they run in the pipeline
+func (c *Client) ListTests(ctx context.Context, filter TestFilter) ([]TestConfiguration, error) {
+	url := fmt.Sprintf("%s/tests%s", c.BaseURL, filter.query())
+	req, err := c.newRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	var response APIResponse
+	if err := c.do(req, &response); err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+
+	return response.Tests, nil
+}
+
+// TestFilter narrows the tests fetched from the API, so filtering happens on
+// the server rather than after downloading every configuration
+type TestFilter struct {
+	Environment string   // tests for this environment, e.g. "staging"
+	Tags        []string // tests carrying all of these tags
+	Enabled     *bool    // only enabled (true) or disabled (false) tests; nil for both
+}
+
+// query encodes the filter as a query string, empty when it matches everything
+func (f TestFilter) query() string {
+	q := neturl.Values{}
+	if f.Environment != "" {
+		q.Set("env", f.Environment)
+	}
+	if len(f.Tags) > 0 {
+		q.Set("tags", strings.Join(f.Tags, ","))
+	}
+	if f.Enabled != nil {
+		q.Set("enabled", strconv.FormatBool(*f.Enabled))
+	}
+	if len(q) == 0 {
+		return ""
+	}
+	return "?" + q.Encode()
+}
+
+// FetchTestByID retrieves a specific test configuration by ID
+func (c *Client) FetchTestByID(ctx context.Context, testID string) (*TestConfiguration, error) {
+	url := fmt.Sprintf("%s/tests/%s", c.BaseURL, testID)
+	req, err := c.newRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	var test TestConfiguration
+	if err := c.do(req, &test); err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+
+	return &test, nil
+}
+
+// FetchTestResults retrieves up to limit of a test's submitted results,
+// newest first
+func (c *Client) FetchTestResults(ctx context.Context, testID string, limit int) ([]TestResult, error) {
+	url := fmt.Sprintf("%s/tests/%s/results?limit=%d", c.BaseURL, testID, limit)
+	req, err := c.newRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	var response APIResponse
+	if err := c.do(req, &response); err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+
+	return response.Results, nil
+}
+
+// SubmitTestResult sends test results back to the API and returns the ID the
+// platform stored them under
+func (c *Client) SubmitTestResult(ctx context.Context, result TestResult) (string, error) {
+	url := fmt.Sprintf("%s/test-results", c.BaseURL)
+
+	req, err := c.newRequest(ctx, "POST", url, result)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	if c.SigningSecret != "" {
+		if err := c.sign(req, c.now()); err != nil {
+			return "", fmt.Errorf("sign result: %w", err)
+		}
+	}
+
+	var response SubmitResponse
+	if err := c.do(req, &response); err != nil {
+		return "", fmt.Errorf("execute request: %w", err)
+	}
+
+	return response.ID, nil
+}
+
+// UploadArtifact attaches a file, such as a rendered report, to a submitted
+// result so it is linked from the dashboard
+func (c *Client) UploadArtifact(ctx context.Context, resultID, name string, body io.Reader) error {
+	url := fmt.Sprintf("%s/test-results/%s/artifacts?name=%s", c.BaseURL, resultID, neturl.QueryEscape(name))
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	c.setHeaders(req)
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	if err := c.do(req, nil); err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+
+	return nil
+}
+
+// SubmitProbeResult sends a monitor-mode availability check to the API
+func (c *Client) SubmitProbeResult(ctx context.Context, probe ProbeResult) error {
+	url := fmt.Sprintf("%s/probe-results", c.BaseURL)
+
+	req, err := c.newRequest(ctx, "POST", url, probe)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	if err := c.do(req, nil); err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+
+	return nil
+}
+
+// Login exchanges account credentials for an API key
+func (c *Client) Login(ctx context.Context, email, password string) (*LoginResponse, error) {
+	url := fmt.Sprintf("%s/auth/login", c.BaseURL)
+
+	req, err := c.newRequest(ctx, "POST", url, LoginRequest{Email: email, Password: password})
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	var response LoginResponse
+	if err := c.do(req, &response); err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+
+	return &response, nil
+}
+
+// WhoAmI returns the account and project the API key belongs to
+func (c *Client) WhoAmI(ctx context.Context) (*Identity, error) {
+	url := fmt.Sprintf("%s/auth/whoami", c.BaseURL)
+	req, err := c.newRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	var identity Identity
+	if err := c.do(req, &identity); err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+
+	return &identity, nil
+}
+
+// LatestRelease returns the newest release for an OS and architecture
+func (c *Client) LatestRelease(ctx context.Context, goos, goarch string) (*Release, error) {
+	url := fmt.Sprintf("%s/releases/latest?os=%s&arch=%s", c.BaseURL, goos, goarch)
+	req, err := c.newRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	var release Release
+	if err := c.do(req, &release); err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+
+	return &release, nil
+}
+
+// Download fetches a release artifact. It ignores the client's timeout,
+// leaving ctx to bound the transfer.
+func (c *Client) Download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	// The client's timeout is sized for API calls; ctx bounds a download
+	hc := *c.HTTPClient
+	hc.Timeout = 0
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("execute HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed (status %d)", resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// ServerTime returns the API server's clock, read from the Date header
+func (c *Client) ServerTime(ctx context.Context) (time.Time, error) {
+	req, err := c.newRequest(ctx, "HEAD", c.BaseURL, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("execute HTTP request: %w", err)
+	}
+	resp.Body.Close()
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse Date header: %w", err)
+	}
+	return date, nil
+}
+
+// newRequest creates a new HTTP request with common headers
+func (c *Client) newRequest(ctx context.Context, method, url string, body interface{}) (*http.Request, error) {
+	var buf bytes.Buffer
+
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return nil, fmt.Errorf("encode request body: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, &buf)
+	if err != nil {
+		return nil, fmt.Errorf("create HTTP request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	c.setHeaders(req)
+
+	return req, nil
+}
+
+// now returns the current time on the client's clock
+func (c *Client) now() time.Time {
+	if c.Clock == nil {
+		return SystemClock.Now()
+	}
+	return c.Clock.Now()
+}
+
+// setHeaders adds the authentication and scoping headers every call carries
+func (c *Client) setHeaders(req *http.Request) {
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.APIKey))
+	if c.Project != "" {
+		req.Header.Set("X-BuzzBench-Project", c.Project)
+	}
+	if c.Workspace != "" {
+		req.Header.Set("X-BuzzBench-Workspace", c.Workspace)
+	}
+}
+
+// do executes an HTTP request and decodes the response
+func (c *Client) do(req *http.Request, v interface{}) error {
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read the response body
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response body: %w", err)
+	}
+
+	// Check for error status codes
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	// If there's no response structure to decode into, we're done
+	if v == nil || len(body) == 0 {
+		return nil
+	}
+
+	// Decode the JSON response
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package api
+
+import "time"
+
+// Clock is the source of time for the runner and the API client. Tests can
+// substitute a fake to simulate time deterministically, e.g. to advance
+// through think times and Retry-After waits without sleeping.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// After sends the current time on the returned channel once d has
+	// elapsed
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the real wall clock
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
@@ -0,0 +1,811 @@
+// Package api holds the BuzzBench test and result models shared by the
+// runner and the platform, and the client for the platform API. Its
+// exported API follows semantic versioning.
+package api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TestConfiguration holds the configuration for a performance test
+type TestConfiguration struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	URL           string `json:"url"`
+	Method        string `json:"method"`
+	Requests      int    `json:"requests"`
+	Concurrency   int    `json:"concurrency"`
+	TimeoutSecs   int    `json:"timeout_seconds"`
+	AuthToken     string `json:"auth_token,omitempty"`
+	Body          string `json:"body,omitempty"`
+	RunInPipeline bool   `json:"run_in_pipeline"`
+	UseVariables  bool   `json:"use_variables"`       // Whether to use dynamic variables
+	Variables     string `json:"variables,omitempty"` // JSON string for variable definitions
+	Description   string `json:"description,omitempty"`
+
+	// Tags are the labels the test carries on the platform
+	Tags []string `json:"tags,omitempty"`
+
+	// Headers are extra request headers. Values may use {{variables}}, as
+	// may AuthToken.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// QueryParams are appended to the URL's query string, encoded. Values
+	// may use {{variables}}.
+	QueryParams map[string]string `json:"query_params,omitempty"`
+
+	// RequestIDHeader names a header set to a fresh UUID on every request so
+	// failures can be looked up in server logs. Empty or NoRequestIDHeader
+	// disables injection; the CLI fills an empty one with its default.
+	RequestIDHeader string `json:"request_id_header,omitempty"`
+
+	// ResponseIDHeader names a response header (e.g. X-Amzn-RequestId) whose
+	// value is recorded on failed requests to trace the server-side transaction.
+	ResponseIDHeader string `json:"response_id_header,omitempty"`
+
+	// HostHeader and SNIName override the Host header and TLS server name
+	// derived from the URL, e.g. when targeting a load balancer by IP.
+	HostHeader string `json:"host_header,omitempty"`
+	SNIName    string `json:"sni_name,omitempty"`
+
+	// IPFamily forces IPv4 ("4") or IPv6 ("6"); empty lets the resolver decide.
+	// LocalAddr binds outgoing connections to a specific source address;
+	// LocalAddrPool lists addresses or CIDR ranges that new connections rotate through.
+	IPFamily      string   `json:"ip_family,omitempty"`
+	LocalAddr     string   `json:"local_addr,omitempty"`
+	LocalAddrPool []string `json:"local_addr_pool,omitempty"`
+
+	// Connection pool tuning; zero values keep Go's transport defaults
+	DisableKeepAlives   bool `json:"disable_keep_alives,omitempty"`
+	MaxIdleConnsPerHost int  `json:"max_idle_conns_per_host,omitempty"`
+	IdleConnTimeoutSecs int  `json:"idle_conn_timeout_seconds,omitempty"`
+	MaxConnsPerHost     int  `json:"max_conns_per_host,omitempty"`
+
+	// Timeouts bound the phases of a request separately, so failing to
+	// connect is told apart from a slow response; TimeoutSecs remains the
+	// limit for the whole request, body included
+	Timeouts *Timeouts `json:"timeouts,omitempty"`
+
+	// Mode selects a specialised benchmark; empty runs a regular HTTP test.
+	// "connect" opens a fresh TCP+TLS connection per request and reports
+	// connection-establishment timings separately. "handshake" performs only
+	// the TCP connect and TLS handshake, without sending an HTTP request.
+	// "cache" revalidates each URL with If-None-Match / If-Modified-Since
+	// taken from its last full response and reports the 304 ratio.
+	// "preflight" sends the CORS preflight a browser would send before each
+	// request, from Origin, and fails responses that don't allow it.
+	Mode string `json:"mode,omitempty"`
+
+	// Origin sets the Origin header, as a browser on that site would
+	Origin string `json:"origin,omitempty"`
+
+	// CacheBust controls how cacheable the requests are. "unique" appends a
+	// query parameter unique to each request so intermediary caches miss;
+	// "reuse" expands the URL's variables once and repeats that URL, so
+	// caches stay warm. Empty sends URLs as configured.
+	CacheBust string `json:"cache_bust,omitempty"`
+
+	// AcceptEncoding sets the Accept-Encoding header, e.g. "gzip" or "br".
+	// Responses are then read in full and not decompressed, so the result
+	// reports their size on the wire and the latency includes the download.
+	AcceptEncoding string `json:"accept_encoding,omitempty"`
+
+	// Bandwidth caps each connection's throughput in each direction to simulate
+	// slow clients: a preset (modem, 2g, 3g, 4g) or a rate such as "512kbps".
+	Bandwidth string `json:"bandwidth,omitempty"`
+
+	// DelayMs and JitterMs inject an artificial client-side delay of
+	// DelayMs ± JitterMs before each request to simulate distant users.
+	DelayMs  int `json:"delay_ms,omitempty"`
+	JitterMs int `json:"jitter_ms,omitempty"`
+
+	// RateRPS switches to a fixed arrival rate (open model): requests are
+	// started at this rate regardless of how quickly earlier ones complete.
+	// Concurrency then caps the number of requests in flight.
+	RateRPS float64 `json:"rate_rps,omitempty"`
+
+	// Backoff makes workers honor rate limiting: a 429 response, or any
+	// response with Retry-After, halves the request rate to its host and
+	// pauses it for the Retry-After period. The rate recovers while the host
+	// stops limiting. Time spent waiting is not part of any response time.
+	Backoff bool `json:"backoff,omitempty"`
+
+	// RetryAfter retries a request answered with 429 or 503 up to this many
+	// times, each after the response's Retry-After period (one second when
+	// it has none). A Retry-After longer than TimeoutSecs is not waited for.
+	// The result then reflects the last attempt, timed on its own.
+	RetryAfter int `json:"retry_after,omitempty"`
+
+	// IntegratedAuth authenticates every request with Windows integrated
+	// authentication, for intranet services behind Kerberos or NTLM. It
+	// replaces AuthToken.
+	IntegratedAuth *IntegratedAuth `json:"integrated_auth,omitempty"`
+
+	// UserAgents rotates the User-Agent header per request, for a mix of
+	// client classes. Each entry is a User-Agent string or a preset name
+	// such as "chrome", "safari-ios" or "browsers" (a weighted mix of common
+	// browsers). A User-Agent in Headers takes precedence.
+	UserAgents []string `json:"user_agents,omitempty"`
+
+	// Chaos injects client-side faults into a share of the requests, to
+	// check that the target's monitoring notices misbehaving clients.
+	Chaos *ChaosConfig `json:"chaos,omitempty"`
+
+	// Script is JavaScript defining optional setup, beforeRequest and
+	// afterResponse hooks for request signing, dynamic bodies and assertions.
+	Script string `json:"script,omitempty"`
+
+	// LuaAssert is a Lua expression or chunk checked against every response,
+	// with status, headers, body and duration_ms in scope.
+	LuaAssert string `json:"lua_assert,omitempty"`
+
+	// Prometheus lists server-side queries evaluated over the test window
+	// after the run and attached to the result as ServerMetrics.
+	Prometheus *PrometheusConfig `json:"prometheus,omitempty"`
+
+	// ClickHouse inserts every request of the test into a ClickHouse table
+	// while it runs, in batches over the HTTP interface.
+	ClickHouse *ClickHouseConfig `json:"clickhouse,omitempty"`
+
+	// Kafka produces an event for every request of the test to a Kafka
+	// topic while it runs.
+	Kafka *KafkaConfig `json:"kafka,omitempty"`
+
+	// Endpoints turns the test into a multi-endpoint test: each request goes
+	// to one endpoint, chosen at random in proportion to its weight. Relative
+	// endpoint URLs are resolved against URL.
+	Endpoints []Endpoint `json:"endpoints,omitempty"`
+
+	// Steps turns the test into a scenario: each iteration sends the steps
+	// in order from one worker, pausing for each step's think time after it,
+	// and Requests is the number of iterations. Step URLs are resolved
+	// against URL like endpoint URLs; Method and Body default to the test's.
+	Steps []Step `json:"steps,omitempty"`
+
+	// VUSetup is sent once by each virtual user (worker), in order, before
+	// its first measured request, e.g. a login whose token it extracts for
+	// later requests. Setup requests are not part of the test's metrics.
+	VUSetup []Step `json:"vu_setup,omitempty"`
+
+	// Replay sends these recorded requests in order, once each, instead of
+	// Requests copies of the test's request. Relative URLs are resolved
+	// against URL; the test's body and headers apply to every request.
+	Replay []ReplayRequest `json:"replay,omitempty"`
+
+	// ReplaySpeed, when set, starts each replayed request at its recorded
+	// Offset divided by ReplaySpeed, preserving the original gaps (2 replays
+	// twice as fast). It takes precedence over RateRPS.
+	ReplaySpeed float64 `json:"replay_speed,omitempty"`
+
+	// ReplayUsers amplifies a replay: that many virtual users each send every
+	// recorded request, advancing through the recording together. Variables
+	// with scope "user" keep one value per user, and {{$user}} is the user's
+	// number.
+	ReplayUsers int `json:"replay_users,omitempty"`
+
+	// Each runs the test exactly once per value instead of Requests times,
+	// e.g. GET /users/{{id}} for a list of real IDs. Failures are reported
+	// per value in the result's FailedItems.
+	Each *EachConfig `json:"each,omitempty"`
+
+	// Submit routes this test's result to another API endpoint or workspace,
+	// or skips submission. Nil submits to the default API.
+	Submit *SubmitConfig `json:"submit,omitempty"`
+}
+
+// NoRequestIDHeader as a test's RequestIDHeader turns request IDs off for
+// that test, where an empty one takes the CLI's default
+const NoRequestIDHeader = "none"
+
+// SecretEnvPrefix starts the name of every environment variable a test
+// configuration may read a secret from. Tests can come from the platform,
+// so they must not read arbitrary variables.
+const SecretEnvPrefix = "BUZZBENCH_"
+
+// CheckSecretEnv returns an error unless name may hold a test's secret
+func CheckSecretEnv(name string) error {
+	if !strings.HasPrefix(name, SecretEnvPrefix) {
+		return fmt.Errorf("secret environment variable %q must start with %s", name, SecretEnvPrefix)
+	}
+	return nil
+}
+
+// SubmitConfig overrides where a test's result is submitted in API mode
+type SubmitConfig struct {
+	Skip      bool   `json:"skip,omitempty"`        // don't submit this result
+	BaseURL   string `json:"base_url,omitempty"`    // API base URL, e.g. a staging instance
+	APIKeyEnv string `json:"api_key_env,omitempty"` // env var holding the API key for that workspace
+}
+
+// Endpoint is one target of a multi-endpoint test. Method and Body default
+// to the test's; Weight defaults to 1.
+type Endpoint struct {
+	Method string  `json:"method,omitempty"`
+	URL    string  `json:"url"`
+	Body   string  `json:"body,omitempty"`
+	Weight float64 `json:"weight,omitempty"`
+}
+
+// Step is one request of a scenario. Extract names values to take from its
+// response, each "json:path.to.field", "header:Name" or "regex:pattern"
+// (the first group); later steps of the same virtual user reference them as
+// {{name}}.
+type Step struct {
+	Name      string     `json:"name,omitempty"`
+	Method    string     `json:"method,omitempty"`
+	URL       string     `json:"url"`
+	Body      string     `json:"body,omitempty"`
+	ThinkTime *ThinkTime `json:"think_time,omitempty"`
+
+	Extract map[string]string `json:"extract,omitempty"`
+}
+
+// ThinkTime is the pause after a step, as a user reads the page. "fixed"
+// (the default) pauses MeanMs; "uniform" draws between MinMs and MaxMs;
+// "normal" draws around MeanMs with StdDevMs; "exponential" draws with mean
+// MeanMs. Draws are clamped to MinMs and, when set, MaxMs.
+type ThinkTime struct {
+	Distribution string `json:"distribution,omitempty"`
+	MeanMs       int    `json:"mean_ms,omitempty"`
+	MinMs        int    `json:"min_ms,omitempty"`
+	MaxMs        int    `json:"max_ms,omitempty"`
+	StdDevMs     int    `json:"stddev_ms,omitempty"`
+}
+
+// ReplayRequest is a recorded request. Offset is the time in seconds from
+// the first recorded request. Method and Body default to the test's.
+type ReplayRequest struct {
+	Method string  `json:"method"`
+	URL    string  `json:"url"`
+	Offset float64 `json:"offset"`
+	Body   string  `json:"body,omitempty"`
+}
+
+// EachConfig lists the values a test iterates over. Request i uses
+// Values[i] as the {{Variable}} placeholder.
+//
+// Alternatively Rows holds a table, e.g. read from a CSV file: request i
+// uses row i, with each cell as the {{placeholder}} named by its column.
+// Columns named method, url (or path) and body replace the test's method,
+// URL and body for that request.
+type EachConfig struct {
+	Variable string     `json:"variable,omitempty"` // placeholder name, default "item"
+	Values   []string   `json:"values"`
+	Columns  []string   `json:"columns,omitempty"`
+	Rows     [][]string `json:"rows,omitempty"`
+}
+
+// Windows integrated authentication schemes
+const (
+	AuthNegotiate = "negotiate" // Kerberos, via SPNEGO
+	AuthNTLM      = "ntlm"
+)
+
+// IntegratedAuth holds the credentials for Windows integrated
+// authentication. Domain is the NTLM domain or the Kerberos realm (default
+// from krb5.conf); a Username of the form user@REALM sets the realm too.
+// Kerberos logs in with Keytab or Password, or without either uses the
+// tickets of the credential cache (KRB5CCNAME), as obtained with kinit. SPN
+// defaults to HTTP/<host> and Krb5Conf to $KRB5_CONFIG or /etc/krb5.conf.
+type IntegratedAuth struct {
+	Scheme   string `json:"scheme"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Keytab   string `json:"keytab,omitempty"`
+	SPN      string `json:"spn,omitempty"`
+	Krb5Conf string `json:"krb5_conf,omitempty"`
+}
+
+// Client-side faults injected by chaos
+const (
+	FaultDrop     = "drop"     // abort the request once it is sent, before the response
+	FaultDelay    = "delay"    // stall the request body (or the request) for DelayMs
+	FaultTruncate = "truncate" // send half the body and close the connection
+)
+
+// ChaosConfig sets the percentage of requests that suffer each fault. A
+// fault picked for a request without a body that it needs (truncate) drops
+// it instead. DelayMs defaults to one second.
+type ChaosConfig struct {
+	DropPercent     float64 `json:"drop_percent,omitempty"`
+	DelayPercent    float64 `json:"delay_percent,omitempty"`
+	TruncatePercent float64 `json:"truncate_percent,omitempty"`
+	DelayMs         int     `json:"delay_ms,omitempty"`
+}
+
+// PrometheusConfig points at a Prometheus server and the PromQL queries to
+// run against it once a test finishes
+type PrometheusConfig struct {
+	Endpoint    string            `json:"endpoint"`
+	BearerToken string            `json:"bearer_token,omitempty"`
+	StepSecs    int               `json:"step_seconds,omitempty"` // resolution, default 5
+	Queries     []PrometheusQuery `json:"queries"`
+}
+
+// ClickHouseConfig points at a ClickHouse server's HTTP interface and the
+// table requests are inserted into. The password is read from the
+// environment variable PasswordEnv.
+type ClickHouseConfig struct {
+	Endpoint    string `json:"endpoint"` // e.g. http://clickhouse:8123
+	Table       string `json:"table"`    // optionally database-qualified
+	Username    string `json:"username,omitempty"`
+	PasswordEnv string `json:"password_env,omitempty"`
+	BatchSize   int    `json:"batch_size,omitempty"`    // rows per insert, default 10000
+	FlushSecs   int    `json:"flush_seconds,omitempty"` // longest a row waits, default 5
+}
+
+// KafkaConfig points at a Kafka cluster and the topic request events are
+// produced to. With a Username the producer authenticates with SASL/PLAIN,
+// reading the password from the environment variable PasswordEnv.
+type KafkaConfig struct {
+	Brokers     []string `json:"brokers"`
+	Topic       string   `json:"topic"`
+	Encoding    string   `json:"encoding,omitempty"` // KafkaJSON (default) or KafkaAvro
+	TLS         bool     `json:"tls,omitempty"`
+	Username    string   `json:"username,omitempty"`
+	PasswordEnv string   `json:"password_env,omitempty"`
+	BatchSize   int      `json:"batch_size,omitempty"` // events per produce request, default 100
+	FlushMs     int      `json:"flush_ms,omitempty"`   // longest an event waits, default 1000
+}
+
+// Kafka event encodings
+const (
+	KafkaJSON = "json"
+	KafkaAvro = "avro"
+)
+
+// PrometheusQuery is a named PromQL expression, e.g.
+// {"name": "cpu", "query": "rate(process_cpu_seconds_total{job=\"api\"}[1m])"}
+type PrometheusQuery struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+// Test modes
+const (
+	ModeHTTP      = ""
+	ModeConnect   = "connect"
+	ModeHandshake = "handshake"
+	ModeCache     = "cache"
+	ModePreflight = "preflight"
+)
+
+// Cache-busting options
+const (
+	CacheBustUnique = "unique"
+	CacheBustReuse  = "reuse"
+)
+
+// Variable represents a definition of a dynamic variable
+type Variable struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`                 // string, integer, float, boolean, uuid, timestamp
+	Strategy   string `json:"strategy"`             // static, sequential, random, uuid, timestamp, template
+	Value      string `json:"value,omitempty"`      // for static
+	StartValue int    `json:"startValue,omitempty"` // for sequential
+	EndValue   int    `json:"endValue,omitempty"`   // for sequential
+	Increment  int    `json:"increment,omitempty"`  // for sequential
+	MinValue   int    `json:"minValue,omitempty"`   // for random
+	MaxValue   int    `json:"maxValue,omitempty"`   // for random
+	Template   string `json:"template,omitempty"`   // for template
+	Sensitive  bool   `json:"sensitive,omitempty"`  // mask the value in logs and results
+}
+
+// TestResult contains the outcome of a performance test
+type TestResult struct {
+	TestConfigurationID string          `json:"test_configuration_id"`
+	URL                 string          `json:"url"`
+	Method              string          `json:"method"`
+	Requests            int             `json:"requests"`
+	Concurrency         int             `json:"concurrency"`
+	SuccessRate         float64         `json:"success_rate"`
+	AvgResponseTime     float64         `json:"avg_response_time"`
+	MinResponseTime     float64         `json:"min_response_time"`
+	MaxResponseTime     float64         `json:"max_response_time"`
+	P50ResponseTime     float64         `json:"p50_response_time"`
+	P90ResponseTime     float64         `json:"p90_response_time"`
+	P95ResponseTime     float64         `json:"p95_response_time"`
+	P99ResponseTime     float64         `json:"p99_response_time"`
+	RequestsPerSecond   float64         `json:"requests_per_second"`
+	TargetRPS           float64         `json:"target_rps,omitempty"`
+	CapacityRPS         float64         `json:"capacity_rps,omitempty"`
+	TunedConcurrency    int             `json:"tuned_concurrency,omitempty"` // concurrency holding TargetP95, from tuning
+	TargetP95           float64         `json:"target_p95,omitempty"`
+	EquilibriumRPS      float64         `json:"equilibrium_rps,omitempty"` // mean throughput the error budget controller settled at
+	ErrorBudget         float64         `json:"error_budget,omitempty"`
+	StatusCodes         map[string]int  `json:"status_codes"`
+	ConnectTime         *LatencyStats   `json:"connect_time,omitempty"`
+	CorrectedLatency    *LatencyStats   `json:"corrected_latency,omitempty"` // paced tests, from each request's scheduled start
+	TLSHandshakeTime    *LatencyStats   `json:"tls_handshake_time,omitempty"`
+	ErrorCounts         map[string]int  `json:"error_counts,omitempty"` // every error, keyed "status: message"
+	Errors              []ErrorData     `json:"errors,omitempty"`       // bounded sample of individual errors
+	Timeline            []TimelinePoint `json:"timeline,omitempty"`
+	Samples             []float64       `json:"samples,omitempty"` // raw latency samples in ms, when requested
+
+	// Cache reports conditional requests in cache mode
+	Cache *CacheStats `json:"cache,omitempty"`
+
+	// Encoding reports response sizes for tests with an AcceptEncoding
+	Encoding *EncodingStats `json:"encoding,omitempty"`
+
+	// Backoff reports rate limiting for tests with Backoff
+	Backoff *BackoffStats `json:"backoff,omitempty"`
+
+	// Retries reports the retried requests of tests with RetryAfter
+	Retries *RetryStats `json:"retries,omitempty"`
+
+	// UserAgents breaks the requests of tests with UserAgents down by the
+	// User-Agent they were sent with
+	UserAgents []UserAgentResult `json:"user_agents,omitempty"`
+
+	// Chaos counts the faults injected into tests with Chaos
+	Chaos *ChaosStats `json:"chaos,omitempty"`
+
+	// Timeouts counts the requests that timed out, by the phase they timed
+	// out in; nil when none did
+	Timeouts *TimeoutStats `json:"timeouts,omitempty"`
+
+	// Variant labels the content-negotiation variant this result is for,
+	// e.g. "Accept: application/xml". Representations counts successful
+	// responses by their media type and Content-Language, for tests that
+	// send an Accept or Accept-Language header.
+	Variant         string         `json:"variant,omitempty"`
+	Representations map[string]int `json:"representations,omitempty"`
+
+	// Steps breaks a scenario's result down by step; Iterations reports its
+	// complete iterations
+	Steps      []EndpointResult `json:"steps,omitempty"`
+	Iterations *IterationStats  `json:"iterations,omitempty"`
+
+	// VUSetup reports the virtual users' setup steps, apart from the load
+	VUSetup *VUSetupStats `json:"vu_setup,omitempty"`
+
+	// Endpoints breaks a multi-endpoint test's result down by endpoint
+	Endpoints []EndpointResult `json:"endpoints,omitempty"`
+
+	// FailedItems lists every value of an each test whose request failed
+	FailedItems []ItemFailure `json:"failed_items,omitempty"`
+
+	// Outlier-resistant latency statistics: samples above OutlierCutoff (the
+	// configured percentile, in ms) are dropped from the trimmed mean and
+	// clamped to the cutoff in the winsorized mean.
+	TrimmedMeanResponseTime    float64 `json:"trimmed_mean_response_time,omitempty"`
+	WinsorizedMeanResponseTime float64 `json:"winsorized_mean_response_time,omitempty"`
+	OutlierCutoff              float64 `json:"outlier_cutoff,omitempty"`
+	OutlierCount               int     `json:"outlier_count"`
+
+	// Generator describes the load generator's own resource use during the run
+	Generator *GeneratorStats `json:"generator,omitempty"`
+
+	// Metadata identifies the code and machine the run was made from
+	Metadata *RunMetadata `json:"metadata,omitempty"`
+
+	// Labels are the run's key=value labels, e.g. release or region, for
+	// filtering runs
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// ServerMetrics holds the test's Prometheus queries evaluated over the run
+	ServerMetrics []ServerMetric `json:"server_metrics,omitempty"`
+}
+
+// CacheStats describes cache validation in cache mode. Conditional requests
+// carried validators from an earlier response; HitRatio is the percentage of
+// them answered 304 Not Modified. Validation and Full are the latencies of
+// conditional and unconditional requests.
+type CacheStats struct {
+	ConditionalRequests int           `json:"conditional_requests"`
+	NotModified         int           `json:"not_modified"`
+	HitRatio            float64       `json:"hit_ratio"`
+	Validation          *LatencyStats `json:"validation,omitempty"`
+	Full                *LatencyStats `json:"full,omitempty"`
+}
+
+// EncodingStats describes the responses to a test sent with an
+// Accept-Encoding header. Served counts responses by their Content-Encoding
+// ("identity" when the header was absent); sizes are of the encoded bodies
+// of successful responses.
+type EncodingStats struct {
+	Accepted   string         `json:"accepted"`
+	Served     map[string]int `json:"served"`
+	TotalBytes int64          `json:"total_bytes"`
+	AvgBytes   float64        `json:"avg_bytes"`
+}
+
+// RunMetadata identifies where a run was made: the buzzbench version, the
+// generator's hostname and, when known, the git commit and branch of the
+// working directory or CI job, and the job's URL. CI names the CI system.
+type RunMetadata struct {
+	Version   string `json:"version"`
+	Hostname  string `json:"hostname,omitempty"`
+	GitCommit string `json:"git_commit,omitempty"`
+	GitBranch string `json:"git_branch,omitempty"`
+	CI        string `json:"ci,omitempty"`
+	CIJobURL  string `json:"ci_job_url,omitempty"`
+}
+
+// BackoffStats describes how a test with Backoff was rate limited.
+// RateLimited counts 429 responses and responses with Retry-After;
+// Throttled counts the requests backoff held back, for ThrottledSecs in
+// total across workers.
+type BackoffStats struct {
+	RateLimited   int     `json:"rate_limited"`
+	Throttled     int     `json:"throttled"`
+	ThrottledSecs float64 `json:"throttled_seconds"`
+}
+
+// RetryStats describes the requests of a test with RetryAfter that were
+// retried. Recovered counts those whose last attempt succeeded; WaitSecs is
+// the total Retry-After time waited across workers.
+type RetryStats struct {
+	Retried   int     `json:"retried"`
+	Recovered int     `json:"recovered"`
+	Retries   int     `json:"retries"`
+	WaitSecs  float64 `json:"wait_seconds"`
+}
+
+// Timeouts are the per-phase limits of a request, in milliseconds. Zero
+// leaves a phase bounded by the whole request's TimeoutSecs only.
+type Timeouts struct {
+	DialMs           int `json:"dial_ms,omitempty"`            // TCP connect
+	TLSHandshakeMs   int `json:"tls_handshake_ms,omitempty"`   // TLS handshake
+	ResponseHeaderMs int `json:"response_header_ms,omitempty"` // from the request written to the response headers
+}
+
+// TimeoutStats counts timed-out requests by phase. Each is also counted as
+// an error, of class "dial_timeout", "tls_timeout", "header_timeout" or
+// "timeout" (the whole request's).
+type TimeoutStats struct {
+	Dial           int `json:"dial"`
+	TLSHandshake   int `json:"tls_handshake"`
+	ResponseHeader int `json:"response_header"`
+	Total          int `json:"total"`
+}
+
+// ChaosStats counts the requests of a test with Chaos that had each fault
+// injected. Dropped and truncated requests are also counted as errors, of
+// class "chaos".
+type ChaosStats struct {
+	Dropped   int `json:"dropped"`
+	Delayed   int `json:"delayed"`
+	Truncated int `json:"truncated"`
+}
+
+// IterationStats counts a scenario's complete iterations, e.g. checkouts.
+// An iteration fails when any of its requests does. Duration runs from its
+// first request to the end of its last step's think time.
+type IterationStats struct {
+	Completed int           `json:"completed"`
+	Failed    int           `json:"failed"`
+	PerSecond float64       `json:"per_second"`
+	Duration  *LatencyStats `json:"duration,omitempty"`
+}
+
+// VUSetupStats counts the virtual users that ran their setup steps. A VU
+// whose setup fails sends no measured requests; Errors counts the failures
+// by message.
+type VUSetupStats struct {
+	VUs      int            `json:"vus"`
+	Failed   int            `json:"failed"`
+	Duration *LatencyStats  `json:"duration,omitempty"`
+	Errors   map[string]int `json:"errors,omitempty"`
+}
+
+// EndpointResult is the share of a multi-endpoint test's requests sent to
+// one endpoint, or of a scenario's sent by one step. Latency covers its
+// completed requests.
+type EndpointResult struct {
+	Name        string        `json:"name,omitempty"` // scenario steps only
+	Method      string        `json:"method"`
+	URL         string        `json:"url"`
+	Requests    int           `json:"requests"`
+	SuccessRate float64       `json:"success_rate"`
+	Latency     *LatencyStats `json:"latency,omitempty"`
+}
+
+// UserAgentResult is the share of a test's requests sent with one
+// User-Agent, labelled by its preset name when it came from one
+type UserAgentResult struct {
+	Agent       string        `json:"agent"`
+	Requests    int           `json:"requests"`
+	SuccessRate float64       `json:"success_rate"`
+	Latency     *LatencyStats `json:"latency,omitempty"`
+}
+
+// ItemFailure is a value of an each test whose request failed, with its
+// error as keyed in ErrorCounts
+type ItemFailure struct {
+	Value string `json:"value"`
+	Error string `json:"error"`
+}
+
+// ServerMetric is one series returned by a Prometheus query
+type ServerMetric struct {
+	Name   string        `json:"name"` // query name plus the series labels
+	Query  string        `json:"query"`
+	Min    float64       `json:"min"`
+	Avg    float64       `json:"avg"`
+	Max    float64       `json:"max"`
+	Points []MetricPoint `json:"points,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// MetricPoint is a sample of a server metric, timestamped in Unix seconds
+type MetricPoint struct {
+	Timestamp float64 `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+// GeneratorStats is the resource use of the machine running buzzbench,
+// sampled once a second during a test. CPU percentages are of all cores.
+type GeneratorStats struct {
+	CPUPercent       float64 `json:"cpu_percent"`
+	PeakCPUPercent   float64 `json:"peak_cpu_percent"`
+	SystemCPUPercent float64 `json:"system_cpu_percent,omitempty"` // whole machine, Linux only
+	PeakMemoryMB     float64 `json:"peak_memory_mb"`
+	PeakGoroutines   int     `json:"peak_goroutines"`
+	NetRxMbps        float64 `json:"net_rx_mbps,omitempty"` // Linux only
+	NetTxMbps        float64 `json:"net_tx_mbps,omitempty"` // Linux only
+	Samples          int     `json:"samples"`
+	// Saturated is set when the generator's CPU peaked high enough that the
+	// results may reflect the client machine's limits rather than the target's
+	Saturated bool `json:"saturated,omitempty"`
+}
+
+// SubmitResponse is returned by the API for a submitted result
+type SubmitResponse struct {
+	ID string `json:"id"`
+}
+
+// ProbeResult is a single availability check produced by monitor mode
+type ProbeResult struct {
+	TestConfigurationID string    `json:"test_configuration_id"`
+	Name                string    `json:"name"`
+	URL                 string    `json:"url"`
+	Timestamp           time.Time `json:"timestamp"`
+	Up                  bool      `json:"up"`
+	Status              int       `json:"status,omitempty"`
+	ResponseTime        float64   `json:"response_time"`
+	Error               string    `json:"error,omitempty"`
+}
+
+// LoginRequest holds the credentials exchanged for an API key
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// LoginResponse is returned by a successful login
+type LoginResponse struct {
+	APIKey   string   `json:"api_key"`
+	Identity Identity `json:"identity"`
+}
+
+// Identity describes who an API key belongs to
+type Identity struct {
+	Email   string `json:"email"`
+	Account string `json:"account"`
+	Project string `json:"project"`
+}
+
+// Release describes a published buzzbench build for one platform
+type Release struct {
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	// SHA256 is the hex checksum of the binary at URL; Signature is the
+	// base64 ed25519 signature of "version|os|arch|sha256".
+	SHA256    string `json:"sha256"`
+	Signature string `json:"signature"`
+}
+
+// LatencyStats describes a latency distribution in milliseconds
+type LatencyStats struct {
+	Count int     `json:"count"`
+	Avg   float64 `json:"avg"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+}
+
+// RequestResult represents the result of a single HTTP request
+type RequestResult struct {
+	Duration  time.Duration
+	Status    int
+	Error     error
+	Timestamp time.Time
+	Intended  time.Time // when a fixed-rate schedule meant the request to start, if any
+	RequestID string    // correlation ID sent with the request, if any
+
+	ServerRequestID string // value of the configured response ID header, if any
+
+	ConnectDuration time.Duration // TCP connect time, when a new connection was opened
+	TLSDuration     time.Duration // TLS handshake time, when a new connection was opened
+
+	Item        string // the each value this request used, if any
+	Endpoint    int    // index of the endpoint of a multi-endpoint test
+	Conditional bool   // sent with cache validators, in cache mode
+
+	BodyBytes       int64  // response body size, when bodies are read
+	ContentEncoding string // the response's Content-Encoding, when bodies are read
+	Representation  string // media type and language served, for negotiated requests
+
+	Throttled   time.Duration // time backoff held the request before sending it
+	RateLimited bool          // the response was a 429 or carried Retry-After
+	Retries     int           // times the request was resent after Retry-After
+	RetryWait   time.Duration // time waited before those retries
+
+	Fault     string // client-side fault injected by chaos, if any
+	UserAgent string // label of the rotated User-Agent sent, if any
+
+	// Set on the last request of a complete scenario iteration
+	IterationDuration time.Duration
+	IterationFailed   bool
+}
+
+// ErrorData represents error information
+type ErrorData struct {
+	Status          string `json:"status,omitempty"`
+	Message         string `json:"message"`
+	RequestID       string `json:"request_id,omitempty"`
+	ServerRequestID string `json:"server_request_id,omitempty"`
+	// Class marks failures of the load generator itself, e.g. "fd_exhausted"
+	// or "ports_exhausted", as opposed to errors caused by the target
+	Class string `json:"class,omitempty"`
+}
+
+// Key groups errors by status and message, as used in TestResult.ErrorCounts
+func (e ErrorData) Key() string {
+	if e.Class != "" {
+		return e.Class + ": " + e.Message
+	}
+	if e.Status == "" {
+		return e.Message
+	}
+	return e.Status + ": " + e.Message
+}
+
+// TimelinePoint represents a data point in the test timeline
+type TimelinePoint struct {
+	Timestamp    float64 `json:"timestamp"`
+	ResponseTime float64 `json:"response_time"`
+	ActiveUsers  float64 `json:"active_users"`
+}
+
+// IntervalStats summarises one interval of a running test, as written to a
+// live metrics file
+type IntervalStats struct {
+	Test      string        `json:"test"`
+	TestID    string        `json:"test_id,omitempty"`
+	Time      time.Time     `json:"time"`
+	Elapsed   float64       `json:"elapsed_seconds"`
+	Done      int           `json:"done"`     // requests done since the test started
+	Total     int           `json:"total"`    // requests the test makes
+	Requests  int           `json:"requests"` // requests done in the interval
+	Errors    int           `json:"errors"`
+	RPS       float64       `json:"rps"`
+	ErrorRate float64       `json:"error_rate"` // percent
+	Latency   *LatencyStats `json:"latency,omitempty"`
+}
+
+// RequestRecord is one request as written to a request log
+type RequestRecord struct {
+	Test       string    `json:"test"`
+	Time       time.Time `json:"time"`
+	DurationMs float64   `json:"duration_ms"`
+	Status     int       `json:"status,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Endpoint   int       `json:"endpoint,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Item       string    `json:"item,omitempty"`
+}
+
+// APIResponse is a generic API response structure
+type APIResponse struct {
+	Tests   []TestConfiguration `json:"tests"`
+	Results []TestResult        `json:"results,omitempty"`
+}
@@ -4,22 +4,98 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
+	"os"
 	"sort"
 	"strings"
 
-	"github.com/lazarkap/buzzbench.io/internal/api"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
 )
 
 // Analyzer provides methods for analyzing test results
 type Analyzer struct {
 	Result api.TestResult
+
+	// Color colors the summary's key metrics by Thresholds. It starts out
+	// on when stdout is a terminal and NO_COLOR isn't set.
+	Color      bool
+	Thresholds Thresholds
+
+	// RegressionPct is how much worse, in percent, CompareWith lets a
+	// metric get before marking it regressed
+	RegressionPct float64
+
+	samples []float64 // raw latencies in ms, sorted
 }
 
-// NewAnalyzer creates a new results analyzer
+// NewAnalyzer creates a new results analyzer. The result's own raw samples,
+// kept with -samples, back the sample statistics.
 func NewAnalyzer(result api.TestResult) *Analyzer {
+	return NewAnalyzerWithSamples(result, result.Samples)
+}
+
+// NewAnalyzerWithSamples creates a results analyzer whose sample statistics
+// come from samples, latencies in ms such as a WithSampleCallback collected
+// for every request, rather than from the result
+func NewAnalyzerWithSamples(result api.TestResult, samples []float64) *Analyzer {
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
 	return &Analyzer{
-		Result: result,
+		Result:        result,
+		Color:         ColorEnabled(os.Stdout),
+		Thresholds:    DefaultThresholds,
+		RegressionPct: DefaultRegressionPct,
+		samples:       sorted,
+	}
+}
+
+// SampleCount returns the number of raw samples the analyzer has
+func (a *Analyzer) SampleCount() int {
+	return len(a.samples)
+}
+
+// Percentile returns the pth percentile (0-100) of the raw samples in ms,
+// interpolating between the closest ranks. Without samples it returns the
+// result's own p50, p90, p95 or p99, and 0 for any other percentile.
+func (a *Analyzer) Percentile(p float64) float64 {
+	if len(a.samples) == 0 {
+		switch p {
+		case 50:
+			return a.Result.P50ResponseTime
+		case 90:
+			return a.Result.P90ResponseTime
+		case 95:
+			return a.Result.P95ResponseTime
+		case 99:
+			return a.Result.P99ResponseTime
+		}
+		return 0
+	}
+	p = math.Max(0, math.Min(100, p))
+	rank := p / 100 * float64(len(a.samples)-1)
+	lo := int(rank)
+	if lo == len(a.samples)-1 {
+		return a.samples[lo]
 	}
+	return a.samples[lo] + (rank-float64(lo))*(a.samples[lo+1]-a.samples[lo])
+}
+
+// StdDev returns the sample standard deviation of the raw samples in ms, 0
+// without samples
+func (a *Analyzer) StdDev() float64 {
+	_, stddev := meanStdDev(a.samples)
+	return stddev
+}
+
+// CV returns the coefficient of variation of the raw samples, their
+// standard deviation over their mean, 0 without samples. Latencies with a
+// CV above 1 vary more than their mean, typically from a long tail.
+func (a *Analyzer) CV() float64 {
+	mean, stddev := meanStdDev(a.samples)
+	if mean == 0 {
+		return 0
+	}
+	return stddev / mean
 }
 
 // PrintSummary prints a summary of the test results to stdout
@@ -29,11 +105,182 @@ func (a *Analyzer) PrintSummary() {
 	fmt.Printf("Method: %s\n", a.Result.Method)
 	fmt.Printf("Requests: %d\n", a.Result.Requests)
 	fmt.Printf("Concurrency: %d\n", a.Result.Concurrency)
-	fmt.Printf("Success Rate: %.2f%%\n", a.Result.SuccessRate)
+	fmt.Printf("Success Rate: %s\n",
+		a.paint(a.Thresholds.SuccessRate.higher(a.Result.SuccessRate), fmt.Sprintf("%.2f%%", a.Result.SuccessRate)))
 	fmt.Printf("Avg Response Time: %.2f ms\n", a.Result.AvgResponseTime)
 	fmt.Printf("Min Response Time: %.2f ms\n", a.Result.MinResponseTime)
 	fmt.Printf("Max Response Time: %.2f ms\n", a.Result.MaxResponseTime)
-	fmt.Printf("Requests Per Second: %.2f\n", a.Result.RequestsPerSecond)
+	fmt.Printf("Percentiles: p50 %.2f ms, p90 %.2f ms, p95 %s, p99 %.2f ms\n",
+		a.Result.P50ResponseTime, a.Result.P90ResponseTime,
+		a.paint(a.Thresholds.P95.lower(a.Result.P95ResponseTime), fmt.Sprintf("%.2f ms", a.Result.P95ResponseTime)),
+		a.Result.P99ResponseTime)
+	if n := len(a.samples); n > 1 {
+		fmt.Printf("Spread: stddev %.2f ms, CV %.2f (from %d samples)\n", a.StdDev(), a.CV(), n)
+	}
+	if a.Result.OutlierCount > 0 {
+		fmt.Printf("Trimmed Mean: %.2f ms, Winsorized Mean: %.2f ms (%d outliers above %.2f ms)\n",
+			a.Result.TrimmedMeanResponseTime, a.Result.WinsorizedMeanResponseTime, a.Result.OutlierCount, a.Result.OutlierCutoff)
+	}
+	fmt.Printf("Requests Per Second: %s\n",
+		a.paint(a.Thresholds.RPS.higher(a.Result.RequestsPerSecond), fmt.Sprintf("%.2f", a.Result.RequestsPerSecond)))
+	if a.Result.TargetRPS > 0 {
+		fmt.Printf("Target Rate: %.2f RPS\n", a.Result.TargetRPS)
+	}
+	if a.Result.CapacityRPS > 0 {
+		fmt.Printf("Capacity: %.2f RPS\n", a.Result.CapacityRPS)
+	}
+
+	a.printLatencyHistogram()
+	a.printThroughput()
+
+	if c := a.Result.CorrectedLatency; c != nil {
+		fmt.Println("\n=== CORRECTED LATENCY ===")
+		printLatencyStats("Corrected", c)
+		// Slow responses held up the sends scheduled behind them; a few ms
+		// are the generator's own timer jitter
+		if behind := c.P99 - a.Result.P99ResponseTime; behind >= 5 && behind > a.Result.P99ResponseTime/10 {
+			fmt.Printf("  Sends fell behind the schedule: corrected p99 is %.2f ms above the raw p99, which\n"+
+				"  understates the latency clients arriving at this rate would see\n", behind)
+		}
+	}
+
+	if a.Result.ConnectTime != nil || a.Result.TLSHandshakeTime != nil {
+		fmt.Println("\n=== CONNECTION TIMING ===")
+		printLatencyStats("TCP Connect", a.Result.ConnectTime)
+		printLatencyStats("TLS Handshake", a.Result.TLSHandshakeTime)
+	}
+
+	if g := a.Result.Generator; g != nil {
+		fmt.Println("\n=== LOAD GENERATOR ===")
+		fmt.Printf("  CPU: avg %.1f%%, peak %.1f%%", g.CPUPercent, g.PeakCPUPercent)
+		if g.SystemCPUPercent > 0 {
+			fmt.Printf(" (machine %.1f%%)", g.SystemCPUPercent)
+		}
+		fmt.Printf("  Memory: %.1f MB  Goroutines: %d\n", g.PeakMemoryMB, g.PeakGoroutines)
+		if g.NetRxMbps > 0 || g.NetTxMbps > 0 {
+			fmt.Printf("  Network: rx %.2f Mbps, tx %.2f Mbps\n", g.NetRxMbps, g.NetTxMbps)
+		}
+		if g.Saturated {
+			fmt.Println("  Warning: the generator's CPU was saturated; results may be limited by this machine, not the target")
+		}
+	}
+
+	if len(a.Result.ServerMetrics) > 0 {
+		fmt.Println("\n=== SERVER METRICS ===")
+		for _, m := range a.Result.ServerMetrics {
+			if m.Error != "" {
+				fmt.Printf("  %s: error: %s\n", m.Name, m.Error)
+				continue
+			}
+			fmt.Printf("  %s: min %.4g  avg %.4g  max %.4g\n", m.Name, m.Min, m.Avg, m.Max)
+		}
+	}
+
+	if c := a.Result.Cache; c != nil {
+		fmt.Println("\n=== CACHE VALIDATION ===")
+		fmt.Printf("  Conditional requests: %d, 304 Not Modified: %d (%.1f%% hit ratio)\n", c.ConditionalRequests, c.NotModified, c.HitRatio)
+		printLatencyStats("Validation", c.Validation)
+		printLatencyStats("Full", c.Full)
+	}
+
+	if len(a.Result.Representations) > 0 {
+		fmt.Println("\n=== REPRESENTATIONS ===")
+		fmt.Printf("  Served: %s\n", shares(a.Result.Representations))
+	}
+
+	if e := a.Result.Encoding; e != nil {
+		fmt.Println("\n=== RESPONSE ENCODING ===")
+		fmt.Printf("  Accept-Encoding: %s  Served: %s\n", e.Accepted, ServedEncodings(e))
+		fmt.Printf("  Avg response size: %.0f bytes  Total: %d bytes\n", e.AvgBytes, e.TotalBytes)
+	}
+
+	if v := a.Result.VUSetup; v != nil {
+		fmt.Println("\n=== VU SETUP ===")
+		fmt.Printf("  Virtual users: %d  Failed setup: %d\n", v.VUs, v.Failed)
+		printLatencyStats("Setup", v.Duration)
+		var msgs []string
+		for msg := range v.Errors {
+			msgs = append(msgs, msg)
+		}
+		sort.Strings(msgs)
+		for _, msg := range msgs {
+			fmt.Printf("  [%d occurrences] %s\n", v.Errors[msg], msg)
+		}
+		if v.Failed > 0 {
+			fmt.Println("  Virtual users that failed setup sent no requests")
+		}
+	}
+
+	if b := a.Result.Backoff; b != nil {
+		fmt.Println("\n=== RATE LIMITING ===")
+		fmt.Printf("  Rate limited responses: %d  Requests held back: %d  Time throttled: %.2f s\n", b.RateLimited, b.Throttled, b.ThrottledSecs)
+	}
+
+	if rt := a.Result.Retries; rt != nil {
+		fmt.Println("\n=== RETRIES ===")
+		fmt.Printf("  Requests retried: %d (%d recovered)  Retries: %d  Waited: %.2f s\n", rt.Retried, rt.Recovered, rt.Retries, rt.WaitSecs)
+	}
+
+	if c := a.Result.Chaos; c != nil {
+		fmt.Println("\n=== CHAOS ===")
+		fmt.Printf("  Injected faults: %d dropped, %d delayed, %d truncated\n", c.Dropped, c.Delayed, c.Truncated)
+		if c.Dropped+c.Truncated > 0 {
+			fmt.Println("  Dropped and truncated requests are counted as errors of class chaos")
+		}
+	}
+
+	if t := a.Result.Timeouts; t != nil {
+		fmt.Println("\n=== TIMEOUTS ===")
+		fmt.Printf("  Connect: %d  TLS handshake: %d  Response headers: %d  Whole request: %d\n",
+			t.Dial, t.TLSHandshake, t.ResponseHeader, t.Total)
+	}
+
+	if it := a.Result.Iterations; it != nil {
+		fmt.Println("\n=== ITERATIONS ===")
+		fmt.Printf("  Completed: %d (%d failed)  Iterations/sec: %.2f\n", it.Completed, it.Failed, it.PerSecond)
+		printLatencyStats("Duration", it.Duration)
+	}
+
+	if len(a.Result.Steps) > 0 {
+		fmt.Println("\n=== STEPS ===")
+		for i, s := range a.Result.Steps {
+			name := s.Name
+			if name == "" {
+				name = fmt.Sprintf("step %d", i+1)
+			}
+			fmt.Printf("  %s (%s %s): %d requests, %.1f%% success", name, s.Method, s.URL, s.Requests, s.SuccessRate)
+			if l := s.Latency; l != nil {
+				fmt.Printf(", avg %.2f ms, p95 %.2f ms", l.Avg, l.P95)
+			}
+			fmt.Println()
+		}
+	}
+
+	if len(a.Result.Endpoints) > 0 {
+		fmt.Println("\n=== ENDPOINTS ===")
+		for _, e := range a.Result.Endpoints {
+			fmt.Printf("  %s %s: %d requests, %.1f%% success", e.Method, e.URL, e.Requests, e.SuccessRate)
+			if l := e.Latency; l != nil {
+				fmt.Printf(", avg %.2f ms, p95 %.2f ms", l.Avg, l.P95)
+			}
+			fmt.Println()
+		}
+	}
+
+	if len(a.Result.UserAgents) > 0 {
+		fmt.Println("\n=== USER AGENTS ===")
+		for _, u := range a.Result.UserAgents {
+			agent := u.Agent
+			if len(agent) > 60 {
+				agent = agent[:57] + "..."
+			}
+			fmt.Printf("  %s: %d requests, %.1f%% success", agent, u.Requests, u.SuccessRate)
+			if l := u.Latency; l != nil {
+				fmt.Printf(", avg %.2f ms, p95 %.2f ms", l.Avg, l.P95)
+			}
+			fmt.Println()
+		}
+	}
 
 	fmt.Println("\n=== STATUS CODES ===")
 	a.printStatusCodes()
@@ -42,6 +289,11 @@ func (a *Analyzer) PrintSummary() {
 		fmt.Println("\n=== ERRORS ===")
 		a.printErrors()
 	}
+
+	if len(a.Result.FailedItems) > 0 {
+		fmt.Printf("\n=== FAILED ITEMS (%d) ===\n", len(a.Result.FailedItems))
+		a.printFailedItems()
+	}
 }
 
 // SaveJSON saves the test results to a JSON file
@@ -58,6 +310,15 @@ func (a *Analyzer) SaveJSON(filePath string) error {
 	return nil
 }
 
+// printLatencyStats prints a one-line distribution summary, if stats are present
+func printLatencyStats(label string, s *api.LatencyStats) {
+	if s == nil {
+		return
+	}
+	fmt.Printf("  %-14s avg %.2f ms  min %.2f  p50 %.2f  p95 %.2f  p99 %.2f  max %.2f  (n=%d)\n",
+		label+":", s.Avg, s.Min, s.P50, s.P95, s.P99, s.Max, s.Count)
+}
+
 // printStatusCodes prints the status code distribution
 func (a *Analyzer) printStatusCodes() {
 	if len(a.Result.StatusCodes) == 0 {
@@ -81,6 +342,10 @@ func (a *Analyzer) printStatusCodes() {
 		codeType := getStatusCodeType(code)
 		fmt.Printf("  %s: %d (%.1f%%) - %s\n", code, count, percentage, codeType)
 	}
+
+	if throttled := a.GetStatusCodeCounts()["throttled"]; throttled > 0 {
+		fmt.Printf("  Throttled/unavailable (429, 503): %d (%.1f%%)\n", throttled, float64(throttled)/float64(totalRequests)*100)
+	}
 }
 
 // printErrors prints error information
@@ -89,11 +354,20 @@ func (a *Analyzer) printErrors() {
 		return
 	}
 
-	// Group errors by message
-	errorCounts := make(map[string]int)
+	// Group errors by message, keeping one request ID per group for lookup.
+	// ErrorCounts covers every error; Errors may only be a sample of them.
+	errorCounts := a.Result.ErrorCounts
+	if len(errorCounts) == 0 {
+		errorCounts = make(map[string]int)
+		for _, err := range a.Result.Errors {
+			errorCounts[err.Key()]++
+		}
+	}
+	sampleIDs := make(map[string]string)
 	for _, err := range a.Result.Errors {
-		key := fmt.Sprintf("%s: %s", err.Status, err.Message)
-		errorCounts[key]++
+		if key := err.Key(); sampleIDs[key] == "" {
+			sampleIDs[key] = describeRequestIDs(err)
+		}
 	}
 
 	// Sort error messages for consistent output
@@ -107,22 +381,55 @@ func (a *Analyzer) printErrors() {
 	for _, msg := range errorMessages {
 		count := errorCounts[msg]
 		fmt.Printf("  [%d occurrences] %s\n", count, msg)
+		if ids := sampleIDs[msg]; ids != "" {
+			fmt.Printf("      e.g. %s\n", ids)
+		}
 	}
 }
 
-// GetStatusCodeCounts returns counts grouped by status code type
+// maxFailedItemsShown bounds the failed items printed; the JSON output has them all
+const maxFailedItemsShown = 20
+
+// printFailedItems prints the values of an each test whose requests failed
+func (a *Analyzer) printFailedItems() {
+	for i, f := range a.Result.FailedItems {
+		if i == maxFailedItemsShown {
+			fmt.Printf("  ... and %d more (see -out or -json)\n", len(a.Result.FailedItems)-i)
+			return
+		}
+		fmt.Printf("  %s: %s\n", f.Value, f.Error)
+	}
+}
+
+// describeRequestIDs formats the client and server request IDs of an error, if any
+func describeRequestIDs(e api.ErrorData) string {
+	var parts []string
+	if e.RequestID != "" {
+		parts = append(parts, "request ID "+e.RequestID)
+	}
+	if e.ServerRequestID != "" {
+		parts = append(parts, "server request ID "+e.ServerRequestID)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// GetStatusCodeCounts returns counts grouped by status code type. 429 and
+// 503 count as "throttled" rather than as client or server errors.
 func (a *Analyzer) GetStatusCodeCounts() map[string]int {
 	result := map[string]int{
 		"success":     0,
 		"redirection": 0,
 		"clientError": 0,
 		"serverError": 0,
+		"throttled":   0,
 		"unknown":     0,
 	}
 
 	for code, count := range a.Result.StatusCodes {
 		codeType := getStatusCodeType(code)
 		switch {
+		case strings.Contains(codeType, "Throttled"):
+			result["throttled"] += count
 		case strings.Contains(codeType, "Success"):
 			result["success"] += count
 		case strings.Contains(codeType, "Redirection"):
@@ -175,6 +482,12 @@ func (a *Analyzer) GetPerformanceGrade() string {
 
 // getStatusCodeType returns a human-readable status code type
 func getStatusCodeType(code string) string {
+	// 429 and 503 ask the client to come back later; they say the target is
+	// shedding load rather than that the request or the server is broken
+	if code == "429" || code == "503" {
+		return "Throttled/Unavailable"
+	}
+
 	// Remove all non-digit characters to handle format variations
 	codeDigits := code
 	if len(codeDigits) > 0 {
@@ -0,0 +1,142 @@
+package results
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/wcharczuk/go-chart/v2"
+)
+
+// Chart image size in pixels
+const (
+	chartWidth  = 960
+	chartHeight = 480
+)
+
+// SaveLatencyChart renders the latency distribution of the raw samples as a
+// bar chart, binned like the summary's histogram. The file's extension,
+// .png or .svg, picks the format. It fails without samples.
+func (a *Analyzer) SaveLatencyChart(path string) error {
+	hist := a.LatencyHistogram(histogramBins)
+	if len(hist) == 0 {
+		return fmt.Errorf("no raw samples to chart (run with -samples N)")
+	}
+	bars := make([]chart.Value, len(hist))
+	peak := 0.0
+	for i, b := range hist {
+		label := fmt.Sprintf("≤ %.3g", b.High)
+		if i == histogramBins {
+			label = "tail"
+		}
+		bars[i] = chart.Value{Label: label, Value: float64(b.Count), Style: barStyle}
+		peak = math.Max(peak, float64(b.Count))
+	}
+	graph := chart.BarChart{
+		Title:      fmt.Sprintf("%s %s: latency (ms) of %d samples", a.Result.Method, a.Result.URL, len(a.samples)),
+		Width:      chartWidth,
+		Height:     chartHeight,
+		BarWidth:   chartWidth / (len(bars) + 1) * 3 / 4,
+		BarSpacing: chartWidth / (len(bars) + 1) / 4,
+		Background: chart.Style{Padding: chart.Box{Top: 40, Left: 10, Right: 10, Bottom: 30}},
+		YAxis:      chart.YAxis{Range: &chart.ContinuousRange{Max: peak}, ValueFormatter: chart.IntValueFormatter},
+		Bars:       bars,
+	}
+	return renderChart(path, graph.Render)
+}
+
+// SaveTimelineChart renders requests per second and mean latency over the
+// run as lines against seconds since its start. The file's extension, .png
+// or .svg, picks the format. It fails for runs shorter than two seconds.
+func (a *Analyzer) SaveTimelineChart(path string) error {
+	rps := perSecond(a.Result.Timeline)
+	if len(rps) < 2 {
+		return fmt.Errorf("the run is too short to chart over time")
+	}
+	seconds := make([]float64, len(rps))
+	for i := range seconds {
+		seconds[i] = float64(i)
+	}
+	start := a.Result.Timeline[0].Timestamp
+	var latencyAt, latency []float64
+	for _, pt := range a.Result.Timeline {
+		latencyAt = append(latencyAt, pt.Timestamp-start)
+		latency = append(latency, pt.ResponseTime)
+	}
+	if len(latency) < 2 {
+		// A line needs two points; the run only answered in one second
+		latencyAt = append(latencyAt, latencyAt[0])
+		latency = append(latency, latency[0])
+	}
+
+	peakRPS, peakLatency := 0.0, 0.0
+	for _, v := range rps {
+		peakRPS = math.Max(peakRPS, v)
+	}
+	for _, v := range latency {
+		peakLatency = math.Max(peakLatency, v)
+	}
+
+	graph := chart.Chart{
+		Title:          fmt.Sprintf("%s %s over time", a.Result.Method, a.Result.URL),
+		Width:          chartWidth,
+		Height:         chartHeight,
+		Background:     chart.Style{Padding: chart.Box{Top: 50, Left: 20, Right: 20, Bottom: 10}},
+		XAxis:          chart.XAxis{Name: "seconds"},
+		YAxis:          chart.YAxis{Name: "requests/s", Range: zeroBased(peakRPS), ValueFormatter: shortFloat},
+		YAxisSecondary: chart.YAxis{Name: "mean latency (ms)", Range: zeroBased(peakLatency), ValueFormatter: shortFloat},
+		Series: []chart.Series{
+			chart.ContinuousSeries{Name: "requests/s", XValues: seconds, YValues: rps},
+			chart.ContinuousSeries{Name: "mean latency (ms)", YAxis: chart.YAxisSecondary, XValues: latencyAt, YValues: latency},
+		},
+	}
+	graph.Elements = []chart.Renderable{chart.Legend(&graph)}
+	return renderChart(path, graph.Render)
+}
+
+// barStyle draws every bar of the latency chart in one color
+var barStyle = chart.Style{FillColor: chart.ColorBlue, StrokeColor: chart.ColorBlue}
+
+// zeroBased returns an axis range from 0 to peak, so a flat line isn't
+// drawn as swings
+func zeroBased(peak float64) chart.Range {
+	if peak <= 0 {
+		peak = 1
+	}
+	return &chart.ContinuousRange{Max: peak}
+}
+
+// shortFloat labels an axis with three significant digits
+func shortFloat(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'g', 3, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// renderChart writes a chart to path in the format its extension names
+func renderChart(path string, render func(chart.RendererProvider, io.Writer) error) error {
+	var format chart.RendererProvider
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		format = chart.PNG
+	case ".svg":
+		format = chart.SVG
+	default:
+		return fmt.Errorf("unsupported chart format %q (want .png or .svg)", filepath.Ext(path))
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := render(format, f); err != nil {
+		f.Close()
+		return fmt.Errorf("render %s: %w", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,82 @@
+package results
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+// ANSI colors of the summary's key metrics
+const (
+	colorGreen = "\033[32m"
+	colorAmber = "\033[33m"
+	colorRed   = "\033[31m"
+	colorReset = "\033[0m"
+)
+
+// Threshold colors a metric green up to Good, amber up to Warn and red
+// past it. For metrics where higher is better Good is the larger of the
+// two, and for latencies the smaller. The zero Threshold leaves the metric
+// uncolored.
+type Threshold struct {
+	Good float64 `json:"good"`
+	Warn float64 `json:"warn"`
+}
+
+// Thresholds color the summary's success rate (%), p95 (ms) and requests
+// per second
+type Thresholds struct {
+	SuccessRate Threshold `json:"success_rate"`
+	P95         Threshold `json:"p95"`
+	RPS         Threshold `json:"rps"`
+}
+
+// DefaultThresholds color the success rate and p95 of every summary.
+// Throughput depends too much on the target for a default.
+var DefaultThresholds = Thresholds{
+	SuccessRate: Threshold{Good: 99, Warn: 95},
+	P95:         Threshold{Good: 100, Warn: 1000},
+}
+
+// ColorEnabled reports whether output to f should be colored: f is a
+// terminal and NO_COLOR isn't set
+func ColorEnabled(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// higher returns the color of a metric where higher is better
+func (t Threshold) higher(v float64) string {
+	switch {
+	case t == Threshold{}:
+		return ""
+	case v >= t.Good:
+		return colorGreen
+	case v >= t.Warn:
+		return colorAmber
+	}
+	return colorRed
+}
+
+// lower returns the color of a metric where lower is better
+func (t Threshold) lower(v float64) string {
+	switch {
+	case t == Threshold{}:
+		return ""
+	case v <= t.Good:
+		return colorGreen
+	case v <= t.Warn:
+		return colorAmber
+	}
+	return colorRed
+}
+
+// paint wraps s in color, when the analyzer colors its output
+func (a *Analyzer) paint(color, s string) string {
+	if !a.Color || color == "" {
+		return s
+	}
+	return color + s + colorReset
+}
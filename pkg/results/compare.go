@@ -0,0 +1,137 @@
+package results
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// DefaultRegressionPct is how much worse, in percent, a metric must get
+// before a comparison marks it as regressed
+const DefaultRegressionPct = 5
+
+// MetricDelta is the change of one metric from a baseline to a candidate
+type MetricDelta struct {
+	Name      string  `json:"name"`
+	Unit      string  `json:"unit,omitempty"`
+	Baseline  float64 `json:"baseline"`
+	Candidate float64 `json:"candidate"`
+	Delta     float64 `json:"delta"`
+	Percent   float64 `json:"percent"` // 0 when the baseline is 0
+	Regressed bool    `json:"regressed"`
+	Improved  bool    `json:"improved"`
+}
+
+// Comparison is a candidate result set against a baseline
+type Comparison struct {
+	Deltas []MetricDelta `json:"deltas"`
+	// Significance tests the latency difference when both results kept
+	// raw samples
+	Significance *MannWhitneyResult `json:"significance,omitempty"`
+
+	color bool
+}
+
+// compareMetrics lists the metrics compared, and whether higher is better
+var compareMetrics = []struct {
+	name, unit   string
+	higherBetter bool
+	value        func(api.TestResult) float64
+}{
+	{"Requests/sec", "", true, func(r api.TestResult) float64 { return r.RequestsPerSecond }},
+	{"Avg response", "ms", false, func(r api.TestResult) float64 { return r.AvgResponseTime }},
+	{"p50 response", "ms", false, func(r api.TestResult) float64 { return r.P50ResponseTime }},
+	{"p95 response", "ms", false, func(r api.TestResult) float64 { return r.P95ResponseTime }},
+	{"p99 response", "ms", false, func(r api.TestResult) float64 { return r.P99ResponseTime }},
+	{"Success rate", "%", true, func(r api.TestResult) float64 { return r.SuccessRate }},
+}
+
+// CompareWith compares the analyzer's result, the candidate, against a
+// baseline. A metric that got worse by more than RegressionPct percent is
+// marked regressed, one that got better by as much improved.
+func (a *Analyzer) CompareWith(baseline api.TestResult) *Comparison {
+	c := &Comparison{color: a.Color}
+	for _, m := range compareMetrics {
+		d := MetricDelta{
+			Name:      m.name,
+			Unit:      m.unit,
+			Baseline:  m.value(baseline),
+			Candidate: m.value(a.Result),
+		}
+		d.Delta = d.Candidate - d.Baseline
+		if d.Baseline != 0 {
+			d.Percent = d.Delta / d.Baseline * 100
+		}
+		worse := d.Percent
+		if m.higherBetter {
+			worse = -worse
+		}
+		d.Regressed = worse > a.RegressionPct
+		d.Improved = -worse > a.RegressionPct
+		c.Deltas = append(c.Deltas, d)
+	}
+	if len(baseline.Samples) > 0 && len(a.Result.Samples) > 0 {
+		mw := MannWhitneyU(baseline.Samples, a.Result.Samples)
+		c.Significance = &mw
+	}
+	return c
+}
+
+// Delta returns the change of the named metric, as listed in Deltas
+func (c *Comparison) Delta(name string) (MetricDelta, bool) {
+	for _, d := range c.Deltas {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return MetricDelta{}, false
+}
+
+// String formats the comparison as an aligned text table for a terminal
+func (c *Comparison) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "  %-20s %12s %12s %12s %9s\n", "Metric", "Baseline", "Candidate", "Change", "Change %")
+	for _, d := range c.Deltas {
+		fmt.Fprintf(&b, "  %-20s %12.2f %12.2f %+12.2f %+8.1f%%", d.label(), d.Baseline, d.Candidate, d.Delta, d.Percent)
+		switch {
+		case d.Regressed && c.color:
+			b.WriteString("  " + colorRed + "REGRESSED" + colorReset)
+		case d.Regressed:
+			b.WriteString("  REGRESSED")
+		case d.Improved && c.color:
+			b.WriteString("  " + colorGreen + "improved" + colorReset)
+		case d.Improved:
+			b.WriteString("  improved")
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Markdown formats the comparison as a Markdown table, for reports and pull
+// request comments
+func (c *Comparison) Markdown() string {
+	var b strings.Builder
+	b.WriteString("| Metric | Baseline | Candidate | Change | Change % | |\n")
+	b.WriteString("|---|---:|---:|---:|---:|---|\n")
+	for _, d := range c.Deltas {
+		marker := ""
+		switch {
+		case d.Regressed:
+			marker = "🔴 regressed"
+		case d.Improved:
+			marker = "🟢 improved"
+		}
+		fmt.Fprintf(&b, "| %s | %.2f | %.2f | %+.2f | %+.1f%% | %s |\n", d.label(), d.Baseline, d.Candidate, d.Delta, d.Percent, marker)
+	}
+	return b.String()
+}
+
+// label returns the metric's name with its unit
+func (d MetricDelta) label() string {
+	if d.Unit == "" {
+		return d.Name
+	}
+	return fmt.Sprintf("%s (%s)", d.Name, d.Unit)
+}
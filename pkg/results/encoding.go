@@ -0,0 +1,73 @@
+package results
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// ServedEncodings lists the Content-Encodings a test's responses came back
+// with, most common first, e.g. "gzip 98%, identity 2%"
+func ServedEncodings(e *api.EncodingStats) string {
+	return shares(e.Served)
+}
+
+// shares formats counts as percentages of their total, largest first
+func shares(counts map[string]int) string {
+	total := 0
+	names := make([]string, 0, len(counts))
+	for name, n := range counts {
+		total += n
+		names = append(names, name)
+	}
+	if total == 0 {
+		return "-"
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s %.0f%%", name, float64(counts[name])/float64(total)*100)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// PrintEncodingTable prints one row per Accept-Encoding of an encoding
+// matrix. Ratio is each row's average response size relative to the
+// identity row, or to the largest when identity wasn't tested.
+func PrintEncodingTable(name string, runs []api.TestResult) {
+	fmt.Printf("\n=== ENCODING MATRIX: %s ===\n", name)
+	fmt.Printf("  %-10s  %-18s  %12s  %6s  %10s  %10s  %10s  %8s\n",
+		"Accept", "Served", "Avg size (B)", "Ratio", "Avg (ms)", "p95 (ms)", "RPS", "Errors")
+
+	var base float64
+	for _, r := range runs {
+		if r.Encoding == nil {
+			continue
+		}
+		if r.Encoding.Accepted == "identity" {
+			base = r.Encoding.AvgBytes
+			break
+		}
+		base = max(base, r.Encoding.AvgBytes)
+	}
+
+	for _, r := range runs {
+		if r.Encoding == nil {
+			continue
+		}
+		ratio := "-"
+		if base > 0 {
+			ratio = fmt.Sprintf("%.2f", r.Encoding.AvgBytes/base)
+		}
+		fmt.Printf("  %-10s  %-18s  %12.0f  %6s  %10.2f  %10.2f  %10.2f  %7.2f%%\n",
+			r.Encoding.Accepted, ServedEncodings(r.Encoding), r.Encoding.AvgBytes, ratio,
+			r.AvgResponseTime, r.P95ResponseTime, r.RequestsPerSecond, ErrorRate(r))
+	}
+}
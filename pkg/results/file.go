@@ -0,0 +1,34 @@
+package results
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// LoadFile reads a results file written with -out. Both a single result
+// object and an array of results are accepted.
+func LoadFile(path string) ([]api.TestResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %q: %w", path, err)
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var list []api.TestResult
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("parse %q: %w", path, err)
+		}
+		return list, nil
+	}
+
+	var single api.TestResult
+	if err := json.Unmarshal(data, &single); err != nil {
+		return nil, fmt.Errorf("parse %q: %w", path, err)
+	}
+	return []api.TestResult{single}, nil
+}
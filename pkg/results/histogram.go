@@ -0,0 +1,145 @@
+package results
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// Terminal chart sizes
+const (
+	histogramBins  = 10
+	histogramWidth = 40 // characters of the longest bar
+	sparklineWidth = 60 // at most one character per second up to this
+)
+
+// sparkTicks are the sparkline's levels, lowest first
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// HistogramBin counts the samples from Low up to High ms
+type HistogramBin struct {
+	Low   float64 `json:"low"`
+	High  float64 `json:"high"`
+	Count int     `json:"count"`
+}
+
+// LatencyHistogram splits the raw samples into bins of equal width from the
+// fastest sample to the p99. Slower samples go into a last bin that ends at
+// the slowest one, so a long tail doesn't squeeze the rest into one bar.
+// It returns nil without samples.
+func (a *Analyzer) LatencyHistogram(bins int) []HistogramBin {
+	if len(a.samples) == 0 || bins < 1 {
+		return nil
+	}
+	low, high := a.samples[0], a.Percentile(99)
+	slowest := a.samples[len(a.samples)-1]
+	if high <= low {
+		return []HistogramBin{{Low: low, High: slowest, Count: len(a.samples)}}
+	}
+
+	width := (high - low) / float64(bins)
+	hist := make([]HistogramBin, bins)
+	for i := range hist {
+		hist[i].Low = low + float64(i)*width
+		hist[i].High = low + float64(i+1)*width
+	}
+	var tail HistogramBin
+	if slowest > high {
+		tail = HistogramBin{Low: high, High: slowest}
+	}
+	for _, v := range a.samples {
+		if v > high {
+			tail.Count++
+			continue
+		}
+		hist[min(int((v-low)/width), bins-1)].Count++
+	}
+	if tail.Count > 0 {
+		hist = append(hist, tail)
+	}
+	return hist
+}
+
+// printLatencyHistogram prints the shape of the latency distribution as bars
+func (a *Analyzer) printLatencyHistogram() {
+	hist := a.LatencyHistogram(histogramBins)
+	if len(hist) == 0 {
+		return
+	}
+	peak := 0
+	for _, b := range hist {
+		peak = max(peak, b.Count)
+	}
+	fmt.Printf("\n=== LATENCY DISTRIBUTION (%d samples) ===\n", len(a.samples))
+	for _, b := range hist {
+		bar := strings.Repeat("#", int(math.Round(float64(b.Count)/float64(peak)*histogramWidth)))
+		if bar == "" && b.Count > 0 {
+			bar = "."
+		}
+		fmt.Printf("  %9.2f - %9.2f ms  %-*s %6d (%.1f%%)\n",
+			b.Low, b.High, histogramWidth, bar, b.Count, float64(b.Count)/float64(len(a.samples))*100)
+	}
+}
+
+// printThroughput prints requests per second over the run as a sparkline
+func (a *Analyzer) printThroughput() {
+	rps := perSecond(a.Result.Timeline)
+	if len(rps) < 2 {
+		return
+	}
+	lowest, highest := rps[0], rps[0]
+	for _, v := range rps {
+		lowest = math.Min(lowest, v)
+		highest = math.Max(highest, v)
+	}
+	fmt.Println("\n=== THROUGHPUT ===")
+	fmt.Printf("  RPS %s  min %.0f  max %.0f  (%d s)\n", sparkline(rps, sparklineWidth), lowest, highest, len(rps))
+}
+
+// perSecond returns the requests of every second of the timeline, including
+// the seconds without any
+func perSecond(timeline []api.TimelinePoint) []float64 {
+	if len(timeline) == 0 {
+		return nil
+	}
+	start := timeline[0].Timestamp
+	counts := make([]float64, int(timeline[len(timeline)-1].Timestamp-start)+1)
+	for _, pt := range timeline {
+		counts[int(pt.Timestamp-start)] += pt.ActiveUsers
+	}
+	return counts
+}
+
+// sparkline draws values as at most width characters, averaging
+// neighbouring values when there are more
+func sparkline(values []float64, width int) string {
+	if len(values) > width {
+		averaged := make([]float64, width)
+		for i := range averaged {
+			from, to := i*len(values)/width, (i+1)*len(values)/width
+			sum := 0.0
+			for _, v := range values[from:to] {
+				sum += v
+			}
+			averaged[i] = sum / float64(to-from)
+		}
+		values = averaged
+	}
+
+	lowest, highest := values[0], values[0]
+	for _, v := range values {
+		lowest = math.Min(lowest, v)
+		highest = math.Max(highest, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		level := len(sparkTicks) - 1
+		if highest > lowest {
+			level = int((v - lowest) / (highest - lowest) * float64(len(sparkTicks)-1))
+		}
+		b.WriteRune(sparkTicks[level])
+	}
+	return b.String()
+}
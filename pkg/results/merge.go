@@ -0,0 +1,319 @@
+package results
+
+import (
+	"math"
+	"sort"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// Merge combines results of the same test run from several machines at
+// once into one. Counts add up; the success rate and mean latency are
+// weighted by requests; timelines are joined second by second and the
+// throughput is taken over the combined window. Latency percentiles are
+// recomputed from the raw samples when every part kept some (-samples),
+// each sample weighted by the requests it stands for; exact is false when
+// they had to be approximated by request-weighted means of each part's
+// percentiles. Breakdowns by endpoint, step or User-Agent and the
+// generator's own telemetry describe a single machine and are left out.
+func Merge(parts []api.TestResult) (merged api.TestResult, exact bool) {
+	if len(parts) == 0 {
+		return api.TestResult{}, false
+	}
+	first := parts[0]
+	merged = api.TestResult{
+		TestConfigurationID: first.TestConfigurationID,
+		URL:                 first.URL,
+		Method:              first.Method,
+		Variant:             first.Variant,
+		MinResponseTime:     first.MinResponseTime,
+		StatusCodes:         make(map[string]int),
+		ErrorCounts:         make(map[string]int),
+		Labels:              commonLabels(parts),
+	}
+
+	var successes, latencySum float64
+	for _, p := range parts {
+		merged.Requests += p.Requests
+		merged.Concurrency += p.Concurrency
+		merged.TargetRPS += p.TargetRPS
+		successes += p.SuccessRate / 100 * float64(p.Requests)
+		latencySum += p.AvgResponseTime * float64(p.Requests)
+		merged.MinResponseTime = math.Min(merged.MinResponseTime, p.MinResponseTime)
+		merged.MaxResponseTime = math.Max(merged.MaxResponseTime, p.MaxResponseTime)
+		addCounts(merged.StatusCodes, p.StatusCodes)
+		addCounts(merged.ErrorCounts, p.ErrorCounts)
+		merged.Errors = append(merged.Errors, p.Errors...)
+		merged.FailedItems = append(merged.FailedItems, p.FailedItems...)
+		merged.OutlierCount += p.OutlierCount
+		if len(p.Representations) > 0 {
+			if merged.Representations == nil {
+				merged.Representations = make(map[string]int)
+			}
+			addCounts(merged.Representations, p.Representations)
+		}
+		mergeCounters(&merged, p)
+	}
+	if merged.Requests > 0 {
+		merged.SuccessRate = successes / float64(merged.Requests) * 100
+		merged.AvgResponseTime = latencySum / float64(merged.Requests)
+	}
+
+	merged.Timeline = mergeTimelines(parts)
+	merged.RequestsPerSecond = mergedThroughput(parts, merged.Requests)
+	merged.ConnectTime = mergeLatency(parts, func(r api.TestResult) *api.LatencyStats { return r.ConnectTime })
+	merged.TLSHandshakeTime = mergeLatency(parts, func(r api.TestResult) *api.LatencyStats { return r.TLSHandshakeTime })
+	merged.CorrectedLatency = mergeLatency(parts, func(r api.TestResult) *api.LatencyStats { return r.CorrectedLatency })
+
+	exact = true
+	for _, p := range parts {
+		if len(p.Samples) == 0 && p.Requests > 0 {
+			exact = false
+		}
+	}
+	if exact {
+		samples, weights := weightedSamples(parts)
+		merged.P50ResponseTime = weightedPercentile(samples, weights, 50)
+		merged.P90ResponseTime = weightedPercentile(samples, weights, 90)
+		merged.P95ResponseTime = weightedPercentile(samples, weights, 95)
+		merged.P99ResponseTime = weightedPercentile(samples, weights, 99)
+		merged.Samples = resample(parts)
+	} else {
+		merged.P50ResponseTime = weightedMean(parts, func(r api.TestResult) float64 { return r.P50ResponseTime })
+		merged.P90ResponseTime = weightedMean(parts, func(r api.TestResult) float64 { return r.P90ResponseTime })
+		merged.P95ResponseTime = weightedMean(parts, func(r api.TestResult) float64 { return r.P95ResponseTime })
+		merged.P99ResponseTime = weightedMean(parts, func(r api.TestResult) float64 { return r.P99ResponseTime })
+	}
+	return merged, exact
+}
+
+func addCounts(dst, src map[string]int) {
+	for k, n := range src {
+		dst[k] += n
+	}
+}
+
+// mergeCounters adds up the optional fault, retry and timeout counters
+func mergeCounters(merged *api.TestResult, p api.TestResult) {
+	if p.Timeouts != nil {
+		if merged.Timeouts == nil {
+			merged.Timeouts = &api.TimeoutStats{}
+		}
+		merged.Timeouts.Dial += p.Timeouts.Dial
+		merged.Timeouts.TLSHandshake += p.Timeouts.TLSHandshake
+		merged.Timeouts.ResponseHeader += p.Timeouts.ResponseHeader
+		merged.Timeouts.Total += p.Timeouts.Total
+	}
+	if p.Backoff != nil {
+		if merged.Backoff == nil {
+			merged.Backoff = &api.BackoffStats{}
+		}
+		merged.Backoff.RateLimited += p.Backoff.RateLimited
+		merged.Backoff.Throttled += p.Backoff.Throttled
+		merged.Backoff.ThrottledSecs += p.Backoff.ThrottledSecs
+	}
+	if p.Retries != nil {
+		if merged.Retries == nil {
+			merged.Retries = &api.RetryStats{}
+		}
+		merged.Retries.Retried += p.Retries.Retried
+		merged.Retries.Recovered += p.Retries.Recovered
+		merged.Retries.Retries += p.Retries.Retries
+		merged.Retries.WaitSecs += p.Retries.WaitSecs
+	}
+	if p.Chaos != nil {
+		if merged.Chaos == nil {
+			merged.Chaos = &api.ChaosStats{}
+		}
+		merged.Chaos.Dropped += p.Chaos.Dropped
+		merged.Chaos.Delayed += p.Chaos.Delayed
+		merged.Chaos.Truncated += p.Chaos.Truncated
+	}
+}
+
+// commonLabels returns the labels every part has with the same value
+func commonLabels(parts []api.TestResult) map[string]string {
+	var common map[string]string
+	for k, v := range parts[0].Labels {
+		shared := true
+		for _, p := range parts[1:] {
+			if p.Labels[k] != v {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			if common == nil {
+				common = make(map[string]string)
+			}
+			common[k] = v
+		}
+	}
+	return common
+}
+
+// mergeTimelines joins the parts' per-second timelines. Points of the same
+// second add their requests, and their mean latencies are weighted by them.
+func mergeTimelines(parts []api.TestResult) []api.TimelinePoint {
+	bySecond := make(map[float64]*api.TimelinePoint)
+	for _, p := range parts {
+		for _, pt := range p.Timeline {
+			m := bySecond[pt.Timestamp]
+			if m == nil {
+				m = &api.TimelinePoint{Timestamp: pt.Timestamp}
+				bySecond[pt.Timestamp] = m
+			}
+			total := m.ActiveUsers + pt.ActiveUsers
+			if total > 0 {
+				m.ResponseTime = (m.ResponseTime*m.ActiveUsers + pt.ResponseTime*pt.ActiveUsers) / total
+			}
+			m.ActiveUsers = total
+		}
+	}
+	timeline := make([]api.TimelinePoint, 0, len(bySecond))
+	for _, pt := range bySecond {
+		timeline = append(timeline, *pt)
+	}
+	sort.Slice(timeline, func(i, j int) bool { return timeline[i].Timestamp < timeline[j].Timestamp })
+	return timeline
+}
+
+// mergedThroughput divides all requests by the time from the earliest
+// part's start to the latest part's end. A part starts at its first
+// timeline second and lasts its requests over its throughput. Without
+// timelines the parts are taken to have run at the same time.
+func mergedThroughput(parts []api.TestResult, requests int) float64 {
+	start, end := math.Inf(1), math.Inf(-1)
+	sum := 0.0
+	windowed := true
+	for _, p := range parts {
+		sum += p.RequestsPerSecond
+		if len(p.Timeline) == 0 || p.RequestsPerSecond <= 0 {
+			windowed = false
+			continue
+		}
+		s := p.Timeline[0].Timestamp
+		start = math.Min(start, s)
+		end = math.Max(end, s+float64(p.Requests)/p.RequestsPerSecond)
+	}
+	if !windowed || end <= start {
+		return sum
+	}
+	return float64(requests) / (end - start)
+}
+
+// weightedMean returns a metric's mean over the parts, weighted by requests
+func weightedMean(parts []api.TestResult, metric func(api.TestResult) float64) float64 {
+	var sum, n float64
+	for _, p := range parts {
+		sum += metric(p) * float64(p.Requests)
+		n += float64(p.Requests)
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / n
+}
+
+// mergeLatency combines one latency breakdown of the parts. Counts add up
+// and the extremes are exact; the mean is weighted by count, and so are the
+// percentiles, which only approximates them.
+func mergeLatency(parts []api.TestResult, stats func(api.TestResult) *api.LatencyStats) *api.LatencyStats {
+	var m *api.LatencyStats
+	var n float64
+	for _, p := range parts {
+		s := stats(p)
+		if s == nil || s.Count == 0 {
+			continue
+		}
+		if m == nil {
+			m = &api.LatencyStats{Min: s.Min}
+		}
+		w := float64(s.Count)
+		m.Count += s.Count
+		m.Avg += s.Avg * w
+		m.P50 += s.P50 * w
+		m.P90 += s.P90 * w
+		m.P95 += s.P95 * w
+		m.P99 += s.P99 * w
+		m.Min = math.Min(m.Min, s.Min)
+		m.Max = math.Max(m.Max, s.Max)
+		n += w
+	}
+	if m == nil {
+		return nil
+	}
+	m.Avg /= n
+	m.P50 /= n
+	m.P90 /= n
+	m.P95 /= n
+	m.P99 /= n
+	return m
+}
+
+// weightedSamples returns every part's samples sorted, each weighted by
+// the share of its part's requests it stands for
+func weightedSamples(parts []api.TestResult) (samples, weights []float64) {
+	type weighted struct{ v, w float64 }
+	var all []weighted
+	for _, p := range parts {
+		if len(p.Samples) == 0 {
+			continue
+		}
+		w := float64(p.Requests) / float64(len(p.Samples))
+		for _, v := range p.Samples {
+			all = append(all, weighted{v, w})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+	samples = make([]float64, len(all))
+	weights = make([]float64, len(all))
+	for i, s := range all {
+		samples[i], weights[i] = s.v, s.w
+	}
+	return samples, weights
+}
+
+// weightedPercentile returns the nearest-rank percentile p (0-100) of
+// sorted samples with weights
+func weightedPercentile(samples, weights []float64, p float64) float64 {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	rank := p / 100 * total
+	seen := 0.0
+	for i, w := range weights {
+		seen += w
+		if seen >= rank {
+			return samples[i]
+		}
+	}
+	if len(samples) == 0 {
+		return 0
+	}
+	return samples[len(samples)-1]
+}
+
+// resample draws the merged result's samples: as many as the largest part
+// kept, taken from each part in proportion to its requests, so they stay a
+// fair sample of all requests
+func resample(parts []api.TestResult) []float64 {
+	size, total := 0, 0
+	for _, p := range parts {
+		size = max(size, len(p.Samples))
+		total += p.Requests
+	}
+	if total == 0 {
+		return nil
+	}
+	var out []float64
+	for _, p := range parts {
+		k := min(int(math.Round(float64(size)*float64(p.Requests)/float64(total))), len(p.Samples))
+		// Evenly spaced picks from a random sample are a random sample
+		for i := 0; i < k; i++ {
+			out = append(out, p.Samples[i*len(p.Samples)/k])
+		}
+	}
+	return out
+}
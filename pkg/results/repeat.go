@@ -0,0 +1,120 @@
+package results
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// MetricSummary describes one metric across repeated runs of the same test
+type MetricSummary struct {
+	Name   string  `json:"name"`
+	Unit   string  `json:"unit"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	CILow  float64 `json:"ci95_low"`
+	CIHigh float64 `json:"ci95_high"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+}
+
+// repeatMetrics lists the metrics summarised across runs
+var repeatMetrics = []struct {
+	name, unit string
+	value      func(api.TestResult) float64
+}{
+	{"Requests/sec", "", func(r api.TestResult) float64 { return r.RequestsPerSecond }},
+	{"Avg response", "ms", func(r api.TestResult) float64 { return r.AvgResponseTime }},
+	{"p50 response", "ms", func(r api.TestResult) float64 { return r.P50ResponseTime }},
+	{"p95 response", "ms", func(r api.TestResult) float64 { return r.P95ResponseTime }},
+	{"p99 response", "ms", func(r api.TestResult) float64 { return r.P99ResponseTime }},
+	{"Success rate", "%", func(r api.TestResult) float64 { return r.SuccessRate }},
+}
+
+// SummarizeRuns computes the mean, sample standard deviation and 95% confidence
+// interval (Student's t) of the key metrics across repeated runs.
+func SummarizeRuns(runs []api.TestResult) []MetricSummary {
+	if len(runs) == 0 {
+		return nil
+	}
+
+	summaries := make([]MetricSummary, 0, len(repeatMetrics))
+	for _, m := range repeatMetrics {
+		values := make([]float64, len(runs))
+		for i, r := range runs {
+			values[i] = m.value(r)
+		}
+
+		mean, stddev := meanStdDev(values)
+		margin := 0.0
+		if len(values) > 1 {
+			margin = tCritical95(len(values)-1) * stddev / math.Sqrt(float64(len(values)))
+		}
+
+		s := MetricSummary{
+			Name:   m.name,
+			Unit:   m.unit,
+			Mean:   mean,
+			StdDev: stddev,
+			CILow:  mean - margin,
+			CIHigh: mean + margin,
+			Min:    values[0],
+			Max:    values[0],
+		}
+		for _, v := range values {
+			s.Min = math.Min(s.Min, v)
+			s.Max = math.Max(s.Max, v)
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries
+}
+
+// PrintRepeatSummary prints the across-run statistics for a repeated test
+func PrintRepeatSummary(name string, runs []api.TestResult) {
+	fmt.Printf("\n=== REPEATED RUNS: %s (n=%d) ===\n", name, len(runs))
+	fmt.Printf("  %-14s %12s %10s %25s %12s %12s\n", "Metric", "Mean", "StdDev", "95% CI", "Min", "Max")
+	for _, s := range SummarizeRuns(runs) {
+		ci := fmt.Sprintf("[%.2f, %.2f]", s.CILow, s.CIHigh)
+		fmt.Printf("  %-14s %12.2f %10.2f %25s %12.2f %12.2f %s\n", s.Name, s.Mean, s.StdDev, ci, s.Min, s.Max, s.Unit)
+	}
+}
+
+// meanStdDev returns the mean and sample standard deviation of values
+func meanStdDev(values []float64) (mean, stddev float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)-1))
+}
+
+// tTable holds two-sided 95% critical values of Student's t for 1-30 degrees of freedom
+var tTable = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// tCritical95 returns the two-sided 95% t critical value for df degrees of freedom
+func tCritical95(df int) float64 {
+	if df < 1 {
+		return 0
+	}
+	if df <= len(tTable) {
+		return tTable[df-1]
+	}
+	return 1.96
+}
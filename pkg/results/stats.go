@@ -0,0 +1,81 @@
+package results
+
+import (
+	"math"
+	"sort"
+)
+
+// MannWhitneyResult is the outcome of a two-sided Mann-Whitney U test
+type MannWhitneyResult struct {
+	U      float64 `json:"u"`
+	Z      float64 `json:"z"`
+	PValue float64 `json:"p_value"`
+}
+
+// MannWhitneyU tests whether two latency samples come from the same
+// distribution, using the normal approximation with tie correction. It makes
+// no assumption about the shape of the distributions, which suits long-tailed
+// latency data better than a t-test. Both samples must be non-empty.
+func MannWhitneyU(a, b []float64) MannWhitneyResult {
+	n1, n2 := float64(len(a)), float64(len(b))
+	if n1 == 0 || n2 == 0 {
+		return MannWhitneyResult{PValue: 1}
+	}
+
+	type obs struct {
+		v     float64
+		first bool
+	}
+	all := make([]obs, 0, len(a)+len(b))
+	for _, v := range a {
+		all = append(all, obs{v, true})
+	}
+	for _, v := range b {
+		all = append(all, obs{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// Assign average ranks to ties and accumulate the tie correction term
+	var rankSumA, tieTerm float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2 // ranks are 1-based
+		for k := i; k < j; k++ {
+			if all[k].first {
+				rankSumA += rank
+			}
+		}
+		t := float64(j - i)
+		tieTerm += t*t*t - t
+		i = j
+	}
+
+	u1 := rankSumA - n1*(n1+1)/2
+	u := math.Min(u1, n1*n2-u1)
+
+	n := n1 + n2
+	mean := n1 * n2 / 2
+	variance := n1 * n2 / 12 * ((n + 1) - tieTerm/(n*(n-1)))
+	if variance <= 0 {
+		return MannWhitneyResult{U: u, PValue: 1}
+	}
+
+	// Continuity-corrected z score on U1 so the sign shows direction (negative: a < b)
+	diff := u1 - mean
+	switch {
+	case diff > 0:
+		diff -= 0.5
+	case diff < 0:
+		diff += 0.5
+	}
+	z := diff / math.Sqrt(variance)
+
+	return MannWhitneyResult{
+		U:      u,
+		Z:      z,
+		PValue: math.Erfc(math.Abs(z) / math.Sqrt2),
+	}
+}
@@ -0,0 +1,86 @@
+package results
+
+import (
+	"fmt"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// ErrorRate returns the percentage of requests that did not succeed
+func ErrorRate(r api.TestResult) float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return 100 - r.SuccessRate
+}
+
+// PrintCapacityTable prints every probe of a capacity search followed by the
+// discovered capacity (zero when no probe passed).
+func PrintCapacityTable(name string, probes []api.TestResult, capacity float64) {
+	fmt.Printf("\n=== CAPACITY SEARCH: %s ===\n", name)
+	fmt.Printf("  %10s  %10s  %10s  %8s\n", "Target RPS", "RPS", "p95 (ms)", "Errors")
+	for _, r := range probes {
+		fmt.Printf("  %10.1f  %10.2f  %10.2f  %7.2f%%\n", r.TargetRPS, r.RequestsPerSecond, r.P95ResponseTime, ErrorRate(r))
+	}
+	if capacity > 0 {
+		fmt.Printf("  Max sustainable rate: %.1f RPS\n", capacity)
+	} else {
+		fmt.Println("  No probed rate stayed within the thresholds")
+	}
+}
+
+// PrintTuneTable prints every step of a concurrency tuning run followed by
+// the throughput reached at the target p95 (best is the zero result when no
+// step held it).
+func PrintTuneTable(name string, steps []api.TestResult, best api.TestResult) {
+	fmt.Printf("\n=== CONCURRENCY TUNING: %s ===\n", name)
+	fmt.Printf("  %11s  %10s  %10s  %8s\n", "Concurrency", "RPS", "p95 (ms)", "Errors")
+	for _, r := range steps {
+		fmt.Printf("  %11d  %10.2f  %10.2f  %7.2f%%\n", r.Concurrency, r.RequestsPerSecond, r.P95ResponseTime, ErrorRate(r))
+	}
+	if best.TunedConcurrency > 0 {
+		fmt.Printf("  Throughput at p95 <= %.0f ms: %.1f RPS at concurrency %d (p95 %.2f ms)\n",
+			best.TargetP95, best.RequestsPerSecond, best.TunedConcurrency, best.P95ResponseTime)
+	} else {
+		fmt.Println("  No step held the target p95")
+	}
+}
+
+// PrintBudgetTable prints every window of an error budget run followed by
+// the equilibrium throughput (best is the zero result when no window kept
+// within budget).
+func PrintBudgetTable(name string, windows []api.TestResult, best api.TestResult) {
+	fmt.Printf("\n=== ERROR BUDGET: %s ===\n", name)
+	fmt.Printf("  %10s  %10s  %10s  %8s\n", "Target RPS", "RPS", "p95 (ms)", "Errors")
+	for _, r := range windows {
+		fmt.Printf("  %10.1f  %10.2f  %10.2f  %7.2f%%\n", r.TargetRPS, r.RequestsPerSecond, r.P95ResponseTime, ErrorRate(r))
+	}
+	if best.EquilibriumRPS > 0 {
+		fmt.Printf("  Equilibrium throughput within a %.2f%% error budget: %.1f RPS\n", best.ErrorBudget, best.EquilibriumRPS)
+	} else {
+		fmt.Println("  No window kept within the error budget")
+	}
+}
+
+// PrintSweepTable prints one row per concurrency level of a sweep so the
+// levels can be compared side by side.
+func PrintSweepTable(name string, levels []api.TestResult) {
+	fmt.Printf("\n=== CONCURRENCY SWEEP: %s ===\n", name)
+	fmt.Printf("  %11s  %10s  %10s  %10s  %10s  %8s\n", "Concurrency", "RPS", "Avg (ms)", "p95 (ms)", "p99 (ms)", "Errors")
+
+	bestRPS := -1
+	for i, r := range levels {
+		if bestRPS < 0 || r.RequestsPerSecond > levels[bestRPS].RequestsPerSecond {
+			bestRPS = i
+		}
+	}
+
+	for i, r := range levels {
+		marker := ""
+		if i == bestRPS {
+			marker = "  <- peak RPS"
+		}
+		fmt.Printf("  %11d  %10.2f  %10.2f  %10.2f  %10.2f  %7.2f%%%s\n",
+			r.Concurrency, r.RequestsPerSecond, r.AvgResponseTime, r.P95ResponseTime, r.P99ResponseTime, ErrorRate(r), marker)
+	}
+}
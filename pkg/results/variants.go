@@ -0,0 +1,19 @@
+package results
+
+import (
+	"fmt"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// PrintVariantTable prints one row per content-negotiation variant of a
+// test, with the representations the server actually returned for it
+func PrintVariantTable(name string, runs []api.TestResult) {
+	fmt.Printf("\n=== NEGOTIATION MATRIX: %s ===\n", name)
+	for _, r := range runs {
+		fmt.Printf("  %s\n", r.Variant)
+		fmt.Printf("    Served: %s\n", shares(r.Representations))
+		fmt.Printf("    Avg %.2f ms  p95 %.2f ms  %.2f RPS  %.2f%% errors\n",
+			r.AvgResponseTime, r.P95ResponseTime, r.RequestsPerSecond, ErrorRate(r))
+	}
+}
@@ -0,0 +1,465 @@
+package runner
+
+import (
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/internal/redact"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+const (
+	// maxErrorSamples bounds how many individual error records a result keeps;
+	// every error is still counted in ErrorCounts.
+	maxErrorSamples = 1000
+
+	// maxErrorKinds bounds the distinct keys in ErrorCounts; further kinds
+	// are counted under otherErrorsKey.
+	maxErrorKinds  = 100
+	otherErrorsKey = "other errors"
+)
+
+// statusKeys holds the map key for every valid HTTP status so the hot
+// path does not format a new string per response
+var statusKeys = func() (keys [600]string) {
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	return keys
+}()
+
+// statusKey returns the StatusCodes key for an HTTP status
+func statusKey(status int) string {
+	if status >= 0 && status < len(statusKeys) {
+		return statusKeys[status]
+	}
+	return strconv.Itoa(status)
+}
+
+// timelineBucket accumulates the requests that started within one second
+type timelineBucket struct {
+	sumMs float64
+	count int
+}
+
+// aggregator folds request results into a TestResult as they arrive, so
+// memory use does not grow with the number of requests.
+type aggregator struct {
+	runner *Runner
+	mode   string
+	redact *redact.Redactor
+	result *api.TestResult
+
+	latency, connect, tls *histogram
+
+	total, success int
+	totalDuration  time.Duration
+	errorsSeen     int
+	timeline       map[int64]*timelineBucket
+
+	endpoints []*endpointTally // per endpoint of a multi-endpoint test, or per step
+	scenario  bool             // the tallies are steps
+
+	iterations, failedIterations int
+	iteration                    *histogram
+
+	// Cache mode
+	conditional, notModified int
+	validation, full         *histogram
+
+	encoding *api.EncodingStats // tests with an Accept-Encoding
+	sized    int                // responses counted in encoding.TotalBytes
+
+	representations map[string]int // tests sending Accept or Accept-Language
+
+	backoff *api.BackoffStats // tests with Backoff
+	retries *api.RetryStats   // tests with RetryAfter
+	chaos   *api.ChaosStats   // tests with Chaos
+
+	agents map[string]*endpointTally // by User-Agent label, tests with UserAgents
+
+	corrected *histogram // latency from the intended start, paced tests
+
+	timeouts api.TimeoutStats
+}
+
+// endpointTally accumulates the requests sent to one endpoint
+type endpointTally struct {
+	name           string
+	method, url    string
+	total, success int
+	latency        *histogram
+}
+
+func newAggregator(r *Runner, config api.TestConfiguration, red *redact.Redactor, result *api.TestResult) *aggregator {
+	a := &aggregator{
+		runner:   r,
+		mode:     config.Mode,
+		redact:   red,
+		result:   result,
+		latency:  newHistogram(),
+		connect:  newHistogram(),
+		tls:      newHistogram(),
+		timeline: make(map[int64]*timelineBucket),
+	}
+	if config.Mode == api.ModeCache {
+		a.validation, a.full = newHistogram(), newHistogram()
+	}
+	if config.AcceptEncoding != "" && isHTTPMode(config.Mode) {
+		a.encoding = &api.EncodingStats{Accepted: config.AcceptEncoding, Served: make(map[string]int)}
+	}
+	if isHTTPMode(config.Mode) && negotiates(config) {
+		a.representations = make(map[string]int)
+	}
+	if isHTTPMode(config.Mode) && config.Backoff {
+		a.backoff = &api.BackoffStats{}
+	}
+	if isHTTPMode(config.Mode) && config.RetryAfter > 0 {
+		a.retries = &api.RetryStats{}
+	}
+	if isHTTPMode(config.Mode) && config.Chaos != nil {
+		a.chaos = &api.ChaosStats{}
+	}
+	if isHTTPMode(config.Mode) && len(config.UserAgents) > 0 {
+		a.agents = make(map[string]*endpointTally)
+	}
+	if config.RateRPS > 0 || (config.ReplaySpeed > 0 && len(config.Replay) > 0) {
+		a.corrected = newHistogram()
+	}
+	if isHTTPMode(config.Mode) {
+		for _, e := range config.Endpoints {
+			method := e.Method
+			if method == "" {
+				method = config.Method
+			}
+			a.endpoints = append(a.endpoints, &endpointTally{method: method, url: red.String(e.URL), latency: newHistogram()})
+		}
+		for _, s := range config.Steps {
+			method := s.Method
+			if method == "" {
+				method = config.Method
+			}
+			a.endpoints = append(a.endpoints, &endpointTally{name: s.Name, method: method, url: red.String(s.URL), latency: newHistogram()})
+			a.scenario = true
+		}
+		if a.scenario {
+			a.iteration = newHistogram()
+		}
+	}
+	return a
+}
+
+// add records one request result
+func (a *aggregator) add(res api.RequestResult) {
+	a.total++
+	var ep *endpointTally
+	if res.Endpoint < len(a.endpoints) {
+		ep = a.endpoints[res.Endpoint]
+		ep.total++
+	}
+	var agent *endpointTally
+	if a.agents != nil && res.UserAgent != "" {
+		if agent = a.agents[res.UserAgent]; agent == nil {
+			agent = &endpointTally{name: res.UserAgent, latency: newHistogram()}
+			a.agents[res.UserAgent] = agent
+		}
+		agent.total++
+	}
+
+	if res.ConnectDuration > 0 {
+		a.connect.Record(res.ConnectDuration)
+	}
+	if res.TLSDuration > 0 {
+		a.tls.Record(res.TLSDuration)
+	}
+	if a.backoff != nil {
+		if res.RateLimited {
+			a.backoff.RateLimited++
+		}
+		if res.Throttled > 0 {
+			a.backoff.Throttled++
+			a.backoff.ThrottledSecs += res.Throttled.Seconds()
+		}
+	}
+	if a.retries != nil && res.Retries > 0 {
+		a.retries.Retried++
+		a.retries.Retries += res.Retries
+		a.retries.WaitSecs += res.RetryWait.Seconds()
+		if res.Error == nil && res.Status >= 200 && res.Status < 400 {
+			a.retries.Recovered++
+		}
+	}
+	if a.chaos != nil {
+		switch res.Fault {
+		case api.FaultDrop:
+			a.chaos.Dropped++
+		case api.FaultDelay:
+			a.chaos.Delayed++
+		case api.FaultTruncate:
+			a.chaos.Truncated++
+		}
+	}
+
+	if res.Error != nil {
+		e := api.ErrorData{
+			Message:   res.Error.Error(),
+			RequestID: res.RequestID,
+		}
+		if class, message, ok := resourceError(res.Error); ok {
+			e.Class, e.Message = class, message
+		} else if chaosError(res.Fault) != nil {
+			e.Class = ErrorClassChaos
+		} else if class, message, ok := timeoutError(res.Error); ok {
+			e.Class, e.Message = class, message
+			countTimeout(&a.timeouts, class)
+		}
+		a.addError(e, res.Item)
+		return
+	}
+
+	if !isHTTPMode(a.mode) {
+		// Other protocols succeed whenever they return no error; a status,
+		// if they report one, only feeds the distribution
+		if res.Status != 0 {
+			a.result.StatusCodes[statusKey(res.Status)]++
+		}
+		a.success++
+	} else {
+		statusKey := statusKey(res.Status)
+		a.result.StatusCodes[statusKey]++
+
+		// Consider 2xx and 3xx as success
+		if res.Status >= 200 && res.Status < 400 {
+			a.success++
+			if ep != nil {
+				ep.success++
+			}
+			if agent != nil {
+				agent.success++
+			}
+			if a.encoding != nil {
+				served := res.ContentEncoding
+				if served == "" {
+					served = "identity"
+				}
+				a.encoding.Served[served]++
+				a.encoding.TotalBytes += res.BodyBytes
+				a.sized++
+			}
+			if a.representations != nil {
+				a.representations[res.Representation]++
+			}
+		} else {
+			a.addError(api.ErrorData{
+				Status:          statusKey,
+				Message:         http.StatusText(res.Status),
+				RequestID:       res.RequestID,
+				ServerRequestID: res.ServerRequestID,
+			}, res.Item)
+		}
+	}
+
+	a.totalDuration += res.Duration
+	a.latency.Record(res.Duration)
+	if a.corrected != nil && !res.Intended.IsZero() {
+		// From the intended start to the response: queueing plus service
+		a.corrected.Record(max(res.Timestamp.Add(res.Duration).Sub(res.Intended), res.Duration))
+	}
+	if ep != nil {
+		ep.latency.Record(res.Duration)
+	}
+	if agent != nil {
+		agent.latency.Record(res.Duration)
+	}
+	if a.mode == api.ModeCache {
+		if res.Conditional {
+			a.conditional++
+			if res.Status == http.StatusNotModified {
+				a.notModified++
+			}
+			a.validation.Record(res.Duration)
+		} else {
+			a.full.Record(res.Duration)
+		}
+	}
+	a.keepSample(durationMs(res.Duration))
+
+	if res.IterationDuration > 0 {
+		a.iterations++
+		if res.IterationFailed {
+			a.failedIterations++
+		}
+		a.iteration.Record(res.IterationDuration)
+	}
+
+	second := res.Timestamp.Unix()
+	bucket := a.timeline[second]
+	if bucket == nil {
+		bucket = &timelineBucket{}
+		a.timeline[second] = bucket
+	}
+	bucket.sumMs += float64(res.Duration.Milliseconds())
+	bucket.count++
+}
+
+// addError counts an error and keeps it in the bounded error reservoir.
+// item is the failed request's each value, if any.
+func (a *aggregator) addError(e api.ErrorData, item string) {
+	e.Message = a.redact.String(e.Message)
+	key := e.Key()
+	if item != "" {
+		a.result.FailedItems = append(a.result.FailedItems, api.ItemFailure{Value: item, Error: key})
+	}
+	counts := a.result.ErrorCounts
+	if _, known := counts[key]; !known && len(counts) >= maxErrorKinds {
+		key = otherErrorsKey
+	}
+	counts[key]++
+
+	a.errorsSeen++
+	if len(a.result.Errors) < maxErrorSamples {
+		a.result.Errors = append(a.result.Errors, e)
+	} else if j := rand.Intn(a.errorsSeen); j < maxErrorSamples {
+		a.result.Errors[j] = e
+	}
+}
+
+// keepSample adds a latency sample to the result's reservoir
+func (a *aggregator) keepSample(ms float64) {
+	limit := a.runner.SampleLimit
+	if limit <= 0 {
+		return
+	}
+	n := a.latency.Count()
+	if len(a.result.Samples) < limit {
+		a.result.Samples = append(a.result.Samples, ms)
+		return
+	}
+	if j := rand.Intn(n); j < limit {
+		a.result.Samples[j] = ms
+	}
+}
+
+// finish computes the summary statistics once all results are in
+func (a *aggregator) finish(elapsed time.Duration) {
+	result := a.result
+
+	result.ConnectTime = a.connect.Stats()
+	result.TLSHandshakeTime = a.tls.Stats()
+	if a.corrected != nil {
+		result.CorrectedLatency = a.corrected.Stats()
+	}
+
+	if a.total > 0 {
+		result.SuccessRate = float64(a.success) / float64(a.total) * 100
+		result.AvgResponseTime = float64(a.totalDuration.Milliseconds()) / float64(a.total)
+		result.RequestsPerSecond = float64(a.total) / elapsed.Seconds()
+	}
+
+	if stats := a.latency.Stats(); stats != nil {
+		if a.success > 0 {
+			result.MinResponseTime = float64(int64(stats.Min))
+			result.MaxResponseTime = float64(int64(stats.Max))
+		}
+		result.P50ResponseTime = stats.P50
+		result.P90ResponseTime = stats.P90
+		result.P95ResponseTime = stats.P95
+		result.P99ResponseTime = stats.P99
+
+		result.TrimmedMeanResponseTime, result.WinsorizedMeanResponseTime,
+			result.OutlierCutoff, result.OutlierCount = a.latency.OutlierStats(a.runner.OutlierPercentile)
+	}
+
+	if a.mode == api.ModeCache {
+		result.Cache = &api.CacheStats{
+			ConditionalRequests: a.conditional,
+			NotModified:         a.notModified,
+			Validation:          a.validation.Stats(),
+			Full:                a.full.Stats(),
+		}
+		if a.conditional > 0 {
+			result.Cache.HitRatio = float64(a.notModified) / float64(a.conditional) * 100
+		}
+	}
+
+	if len(a.representations) > 0 {
+		result.Representations = a.representations
+	}
+
+	if a.encoding != nil {
+		if a.sized > 0 {
+			a.encoding.AvgBytes = float64(a.encoding.TotalBytes) / float64(a.sized)
+		}
+		result.Encoding = a.encoding
+	}
+
+	if a.backoff != nil {
+		result.Backoff = a.backoff
+	}
+	if a.retries != nil {
+		result.Retries = a.retries
+	}
+	if a.chaos != nil {
+		result.Chaos = a.chaos
+	}
+	if a.timeouts != (api.TimeoutStats{}) {
+		timeouts := a.timeouts
+		result.Timeouts = &timeouts
+	}
+
+	if a.scenario {
+		result.Iterations = &api.IterationStats{
+			Completed: a.iterations,
+			Failed:    a.failedIterations,
+			Duration:  a.iteration.Stats(),
+		}
+		if elapsed > 0 {
+			result.Iterations.PerSecond = float64(a.iterations) / elapsed.Seconds()
+		}
+	}
+
+	for _, ep := range a.endpoints {
+		e := api.EndpointResult{Name: ep.name, Method: ep.method, URL: ep.url, Requests: ep.total, Latency: ep.latency.Stats()}
+		if ep.total > 0 {
+			e.SuccessRate = float64(ep.success) / float64(ep.total) * 100
+		}
+		if a.scenario {
+			result.Steps = append(result.Steps, e)
+		} else {
+			result.Endpoints = append(result.Endpoints, e)
+		}
+	}
+
+	for _, agent := range a.agents {
+		u := api.UserAgentResult{Agent: agent.name, Requests: agent.total, Latency: agent.latency.Stats()}
+		if agent.total > 0 {
+			u.SuccessRate = float64(agent.success) / float64(agent.total) * 100
+		}
+		result.UserAgents = append(result.UserAgents, u)
+	}
+	sort.Slice(result.UserAgents, func(i, j int) bool {
+		ui, uj := result.UserAgents[i], result.UserAgents[j]
+		if ui.Requests != uj.Requests {
+			return ui.Requests > uj.Requests
+		}
+		return ui.Agent < uj.Agent
+	})
+
+	seconds := make([]int64, 0, len(a.timeline))
+	for second := range a.timeline {
+		seconds = append(seconds, second)
+	}
+	sort.Slice(seconds, func(i, j int) bool { return seconds[i] < seconds[j] })
+
+	for _, second := range seconds {
+		bucket := a.timeline[second]
+		result.Timeline = append(result.Timeline, api.TimelinePoint{
+			Timestamp:    float64(second),
+			ResponseTime: bucket.sumMs / float64(bucket.count),
+			ActiveUsers:  float64(bucket.count),
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package runner
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// backoffRecovery is the factor a throttled host's rate grows by for every
+// second without rate limiting; backoffMinRate is the lowest rate, in
+// requests per second, halving goes down to
+const (
+	backoffRecovery = 1.1
+	backoffMinRate  = 1
+)
+
+// hostBackoff throttles requests per host after rate limiting. A host
+// starts unlimited. A 429, or any response with Retry-After, turns on a
+// token bucket at half the rate the host was being sent, halving it again
+// at most once a second while limiting goes on, and pauses the host for
+// the Retry-After period. Once the host is no longer limiting, the rate
+// grows by backoffRecovery every second and the bucket is dropped when it
+// reaches twice the rate that was first limited.
+type hostBackoff struct {
+	clock Clock
+	mu    sync.Mutex
+	hosts map[string]*hostBucket
+}
+
+type hostBucket struct {
+	rate    float64 // requests per second; 0 while unlimited
+	ceiling float64 // the rate the host was sent when limiting began
+	tokens  float64
+	refill  time.Time // last token refill
+	until   time.Time // paused until, per Retry-After
+	limited time.Time // last rate limited response
+	raised  time.Time // last recovery step
+
+	// Requests sent in the current and previous second, to estimate the
+	// rate when limiting begins
+	window     time.Time
+	sent, prev int
+}
+
+func newHostBackoff(clock Clock) *hostBackoff {
+	return &hostBackoff{clock: clock, hosts: make(map[string]*hostBucket)}
+}
+
+// wait blocks until a request to host may be sent and returns how long it
+// waited. It returns false if ctx ended first.
+func (b *hostBackoff) wait(ctx context.Context, host string) (time.Duration, bool) {
+	start := b.clock.Now()
+	var waited time.Duration
+	for {
+		d := b.reserve(host, b.clock.Now())
+		if d <= 0 {
+			return waited, true
+		}
+		if !sleepContext(ctx, b.clock, d) {
+			return waited, false
+		}
+		waited = b.clock.Now().Sub(start)
+	}
+}
+
+// reserve takes a token for a request to host, or returns how long to wait
+// before trying again
+func (b *hostBackoff) reserve(host string, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h := b.hosts[host]
+	if h == nil {
+		h = &hostBucket{}
+		b.hosts[host] = h
+	}
+	if now.Before(h.until) {
+		return h.until.Sub(now)
+	}
+
+	if h.rate > 0 {
+		if now.Sub(h.limited) >= time.Second {
+			if steps := int(now.Sub(h.raised) / time.Second); steps > 0 {
+				h.rate *= math.Pow(backoffRecovery, float64(steps))
+				h.raised = h.raised.Add(time.Duration(steps) * time.Second)
+			}
+			if h.rate >= 2*h.ceiling {
+				h.rate = 0
+			}
+		}
+	}
+	if h.rate > 0 {
+		// A burst of one spaces requests evenly
+		h.tokens = math.Min(h.tokens+now.Sub(h.refill).Seconds()*h.rate, 1)
+		h.refill = now
+		if h.tokens < 1 {
+			return time.Duration((1 - h.tokens) / h.rate * float64(time.Second))
+		}
+		h.tokens--
+	}
+
+	if now.Sub(h.window) >= time.Second {
+		h.prev = 0
+		if now.Sub(h.window) < 2*time.Second {
+			h.prev = h.sent
+		}
+		h.window, h.sent = now, 0
+	}
+	h.sent++
+	return 0
+}
+
+// observe slows a host down if resp shows it is rate limiting, and reports
+// whether it did
+func (b *hostBackoff) observe(host string, resp *http.Response, now time.Time) bool {
+	wait, hasRetryAfter := retryAfter(resp.Header.Get("Retry-After"), now)
+	if resp.StatusCode != http.StatusTooManyRequests && !hasRetryAfter {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h := b.hosts[host]
+	if h == nil {
+		return true // never sent through wait
+	}
+	switch {
+	case h.rate == 0:
+		h.ceiling = math.Max(float64(max(h.sent, h.prev)), backoffMinRate)
+		h.rate = math.Max(h.ceiling/2, backoffMinRate)
+		h.tokens, h.refill = 0, now
+	case now.Sub(h.limited) >= time.Second:
+		h.rate = math.Max(h.rate/2, backoffMinRate)
+	}
+	h.limited, h.raised = now, now
+	if until := now.Add(wait); until.After(h.until) {
+		h.until = until
+	}
+	return true
+}
+
+// retryAfter parses a Retry-After value, either delay seconds or an HTTP
+// date, into the time to wait from now
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,155 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// BudgetOptions controls the controller that drives the arrival rate as high
+// as an error budget allows
+type BudgetOptions struct {
+	ErrorBudget    float64 // highest acceptable rolling error rate, in percent
+	StartRPS       float64 // rate of the first window
+	MaxRPS         float64 // never drive the rate above this
+	WindowSeconds  int     // duration of each window
+	Windows        int     // number of windows to run
+	RollingWindows int     // windows the rolling error rate spans
+}
+
+// DefaultBudgetOptions returns sensible defaults for the error budget controller
+func DefaultBudgetOptions() BudgetOptions {
+	return BudgetOptions{
+		ErrorBudget:    1,
+		StartRPS:       10,
+		MaxRPS:         10000,
+		WindowSeconds:  5,
+		Windows:        12,
+		RollingWindows: 3,
+	}
+}
+
+// How the controller moves the rate: doubling until the budget is first
+// exceeded, then additive steps of a tenth of the rate at that point, and
+// backing off multiplicatively whenever the budget is exceeded again
+const (
+	budgetSlowStart = 2.0
+	budgetIncrease  = 0.1
+	budgetDecrease  = 0.7
+)
+
+// HoldErrorBudget drives the test's arrival rate to the highest the target
+// sustains with its rolling error rate under opts.ErrorBudget. It runs
+// opts.Windows windows at a fixed rate each; after every window the rate
+// goes up while the error rate over the last RollingWindows windows is within
+// budget and down when it isn't, as TCP congestion control does. The rate
+// settles into a sawtooth around the target's limit, whose mean achieved
+// throughput is the equilibrium. It returns the last window that kept within
+// budget, with EquilibriumRPS and ErrorBudget set, together with every
+// window run in order.
+func (r *Runner) HoldErrorBudget(ctx context.Context, config api.TestConfiguration, opts BudgetOptions) (api.TestResult, []api.TestResult, error) {
+	defaults := DefaultBudgetOptions()
+	if opts.ErrorBudget <= 0 || opts.ErrorBudget >= 100 {
+		return api.TestResult{}, nil, fmt.Errorf("error budget must be between 0 and 100%%, got %g", opts.ErrorBudget)
+	}
+	if opts.StartRPS <= 0 || opts.MaxRPS < opts.StartRPS {
+		return api.TestResult{}, nil, fmt.Errorf("invalid rate range %.0f-%.0f RPS", opts.StartRPS, opts.MaxRPS)
+	}
+	if opts.WindowSeconds <= 0 {
+		opts.WindowSeconds = defaults.WindowSeconds
+	}
+	if opts.Windows <= 0 {
+		opts.Windows = defaults.Windows
+	}
+	if opts.RollingWindows <= 0 {
+		opts.RollingWindows = defaults.RollingWindows
+	}
+
+	var (
+		windows  []api.TestResult
+		last     api.TestResult
+		found    bool
+		rolling  []api.TestResult // last windows, since the last back-off
+		settled  = -1             // index of the first back-off
+		increase float64
+	)
+
+	rate := opts.StartRPS
+	for len(windows) < opts.Windows {
+		window := config
+		window.RateRPS = rate
+		window.Requests = int(math.Ceil(rate * float64(opts.WindowSeconds)))
+
+		r.logInfo("Error budget window at %.1f RPS (%d requests)", rate, window.Requests)
+		result, err := r.RunTest(ctx, window)
+		if err != nil {
+			return api.TestResult{}, windows, err
+		}
+		windows = append(windows, result)
+		rolling = append(rolling, result)
+		if len(rolling) > opts.RollingWindows {
+			rolling = rolling[1:]
+		}
+
+		errorRate := rollingErrorRate(rolling)
+		ok := errorRate <= opts.ErrorBudget
+		r.logInfo("  achieved %.1f RPS, errors %.2f%% (rolling %.2f%%) -> %s",
+			result.RequestsPerSecond, 100-result.SuccessRate, errorRate, passFail(ok))
+		if ok {
+			last, found = result, true
+		}
+
+		switch {
+		case !ok:
+			if settled < 0 {
+				settled = len(windows) - 1
+				increase = rate * budgetIncrease
+			}
+			rate *= budgetDecrease
+			// Judge the new rate on its own windows only
+			rolling = nil
+		case result.RequestsPerSecond < result.TargetRPS*0.9:
+			// The generator can't keep up; more concurrency is needed to
+			// push further, so hold the rate
+		case settled < 0:
+			rate *= budgetSlowStart
+		default:
+			rate += increase
+		}
+		rate = min(max(rate, opts.StartRPS), opts.MaxRPS)
+	}
+
+	if !found {
+		return api.TestResult{}, windows, fmt.Errorf("the error rate exceeded %.2f%% even at %.1f RPS", opts.ErrorBudget, opts.StartRPS)
+	}
+
+	// Without a back-off the budget was never reached; the best there is
+	// is the last rate
+	equilibrium := windows[len(windows)-1:]
+	if settled >= 0 {
+		equilibrium = windows[settled:]
+	}
+	sum := 0.0
+	for _, w := range equilibrium {
+		sum += w.RequestsPerSecond
+	}
+	last.EquilibriumRPS = sum / float64(len(equilibrium))
+	last.ErrorBudget = opts.ErrorBudget
+	return last, windows, nil
+}
+
+// rollingErrorRate returns the error rate over all requests of windows, in
+// percent
+func rollingErrorRate(windows []api.TestResult) float64 {
+	var total, failed float64
+	for _, w := range windows {
+		total += float64(w.Requests)
+		failed += float64(w.Requests) * (100 - w.SuccessRate) / 100
+	}
+	if total == 0 {
+		return 0
+	}
+	return failed / total * 100
+}
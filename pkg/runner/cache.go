@@ -0,0 +1,51 @@
+package runner
+
+import (
+	"net/http"
+	"sync"
+)
+
+// validators are the cache validators of a response
+type validators struct {
+	etag, lastModified string
+}
+
+// validatorCache remembers the validators of each URL's last full response,
+// so cache mode can revalidate it with a conditional request
+type validatorCache struct {
+	mu    sync.RWMutex
+	byURL map[string]validators
+}
+
+func newValidatorCache() *validatorCache {
+	return &validatorCache{byURL: make(map[string]validators)}
+}
+
+// apply adds If-None-Match / If-Modified-Since to req when its URL has been
+// fetched before, and reports whether it did
+func (c *validatorCache) apply(req *http.Request) bool {
+	c.mu.RLock()
+	v, ok := c.byURL[req.URL.String()]
+	c.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	if v.etag != "" {
+		req.Header.Set("If-None-Match", v.etag)
+	}
+	if v.lastModified != "" {
+		req.Header.Set("If-Modified-Since", v.lastModified)
+	}
+	return true
+}
+
+// store records the validators of a full response, if it has any
+func (c *validatorCache) store(req *http.Request, resp *http.Response) {
+	v := validators{etag: resp.Header.Get("ETag"), lastModified: resp.Header.Get("Last-Modified")}
+	if v == (validators{}) {
+		return
+	}
+	c.mu.Lock()
+	c.byURL[req.URL.String()] = v
+	c.mu.Unlock()
+}
@@ -0,0 +1,136 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// CapacityOptions controls the search for the maximum sustainable arrival rate
+type CapacityOptions struct {
+	MinRPS       float64 // lowest rate to try
+	MaxRPS       float64 // highest rate to try
+	StepSeconds  int     // duration of each probe run
+	MaxErrorRate float64 // highest acceptable error rate, in percent
+	MaxP95       float64 // highest acceptable p95 latency, in ms
+	Precision    float64 // stop when the search window is narrower than this fraction
+}
+
+// DefaultCapacityOptions returns sensible defaults for capacity discovery
+func DefaultCapacityOptions() CapacityOptions {
+	return CapacityOptions{
+		MinRPS:       10,
+		MaxRPS:       10000,
+		StepSeconds:  10,
+		MaxErrorRate: 1,
+		MaxP95:       500,
+		Precision:    0.05,
+	}
+}
+
+// DiscoverCapacity finds the highest fixed arrival rate at which the test stays
+// within the error-rate and p95 thresholds. It ramps the rate up exponentially
+// from MinRPS until a probe fails, then binary-searches between the last passing
+// and first failing rate. It returns the result of the best passing probe, with
+// CapacityRPS set, together with every probe run in order.
+func (r *Runner) DiscoverCapacity(ctx context.Context, config api.TestConfiguration, opts CapacityOptions) (api.TestResult, []api.TestResult, error) {
+	if opts.MinRPS <= 0 || opts.MaxRPS < opts.MinRPS {
+		return api.TestResult{}, nil, fmt.Errorf("invalid capacity range %.0f-%.0f RPS", opts.MinRPS, opts.MaxRPS)
+	}
+	if opts.StepSeconds <= 0 {
+		opts.StepSeconds = DefaultCapacityOptions().StepSeconds
+	}
+	if opts.Precision <= 0 {
+		opts.Precision = DefaultCapacityOptions().Precision
+	}
+
+	var (
+		probes []api.TestResult
+		best   api.TestResult
+		found  bool
+	)
+
+	probe := func(rate float64) (bool, error) {
+		step := config
+		step.RateRPS = rate
+		step.Requests = int(math.Ceil(rate * float64(opts.StepSeconds)))
+
+		r.logInfo("Capacity probe at %.1f RPS (%d requests)", rate, step.Requests)
+		result, err := r.RunTest(ctx, step)
+		if err != nil {
+			return false, err
+		}
+		probes = append(probes, result)
+
+		ok := r.withinThresholds(result, opts)
+		r.logInfo("  achieved %.1f RPS, p95 %.2f ms, errors %.2f%% -> %s",
+			result.RequestsPerSecond, result.P95ResponseTime, 100-result.SuccessRate, passFail(ok))
+		if ok {
+			best, found = result, true
+		}
+		return ok, nil
+	}
+
+	// Exponential ramp until the first failure
+	lo, hi := 0.0, 0.0
+	for rate := opts.MinRPS; ; rate *= 2 {
+		if rate > opts.MaxRPS {
+			rate = opts.MaxRPS
+		}
+		ok, err := probe(rate)
+		if err != nil {
+			return api.TestResult{}, probes, err
+		}
+		if !ok {
+			hi = rate
+			break
+		}
+		lo = rate
+		if rate >= opts.MaxRPS {
+			break
+		}
+	}
+
+	// Binary search between the last pass and the first failure
+	for hi > 0 && lo > 0 && (hi-lo)/hi > opts.Precision {
+		mid := (lo + hi) / 2
+		ok, err := probe(mid)
+		if err != nil {
+			return api.TestResult{}, probes, err
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	if !found {
+		return api.TestResult{}, probes, fmt.Errorf("no rate at or above %.1f RPS met the thresholds", opts.MinRPS)
+	}
+
+	best.CapacityRPS = lo
+	return best, probes, nil
+}
+
+// withinThresholds reports whether a probe kept up with its target rate and
+// stayed within the error-rate and latency limits.
+func (r *Runner) withinThresholds(result api.TestResult, opts CapacityOptions) bool {
+	if 100-result.SuccessRate > opts.MaxErrorRate {
+		return false
+	}
+	if opts.MaxP95 > 0 && result.P95ResponseTime > opts.MaxP95 {
+		return false
+	}
+	// A probe that could not sustain the requested rate is saturated
+	return result.RequestsPerSecond >= result.TargetRPS*0.9
+}
+
+func passFail(ok bool) string {
+	if ok {
+		return "pass"
+	}
+	return "fail"
+}
@@ -0,0 +1,157 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// ErrorClassChaos marks the failures chaos injected on purpose, so they are
+// told apart from failures of the target
+const ErrorClassChaos = "chaos"
+
+// defaultChaosDelay is how long a delayed request stalls without DelayMs
+const defaultChaosDelay = time.Second
+
+// Errors reported for the requests chaos broke
+var (
+	errChaosDropped   = errors.New("request dropped by client-side fault injection")
+	errChaosTruncated = errors.New("request body truncated by client-side fault injection")
+)
+
+// chaos injects client-side faults into a share of a test's requests
+type chaos struct {
+	config api.ChaosConfig
+	delay  time.Duration
+	clock  Clock
+}
+
+func newChaos(config api.ChaosConfig, clock Clock) (*chaos, error) {
+	for _, pct := range []float64{config.DropPercent, config.DelayPercent, config.TruncatePercent} {
+		if pct < 0 || pct > 100 {
+			return nil, fmt.Errorf("chaos percentages must be between 0 and 100, got %g", pct)
+		}
+	}
+	if total := config.DropPercent + config.DelayPercent + config.TruncatePercent; total > 100 {
+		return nil, fmt.Errorf("chaos percentages add up to %g, more than 100", total)
+	}
+	if config.DelayMs < 0 {
+		return nil, fmt.Errorf("chaos delay_ms can't be negative")
+	}
+	c := &chaos{config: config, delay: defaultChaosDelay, clock: clock}
+	if config.DelayMs > 0 {
+		c.delay = time.Duration(config.DelayMs) * time.Millisecond
+	}
+	return c, nil
+}
+
+// pick draws the fault for one request, "" for none
+func (c *chaos) pick() string {
+	x := rand.Float64() * 100
+	switch {
+	case x < c.config.DropPercent:
+		return api.FaultDrop
+	case x < c.config.DropPercent+c.config.DelayPercent:
+		return api.FaultDelay
+	case x < c.config.DropPercent+c.config.DelayPercent+c.config.TruncatePercent:
+		return api.FaultTruncate
+	}
+	return ""
+}
+
+// inject rigs req for fault and returns it with the fault actually applied:
+// truncating a request without a body drops it instead. A delayed request
+// without a body is held back here, before it is sent; release must be
+// called once the response is done with. ok is false if ctx ended first.
+func (c *chaos) inject(ctx context.Context, req *http.Request, fault string) (_ *http.Request, applied string, release func(), ok bool) {
+	release = func() {}
+	hasBody := req.Body != nil && req.Body != http.NoBody && req.ContentLength > 0
+	if fault == api.FaultTruncate && !hasBody {
+		fault = api.FaultDrop
+	}
+
+	switch fault {
+	case api.FaultDrop:
+		// Cancel the request as soon as it is on the wire, so the target
+		// sees the client disconnect before it can answer
+		dropCtx, cancel := context.WithCancel(req.Context())
+		trace := &httptrace.ClientTrace{WroteRequest: func(httptrace.WroteRequestInfo) { cancel() }}
+		req = req.WithContext(httptrace.WithClientTrace(dropCtx, trace))
+		release = cancel
+	case api.FaultTruncate:
+		req.Body = &truncatedBody{r: req.Body, left: req.ContentLength / 2}
+		req.GetBody = nil
+	case api.FaultDelay:
+		if !hasBody {
+			if !sleepContext(ctx, c.clock, c.delay) {
+				return req, fault, release, false
+			}
+			break
+		}
+		// The headers go out first; the body follows after the delay. A
+		// retry gets the body from GetBody, without the stall
+		req.Body = &stalledBody{r: req.Body, ctx: ctx, clock: c.clock, delay: c.delay}
+	}
+	return req, fault, release, true
+}
+
+// chaosError returns the error reported for a request that had fault
+// injected, nil for faults the request survives
+func chaosError(fault string) error {
+	switch fault {
+	case api.FaultDrop:
+		return errChaosDropped
+	case api.FaultTruncate:
+		return errChaosTruncated
+	}
+	return nil
+}
+
+// truncatedBody yields the first left bytes of a body, then fails so the
+// transport abandons the connection mid-body
+type truncatedBody struct {
+	r    io.ReadCloser
+	left int64
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	if b.left <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > b.left {
+		p = p[:b.left]
+	}
+	n, err := b.r.Read(p)
+	b.left -= int64(n)
+	return n, err
+}
+
+func (b *truncatedBody) Close() error { return b.r.Close() }
+
+// stalledBody holds a body back for delay before its first byte
+type stalledBody struct {
+	r       io.ReadCloser
+	ctx     context.Context
+	clock   Clock
+	delay   time.Duration
+	started bool
+}
+
+func (b *stalledBody) Read(p []byte) (int, error) {
+	if !b.started {
+		b.started = true
+		if !sleepContext(b.ctx, b.clock, b.delay) {
+			return 0, b.ctx.Err()
+		}
+	}
+	return b.r.Read(p)
+}
+
+func (b *stalledBody) Close() error { return b.r.Close() }
@@ -0,0 +1,131 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lazarkap/buzzbench.io/internal/clickhouse"
+	"github.com/lazarkap/buzzbench.io/internal/redact"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// Defaults for a test's clickhouse block
+const (
+	defaultClickHouseBatch = 10000
+	defaultClickHouseFlush = 5 * time.Second
+)
+
+// clickhouseTime is the DateTime64(6) format ClickHouse parses by default
+const clickhouseTime = "2006-01-02 15:04:05.000000"
+
+// clickhouseRow is one request as inserted into the table
+type clickhouseRow struct {
+	RunID      string  `json:"run_id"`
+	TestID     string  `json:"test_id"`
+	Test       string  `json:"test"`
+	Timestamp  string  `json:"timestamp"`
+	DurationMs float64 `json:"duration_ms"`
+	Status     int     `json:"status"`
+	Error      string  `json:"error"`
+	Endpoint   int     `json:"endpoint"`
+	RequestID  string  `json:"request_id"`
+}
+
+// clickhouseExport inserts the requests of one test run
+type clickhouseExport struct {
+	w     *clickhouse.Writer
+	runID string
+	table string
+}
+
+// clickhouseTableRe matches a table name, optionally qualified by its
+// database. The name is written into the INSERT query as is.
+var clickhouseTableRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// validateClickHouse checks a test's clickhouse block before the test starts
+func validateClickHouse(cfg *api.ClickHouseConfig) error {
+	switch {
+	case cfg == nil:
+		return nil
+	case cfg.Endpoint == "" || cfg.Table == "":
+		return fmt.Errorf("clickhouse requires an endpoint and a table")
+	case !clickhouseTableRe.MatchString(cfg.Table):
+		return fmt.Errorf("clickhouse: invalid table %q (want name or database.name)", cfg.Table)
+	case cfg.PasswordEnv == "":
+		return nil
+	}
+	// Tests may come from the platform, which mustn't get to send any of
+	// the machine's environment to an endpoint of its choosing
+	if err := api.CheckSecretEnv(cfg.PasswordEnv); err != nil {
+		return fmt.Errorf("clickhouse password_env: %w", err)
+	}
+	if os.Getenv(cfg.PasswordEnv) == "" {
+		return fmt.Errorf("clickhouse: %s is not set", cfg.PasswordEnv)
+	}
+	return nil
+}
+
+// startClickHouse starts exporting the test's requests, or returns nil when
+// it has no clickhouse block
+func (r *Runner) startClickHouse(config api.TestConfiguration) *clickhouseExport {
+	cfg := config.ClickHouse
+	if cfg == nil {
+		return nil
+	}
+	batch, flush := defaultClickHouseBatch, defaultClickHouseFlush
+	if cfg.BatchSize > 0 {
+		batch = cfg.BatchSize
+	}
+	if cfg.FlushSecs > 0 {
+		flush = time.Duration(cfg.FlushSecs) * time.Second
+	}
+	var password string
+	if cfg.PasswordEnv != "" {
+		password = os.Getenv(cfg.PasswordEnv)
+	}
+	return &clickhouseExport{
+		w:     clickhouse.NewWriter(cfg.Endpoint, cfg.Table, cfg.Username, password, batch, flush),
+		runID: uuid.NewString(),
+		table: cfg.Table,
+	}
+}
+
+// add queues a request, redacted like the test's errors
+func (e *clickhouseExport) add(config api.TestConfiguration, res api.RequestResult, red *redact.Redactor) {
+	if e == nil {
+		return
+	}
+	row := clickhouseRow{
+		RunID:      e.runID,
+		TestID:     config.ID,
+		Test:       config.Name,
+		Timestamp:  res.Timestamp.UTC().Format(clickhouseTime),
+		DurationMs: float64(res.Duration.Microseconds()) / 1000,
+		Status:     res.Status,
+		Endpoint:   res.Endpoint,
+		RequestID:  res.RequestID,
+	}
+	if res.Error != nil {
+		row.Error = red.String(res.Error.Error())
+	}
+	e.w.Add(row)
+}
+
+// finishClickHouse sends the remaining requests. Failed inserts are
+// reported rather than failing the test.
+func (r *Runner) finishClickHouse(e *clickhouseExport, red *redact.Redactor) {
+	if e == nil {
+		return
+	}
+	stats := e.w.Close()
+	if stats.Err != nil {
+		r.logInfo("ClickHouse export to %s lost %d of %d requests: %s",
+			e.table, stats.Failed, stats.Failed+stats.Rows, red.String(stats.Err.Error()))
+		return
+	}
+	r.logDebug("ClickHouse export: %d requests inserted into %s (run %s)", stats.Rows, e.table, e.runID)
+}
@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// endpointSet holds the endpoints of a multi-endpoint test, each as a copy
+// of the test configuration aimed at that endpoint
+type endpointSet struct {
+	configs    []api.TestConfiguration
+	tmpls      []*http.Request // prebuilt requests, for tests without variables
+	cumulative []float64       // running sum of the weights
+}
+
+// newEndpointSet resolves the test's endpoints against its URL
+func newEndpointSet(config api.TestConfiguration) (*endpointSet, error) {
+	base, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+
+	s := &endpointSet{}
+	total := 0.0
+	for _, e := range config.Endpoints {
+		if e.Weight < 0 {
+			return nil, fmt.Errorf("endpoint %s: negative weight", e.URL)
+		}
+
+		c := config
+		c.Endpoints = nil
+		c.URL = resolveEndpoint(base, config.URL, e.URL)
+		if e.Method != "" {
+			c.Method = e.Method
+		}
+		if e.Body != "" {
+			c.Body = e.Body
+		}
+		s.configs = append(s.configs, c)
+
+		weight := e.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		total += weight
+		s.cumulative = append(s.cumulative, total)
+	}
+	return s, nil
+}
+
+// resolveEndpoint resolves an endpoint URL against the test URL. It works on
+// strings rather than url.URL so {{placeholders}} survive unescaped.
+func resolveEndpoint(base *url.URL, baseURL, ref string) string {
+	switch {
+	case strings.Contains(ref, "://"):
+		return ref
+	case strings.HasPrefix(ref, "/"):
+		return base.Scheme + "://" + base.Host + ref
+	}
+	// Relative to the directory of the test URL's path
+	if i := strings.LastIndex(baseURL, "/"); i > len(base.Scheme)+2 {
+		return baseURL[:i+1] + ref
+	}
+	return baseURL + "/" + ref
+}
+
+// pick returns the index of a random endpoint, weighted
+func (s *endpointSet) pick() int {
+	if len(s.cumulative) == 1 {
+		return 0
+	}
+	x := rand.Float64() * s.cumulative[len(s.cumulative)-1]
+	return sort.SearchFloat64s(s.cumulative, x)
+}
@@ -0,0 +1,145 @@
+package runner
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// handshakeTarget resolves the host:port to dial and the TLS server name for handshake mode
+func handshakeTarget(config api.TestConfiguration) (addr, serverName string, err error) {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return "", "", fmt.Errorf("parse URL: %w", err)
+	}
+	if u.Hostname() == "" {
+		return "", "", fmt.Errorf("URL %q has no host", config.URL)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+
+	serverName = u.Hostname()
+	if config.SNIName != "" {
+		serverName = config.SNIName
+	}
+
+	return net.JoinHostPort(u.Hostname(), port), serverName, nil
+}
+
+// handshakeProtocol is the built-in protocol for handshake mode: a TCP
+// connect and TLS handshake without sending an HTTP request
+type handshakeProtocol struct {
+	config     api.TestConfiguration
+	dial       dialFunc
+	addr       string
+	serverName string
+}
+
+// Setup resolves the target and prepares the dialer
+func (p *handshakeProtocol) Setup(ctx context.Context, config api.TestConfiguration) error {
+	p.config = config
+
+	dial, err := newDialFunc(config)
+	if err != nil {
+		return fmt.Errorf("configure dialer: %w", err)
+	}
+	p.dial = dial
+
+	p.addr, p.serverName, err = handshakeTarget(config)
+	return err
+}
+
+// Teardown has nothing to release; every handshake closes its connection
+func (p *handshakeProtocol) Teardown() error {
+	return nil
+}
+
+// Execute performs one TCP connect and TLS handshake
+func (p *handshakeProtocol) Execute(ctx context.Context, reqIdx int) api.RequestResult {
+	if ctx.Err() != nil {
+		return api.RequestResult{}
+	}
+
+	if p.config.TimeoutSecs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(p.config.TimeoutSecs)*time.Second)
+		defer cancel()
+	}
+
+	start := time.Now()
+	result := api.RequestResult{Timestamp: start}
+
+	conn, err := p.dial(ctx, "tcp", p.addr)
+	if err != nil {
+		result.Duration = time.Since(start)
+		result.Error = handshakeError(err)
+		return result
+	}
+	defer conn.Close()
+	result.ConnectDuration = time.Since(start)
+
+	tlsCtx := ctx
+	if t := p.config.Timeouts; t != nil && t.TLSHandshakeMs > 0 {
+		var cancel context.CancelFunc
+		tlsCtx, cancel = context.WithTimeout(ctx, time.Duration(t.TLSHandshakeMs)*time.Millisecond)
+		defer cancel()
+	}
+	tlsStart := time.Now()
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: p.serverName})
+	err = tlsConn.HandshakeContext(tlsCtx)
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Error = handshakeError(err)
+	} else {
+		result.TLSDuration = time.Since(tlsStart)
+	}
+
+	return result
+}
+
+// handshakeError reduces a dial or handshake error to its failure reason, so
+// failures group together instead of differing by address or port.
+func handshakeError(err error) error {
+	var (
+		netErr      net.Error
+		certErr     *tls.CertificateVerificationError
+		unknownAuth x509.UnknownAuthorityError
+		hostErr     x509.HostnameError
+		invalidErr  x509.CertificateInvalidError
+		alertErr    tls.AlertError
+		recordErr   tls.RecordHeaderError
+	)
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return errors.New("timeout")
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return errors.New("connection refused")
+	case errors.Is(err, syscall.ECONNRESET):
+		return errors.New("connection reset")
+	case errors.As(err, &unknownAuth):
+		return errors.New("certificate: unknown authority")
+	case errors.As(err, &hostErr):
+		return errors.New("certificate: hostname mismatch")
+	case errors.As(err, &invalidErr):
+		return fmt.Errorf("certificate: invalid (%v)", invalidErr.Reason)
+	case errors.As(err, &certErr):
+		return errors.New("certificate: verification failed")
+	case errors.As(err, &alertErr):
+		return fmt.Errorf("tls alert: %v", alertErr)
+	case errors.As(err, &recordErr):
+		return errors.New("tls: server did not respond with TLS")
+	}
+	return err
+}
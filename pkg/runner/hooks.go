@@ -0,0 +1,290 @@
+package runner
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// maxHookBody caps how much of a response body hooks and assertions can see
+const maxHookBody = 1 << 20
+
+// readHookBody reads the start of a response body for hooks and assertions
+func readHookBody(resp *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHookBody))
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	return body, nil
+}
+
+// hooks runs a test's JavaScript hooks. The script may define
+//
+//	setup()                        run once before the test; its return value is ctx.data
+//	beforeRequest(req, ctx)        may change req.method, req.url, req.headers and req.body
+//	afterResponse(res, req, ctx)   throw, or return false or a message, to fail the request
+//
+// where ctx carries the request index, the setup data and vars(name), which
+// yields the next value of a test variable. A goja runtime is not safe for
+// concurrent use, so each worker borrows one from a pool.
+type hooks struct {
+	runner  *Runner
+	program *goja.Program
+	vars    func(name string, reqIdx int) (string, error)
+	data    []byte // setup's return value as JSON, decoded afresh per runtime
+
+	hasBefore, hasAfter bool
+	vms                 chan *hookVM
+}
+
+// hookVM is one runtime with the script loaded
+type hookVM struct {
+	rt            *goja.Runtime
+	before, after goja.Callable
+	data          interface{} // this runtime's copy of the setup data
+}
+
+// newHooks compiles the script and runs its setup function
+func (r *Runner) newHooks(script string, poolSize int, vars func(string, int) (string, error)) (*hooks, error) {
+	program, err := goja.Compile("script", script, false)
+	if err != nil {
+		return nil, fmt.Errorf("compile script: %w", err)
+	}
+
+	h := &hooks{
+		runner:  r,
+		program: program,
+		vars:    vars,
+		vms:     make(chan *hookVM, poolSize),
+	}
+
+	vm, err := h.newVM()
+	if err != nil {
+		return nil, err
+	}
+	h.hasBefore, h.hasAfter = vm.before != nil, vm.after != nil
+
+	if setup, ok := goja.AssertFunction(vm.rt.Get("setup")); ok {
+		v, err := setup(goja.Undefined())
+		if err != nil {
+			return nil, fmt.Errorf("setup: %w", err)
+		}
+		// Runtimes run concurrently, so each gets its own copy of the data
+		if h.data, err = json.Marshal(v.Export()); err != nil {
+			return nil, fmt.Errorf("setup: return value: %w", err)
+		}
+		if err := h.loadData(vm); err != nil {
+			return nil, err
+		}
+	}
+
+	h.put(vm)
+	return h, nil
+}
+
+// newVM creates a runtime with the helpers and the script loaded
+func (h *hooks) newVM() (*hookVM, error) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(goja.TagFieldNameMapper("json", true))
+
+	console := rt.NewObject()
+	console.Set("log", func(args ...interface{}) {
+		h.runner.logDebug("[script] %s", strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+	})
+	rt.Set("console", console)
+
+	crypto := rt.NewObject()
+	crypto.Set("sha256", func(msg string) string {
+		sum := sha256.Sum256([]byte(msg))
+		return hex.EncodeToString(sum[:])
+	})
+	crypto.Set("hmacSHA256", func(key, msg string) string {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(msg))
+		return hex.EncodeToString(mac.Sum(nil))
+	})
+	crypto.Set("base64", func(msg string) string {
+		return base64.StdEncoding.EncodeToString([]byte(msg))
+	})
+	rt.Set("crypto", crypto)
+
+	if _, err := rt.RunProgram(h.program); err != nil {
+		return nil, fmt.Errorf("run script: %w", err)
+	}
+
+	vm := &hookVM{rt: rt}
+	vm.before, _ = goja.AssertFunction(rt.Get("beforeRequest"))
+	vm.after, _ = goja.AssertFunction(rt.Get("afterResponse"))
+	if err := h.loadData(vm); err != nil {
+		return nil, err
+	}
+	return vm, nil
+}
+
+// loadData gives a runtime its own copy of the setup data
+func (h *hooks) loadData(vm *hookVM) error {
+	if h.data == nil {
+		return nil
+	}
+	if err := json.Unmarshal(h.data, &vm.data); err != nil {
+		return fmt.Errorf("setup data: %w", err)
+	}
+	return nil
+}
+
+// get borrows a runtime, creating one when the pool is empty
+func (h *hooks) get() (*hookVM, error) {
+	select {
+	case vm := <-h.vms:
+		return vm, nil
+	default:
+		return h.newVM()
+	}
+}
+
+// put returns a runtime to the pool
+func (h *hooks) put(vm *hookVM) {
+	select {
+	case h.vms <- vm:
+	default:
+	}
+}
+
+// hookContext builds the ctx argument passed to every hook
+func (h *hooks) hookContext(vm *hookVM, reqIdx int) *goja.Object {
+	ctx := vm.rt.NewObject()
+	ctx.Set("index", reqIdx)
+	ctx.Set("data", vm.data)
+	ctx.Set("vars", func(name string) (string, error) {
+		return h.vars(name, reqIdx)
+	})
+	return ctx
+}
+
+// hookRequest is the JavaScript view of an outgoing request
+type hookRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// hookResponse is the JavaScript view of a response
+type hookResponse struct {
+	Status     int               `json:"status"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+	DurationMs float64           `json:"duration_ms"`
+}
+
+// beforeRequest lets the script rewrite req in place
+func (h *hooks) beforeRequest(req *http.Request, body string, reqIdx int) error {
+	if !h.hasBefore {
+		return nil
+	}
+	vm, err := h.get()
+	if err != nil {
+		return err
+	}
+	defer h.put(vm)
+
+	hr := &hookRequest{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: flattenHeaders(req.Header),
+		Body:    body,
+	}
+	obj := vm.rt.ToValue(hr)
+	if _, err := vm.before(goja.Undefined(), obj, h.hookContext(vm, reqIdx)); err != nil {
+		return fmt.Errorf("beforeRequest: %w", err)
+	}
+
+	// Read back whatever the hook changed
+	if err := vm.rt.ExportTo(obj, hr); err != nil {
+		return fmt.Errorf("beforeRequest: %w", err)
+	}
+	u, err := url.Parse(hr.URL)
+	if err != nil {
+		return fmt.Errorf("beforeRequest: invalid url: %w", err)
+	}
+	req.Method = hr.Method
+	req.URL = u
+	req.Header = make(http.Header, len(hr.Headers))
+	for k, v := range hr.Headers {
+		if http.CanonicalHeaderKey(k) == "Host" {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	if hr.Body != body {
+		req.Body = io.NopCloser(strings.NewReader(hr.Body))
+		req.ContentLength = int64(len(hr.Body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(hr.Body)), nil
+		}
+	}
+	return nil
+}
+
+// afterResponse runs the script's assertions on a response
+func (h *hooks) afterResponse(resp *http.Response, body []byte, req *http.Request, duration time.Duration, reqIdx int) error {
+	if !h.hasAfter {
+		return nil
+	}
+
+	vm, err := h.get()
+	if err != nil {
+		return err
+	}
+	defer h.put(vm)
+
+	res := &hookResponse{
+		Status:     resp.StatusCode,
+		Headers:    flattenHeaders(resp.Header),
+		Body:       string(body),
+		DurationMs: durationMs(duration),
+	}
+	hr := &hookRequest{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: flattenHeaders(req.Header),
+	}
+	v, err := vm.after(goja.Undefined(), vm.rt.ToValue(res), vm.rt.ToValue(hr), h.hookContext(vm, reqIdx))
+	if err != nil {
+		return fmt.Errorf("afterResponse: %w", err)
+	}
+
+	switch out := v.Export().(type) {
+	case bool:
+		if !out {
+			return fmt.Errorf("assertion failed")
+		}
+	case string:
+		if out != "" {
+			return fmt.Errorf("assertion failed: %s", out)
+		}
+	}
+	return nil
+}
+
+// flattenHeaders keeps the first value of each header
+func flattenHeaders(header http.Header) map[string]string {
+	flat := make(map[string]string, len(header))
+	for k, v := range header {
+		if len(v) > 0 {
+			flat[k] = v[0]
+		}
+	}
+	return flat
+}
@@ -0,0 +1,215 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Azure/go-ntlmssp"
+	krbclient "github.com/jcmturner/gokrb5/v8/client"
+	krbconfig "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// newIntegratedAuth wraps base so that every request authenticates with
+// the test's Windows integrated authentication scheme
+func newIntegratedAuth(auth api.IntegratedAuth, base http.RoundTripper) (http.RoundTripper, error) {
+	switch strings.ToLower(auth.Scheme) {
+	case api.AuthNTLM:
+		if auth.Username == "" {
+			return nil, fmt.Errorf("ntlm authentication requires a username")
+		}
+		user := auth.Username
+		if auth.Domain != "" && !strings.ContainsAny(user, `\@`) {
+			user = auth.Domain + `\` + user
+		}
+		return &ntlmTransport{base: base, user: user, password: auth.Password}, nil
+
+	case api.AuthNegotiate:
+		client, err := kerberosClient(auth)
+		if err != nil {
+			return nil, err
+		}
+		return &spnegoTransport{base: base, client: client, spn: auth.SPN}, nil
+	}
+	return nil, fmt.Errorf("invalid integrated_auth scheme %q (want %q or %q)", auth.Scheme, api.AuthNegotiate, api.AuthNTLM)
+}
+
+// ntlmTransport runs the NTLM handshake for requests on connections that
+// aren't authenticated yet. NTLM authenticates connections, and all legs of
+// the handshake must use the same one, so each request in flight checks out
+// a transport of its own holding a single connection, and returns it once
+// its response body is closed. With keep-alive only each connection's first
+// request then pays for the handshake.
+type ntlmTransport struct {
+	base           http.RoundTripper
+	user, password string
+
+	mu   sync.Mutex
+	idle []*http.Transport // checked in, with their connection
+	all  []*http.Transport
+}
+
+func (t *ntlmTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The negotiator takes the credentials from basic auth and never sends
+	// them as such to a server that asks for NTLM
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.user, t.password)
+
+	base, ok := t.base.(*http.Transport)
+	if !ok {
+		// An injected transport decides about connections itself
+		return ntlmssp.Negotiator{RoundTripper: t.base}.RoundTrip(req)
+	}
+	conn := t.checkout(base)
+	resp, err := ntlmssp.Negotiator{RoundTripper: conn}.RoundTrip(req)
+	if err != nil {
+		t.checkin(conn)
+		return nil, err
+	}
+	resp.Body = &checkinBody{ReadCloser: resp.Body, checkin: sync.OnceFunc(func() { t.checkin(conn) })}
+	return resp, nil
+}
+
+// checkout returns an idle single-connection transport, or a new one
+func (t *ntlmTransport) checkout(base *http.Transport) *http.Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n := len(t.idle); n > 0 {
+		conn := t.idle[n-1]
+		t.idle = t.idle[:n-1]
+		return conn
+	}
+	conn := base.Clone()
+	conn.MaxConnsPerHost = 1
+	conn.MaxIdleConnsPerHost = 1
+	t.all = append(t.all, conn)
+	return conn
+}
+
+func (t *ntlmTransport) checkin(conn *http.Transport) {
+	t.mu.Lock()
+	t.idle = append(t.idle, conn)
+	t.mu.Unlock()
+}
+
+func (t *ntlmTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, conn := range t.all {
+		conn.CloseIdleConnections()
+	}
+	closeIdleConnections(t.base)
+}
+
+// checkinBody returns a response's transport for reuse once it is closed
+type checkinBody struct {
+	io.ReadCloser
+	checkin func()
+}
+
+func (b *checkinBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.checkin()
+	return err
+}
+
+// spnegoTransport sends a Kerberos service ticket with every request, in
+// a Negotiate Authorization header. Tickets are cached by the client, so
+// only the first request to a service asks the KDC for one.
+type spnegoTransport struct {
+	base   http.RoundTripper
+	client *krbclient.Client
+	spn    string // default HTTP/<host of the request>
+}
+
+func (t *spnegoTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	spn := t.spn
+	if spn == "" {
+		spn = "HTTP/" + req.URL.Hostname()
+	}
+	req = req.Clone(req.Context())
+	if err := spnego.SetSPNEGOHeader(t.client, req, spn); err != nil {
+		return nil, fmt.Errorf("kerberos: %w", err)
+	}
+	return t.base.RoundTrip(req)
+}
+
+func (t *spnegoTransport) CloseIdleConnections() { closeIdleConnections(t.base) }
+
+// closeIdleConnections closes the idle connections of a wrapped transport,
+// as http.Client.CloseIdleConnections does
+func closeIdleConnections(rt http.RoundTripper) {
+	if c, ok := rt.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// kerberosClient logs in with the keytab or password of auth, or without
+// either uses the tickets in the credential cache, as left by kinit
+func kerberosClient(auth api.IntegratedAuth) (*krbclient.Client, error) {
+	confPath := auth.Krb5Conf
+	if confPath == "" {
+		confPath = os.Getenv("KRB5_CONFIG")
+	}
+	if confPath == "" {
+		confPath = "/etc/krb5.conf"
+	}
+	conf, err := krbconfig.Load(confPath)
+	if err != nil {
+		return nil, fmt.Errorf("load kerberos config %s: %w", confPath, err)
+	}
+
+	user, realm := auth.Username, auth.Domain
+	if name, r, ok := strings.Cut(user, "@"); ok {
+		user, realm = name, r
+	}
+	if realm == "" {
+		realm = conf.LibDefaults.DefaultRealm
+	}
+	// Active Directory doesn't support FAST pre-authentication
+	noFAST := krbclient.DisablePAFXFAST(true)
+
+	var client *krbclient.Client
+	switch {
+	case auth.Keytab != "":
+		kt, err := keytab.Load(auth.Keytab)
+		if err != nil {
+			return nil, fmt.Errorf("load keytab %s: %w", auth.Keytab, err)
+		}
+		client = krbclient.NewWithKeytab(user, realm, kt, conf, noFAST)
+	case auth.Password != "":
+		client = krbclient.NewWithPassword(user, realm, auth.Password, conf, noFAST)
+	default:
+		path := credentialCachePath()
+		cache, err := credentials.LoadCCache(path)
+		if err != nil {
+			return nil, fmt.Errorf("no kerberos password or keytab, and no ticket cache at %s (run kinit): %w", path, err)
+		}
+		return krbclient.NewFromCCache(cache, conf, noFAST)
+	}
+	if user == "" {
+		return nil, fmt.Errorf("kerberos authentication with a password or keytab requires a username")
+	}
+	if err := client.Login(); err != nil {
+		return nil, fmt.Errorf("kerberos login as %s@%s: %w", user, realm, err)
+	}
+	return client, nil
+}
+
+// credentialCachePath returns the file credential cache named by
+// KRB5CCNAME, or the default one of the current user
+func credentialCachePath() string {
+	if name := os.Getenv("KRB5CCNAME"); name != "" {
+		return strings.TrimPrefix(name, "FILE:")
+	}
+	return "/tmp/krb5cc_" + strconv.Itoa(os.Getuid())
+}
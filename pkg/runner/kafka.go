@@ -0,0 +1,235 @@
+package runner
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+
+	"github.com/lazarkap/buzzbench.io/internal/redact"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// defaultKafkaFlush is the longest an event waits to be sent when a test's
+// kafka block doesn't set one
+const defaultKafkaFlush = time.Second
+
+// kafkaCloseTimeout bounds sending the last events of a test
+const kafkaCloseTimeout = 30 * time.Second
+
+// KafkaAvroSchema is the Avro schema of the events produced with the
+// "avro" encoding
+const KafkaAvroSchema = `{"type": "record", "name": "Request", "namespace": "io.buzzbench", "fields": [
+  {"name": "run_id", "type": "string"},
+  {"name": "test_id", "type": "string"},
+  {"name": "test", "type": "string"},
+  {"name": "time", "type": {"type": "long", "logicalType": "timestamp-micros"}},
+  {"name": "duration_ms", "type": "double"},
+  {"name": "status", "type": "int"},
+  {"name": "error", "type": "string"},
+  {"name": "endpoint", "type": "int"},
+  {"name": "request_id", "type": "string"}
+]}`
+
+// kafkaEvent is one request as produced to the topic
+type kafkaEvent struct {
+	RunID      string    `json:"run_id"`
+	TestID     string    `json:"test_id"`
+	Test       string    `json:"test"`
+	Time       time.Time `json:"time"`
+	DurationMs float64   `json:"duration_ms"`
+	Status     int       `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Endpoint   int       `json:"endpoint"`
+	RequestID  string    `json:"request_id,omitempty"`
+}
+
+// kafkaExport produces the requests of one test run
+type kafkaExport struct {
+	w     *kafka.Writer
+	runID string
+	topic string
+	avro  bool
+
+	mu     sync.Mutex
+	sent   int
+	failed int
+	err    error
+	broken bool // the topic couldn't be written to at all
+}
+
+// validateKafka checks a test's kafka block before the test starts
+func validateKafka(cfg *api.KafkaConfig) error {
+	switch {
+	case cfg == nil:
+		return nil
+	case len(cfg.Brokers) == 0 || cfg.Topic == "":
+		return fmt.Errorf("kafka requires brokers and a topic")
+	case cfg.Encoding != "" && cfg.Encoding != api.KafkaJSON && cfg.Encoding != api.KafkaAvro:
+		return fmt.Errorf("invalid kafka encoding %q (want %q or %q)", cfg.Encoding, api.KafkaJSON, api.KafkaAvro)
+	case cfg.Username != "" && !cfg.TLS:
+		return fmt.Errorf("kafka: SASL/PLAIN sends the password in the clear; set tls")
+	case cfg.PasswordEnv == "":
+		return nil
+	}
+	// As for clickhouse, a fetched test mustn't pick the variable sent
+	if err := api.CheckSecretEnv(cfg.PasswordEnv); err != nil {
+		return fmt.Errorf("kafka password_env: %w", err)
+	}
+	if os.Getenv(cfg.PasswordEnv) == "" {
+		return fmt.Errorf("kafka: %s is not set", cfg.PasswordEnv)
+	}
+	return nil
+}
+
+// startKafka starts producing the test's requests, or returns nil when it
+// has no kafka block
+func (r *Runner) startKafka(config api.TestConfiguration) *kafkaExport {
+	cfg := config.Kafka
+	if cfg == nil {
+		return nil
+	}
+	flush := defaultKafkaFlush
+	if cfg.FlushMs > 0 {
+		flush = time.Duration(cfg.FlushMs) * time.Millisecond
+	}
+	transport := &kafka.Transport{}
+	if cfg.TLS {
+		transport.TLS = &tls.Config{}
+	}
+	if cfg.Username != "" {
+		transport.SASL = plain.Mechanism{Username: cfg.Username, Password: os.Getenv(cfg.PasswordEnv)}
+	}
+
+	e := &kafkaExport{runID: uuid.NewString(), topic: cfg.Topic, avro: cfg.Encoding == api.KafkaAvro}
+	e.w = &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Topic:        cfg.Topic,
+		Balancer:     &kafka.Hash{}, // keyed by run, so a run's events stay in order
+		BatchTimeout: flush,
+		RequiredAcks: kafka.RequireOne,
+		Async:        true,
+		Transport:    transport,
+		Completion:   e.completed,
+	}
+	if cfg.BatchSize > 0 {
+		e.w.BatchSize = cfg.BatchSize
+	}
+	return e
+}
+
+// add produces a request, redacted like the test's errors
+func (e *kafkaExport) add(config api.TestConfiguration, res api.RequestResult, red *redact.Redactor) {
+	if e == nil {
+		return
+	}
+	ev := kafkaEvent{
+		RunID:      e.runID,
+		TestID:     config.ID,
+		Test:       config.Name,
+		Time:       res.Timestamp.UTC(),
+		DurationMs: float64(res.Duration.Microseconds()) / 1000,
+		Status:     res.Status,
+		Endpoint:   res.Endpoint,
+		RequestID:  res.RequestID,
+	}
+	if res.Error != nil {
+		ev.Error = red.String(res.Error.Error())
+	}
+
+	var value []byte
+	if e.avro {
+		value = ev.appendAvro(nil)
+	} else {
+		value, _ = json.Marshal(ev)
+	}
+	msg := kafka.Message{Key: []byte(e.runID), Value: value}
+
+	e.mu.Lock()
+	broken := e.broken
+	e.mu.Unlock()
+	if broken {
+		e.completed([]kafka.Message{msg}, nil)
+		return
+	}
+	// Async writes return at once and report delivery to completed, unless
+	// the topic's partitions can't even be looked up. Every further write
+	// would wait for the brokers again, holding up the test's results.
+	if err := e.w.WriteMessages(context.Background(), msg); err != nil {
+		e.mu.Lock()
+		e.broken = true
+		e.mu.Unlock()
+		e.completed([]kafka.Message{msg}, err)
+	}
+}
+
+// completed tallies a batch the writer is done with. Once the export is
+// broken, every message counts as failed.
+func (e *kafkaExport) completed(messages []kafka.Message, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil || e.broken {
+		e.failed += len(messages)
+		if e.err == nil {
+			e.err = err
+		}
+		return
+	}
+	e.sent += len(messages)
+}
+
+// finishKafka sends the remaining events. Failed deliveries are reported
+// rather than failing the test.
+func (r *Runner) finishKafka(e *kafkaExport, red *redact.Redactor) {
+	if e == nil {
+		return
+	}
+	closed := make(chan error, 1)
+	go func() { closed <- e.w.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			e.completed(nil, err)
+		}
+	case <-time.After(kafkaCloseTimeout):
+		e.completed(nil, fmt.Errorf("timed out after %s sending the last events", kafkaCloseTimeout))
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		r.logInfo("Kafka export to %s lost %d of %d requests: %s",
+			e.topic, e.failed, e.failed+e.sent, red.String(e.err.Error()))
+		return
+	}
+	r.logDebug("Kafka export: %d requests produced to %s (run %s)", e.sent, e.topic, e.runID)
+}
+
+// appendAvro appends the event's Avro binary encoding, per KafkaAvroSchema
+func (ev kafkaEvent) appendAvro(b []byte) []byte {
+	b = appendAvroString(b, ev.RunID)
+	b = appendAvroString(b, ev.TestID)
+	b = appendAvroString(b, ev.Test)
+	b = binary.AppendVarint(b, ev.Time.UnixMicro())
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(ev.DurationMs))
+	b = binary.AppendVarint(b, int64(ev.Status))
+	b = appendAvroString(b, ev.Error)
+	b = binary.AppendVarint(b, int64(ev.Endpoint))
+	return appendAvroString(b, ev.RequestID)
+}
+
+// appendAvroString appends an Avro string: its length as a zigzag varint,
+// which binary.AppendVarint writes, then its bytes
+func appendAvroString(b []byte, s string) []byte {
+	b = binary.AppendVarint(b, int64(len(s)))
+	return append(b, s...)
+}
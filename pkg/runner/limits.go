@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// Error classes for failures caused by the load generator running out of
+// resources rather than by the target
+const (
+	ErrorClassFiles = "fd_exhausted"
+	ErrorClassPorts = "ports_exhausted"
+)
+
+// FilesNeeded estimates the file descriptors a test at this concurrency
+// uses: one per connection plus headroom for the process itself
+func FilesNeeded(concurrency int) uint64 {
+	return uint64(concurrency) + 64
+}
+
+// checkLimits makes sure the process can open a connection per worker,
+// raising the soft file limit first when RaiseFileLimit is set. A short
+// ephemeral port range only produces a warning, since kept-alive connections
+// reuse their ports.
+func (r *Runner) checkLimits(config api.TestConfiguration) error {
+	need := FilesNeeded(config.Concurrency)
+	limit, err := OpenFileLimit()
+	if err == nil && limit < need && r.RaiseFileLimit {
+		if raised, raiseErr := RaiseOpenFileLimit(need); raiseErr == nil {
+			r.logDebug("Raised open file limit from %d to %d", limit, raised)
+			limit = raised
+		}
+	}
+	if err == nil && limit < need {
+		return fmt.Errorf("concurrency %d needs about %d open files but the limit is %d; raise it with ulimit -n %d or -raise-nofile", config.Concurrency, need, limit, need)
+	}
+
+	if low, high, err := EphemeralPortRange(); err == nil {
+		ports := high - low + 1
+		churn := config.DisableKeepAlives || config.Mode == api.ModeConnect || !isHTTPMode(config.Mode)
+		switch {
+		case ports < config.Concurrency:
+			r.logInfo("Warning: concurrency %d exceeds the %d ephemeral ports (%d-%d)", config.Concurrency, ports, low, high)
+		case churn && config.Requests > ports:
+			r.logInfo("Warning: %d new connections may exhaust the %d ephemeral ports (%d-%d) while closed ones sit in TIME_WAIT", config.Requests, ports, low, high)
+		}
+	}
+	return nil
+}
+
+// resourceError classifies errors caused by exhausted client resources,
+// returning the class and a fixed message so they group in ErrorCounts
+func resourceError(err error) (class, message string, ok bool) {
+	switch {
+	case errors.Is(err, syscall.EMFILE), errors.Is(err, syscall.ENFILE):
+		return ErrorClassFiles, "too many open files on the load generator; raise ulimit -n or lower concurrency", true
+	case errors.Is(err, syscall.EADDRNOTAVAIL):
+		return ErrorClassPorts, "no free local ports on the load generator; enable keep-alive, widen the port range or add source addresses", true
+	}
+	return "", "", false
+}
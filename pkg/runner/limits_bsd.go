@@ -0,0 +1,10 @@
+//go:build freebsd || dragonfly
+
+package runner
+
+import "syscall"
+
+// setRlimitCur sets a soft limit; FreeBSD and DragonFly use signed fields
+func setRlimitCur(rl *syscall.Rlimit, n uint64) {
+	rl.Cur = int64(n)
+}
@@ -0,0 +1,19 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+)
+
+// EphemeralPortRange reads the local port range used for outgoing connections
+func EphemeralPortRange() (int, int, error) {
+	data, err := os.ReadFile("/proc/sys/net/ipv4/ip_local_port_range")
+	if err != nil {
+		return 0, 0, err
+	}
+	var low, high int
+	if _, err := fmt.Sscan(string(data), &low, &high); err != nil {
+		return 0, 0, fmt.Errorf("parse port range: %w", err)
+	}
+	return low, high, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package runner
+
+import "errors"
+
+// EphemeralPortRange is only known on Linux
+func EphemeralPortRange() (int, int, error) {
+	return 0, 0, errors.New("not supported on this platform")
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package runner
+
+import "errors"
+
+var errLimitsUnsupported = errors.New("not supported on this platform")
+
+// OpenFileLimit is not available on this platform
+func OpenFileLimit() (uint64, error) {
+	return 0, errLimitsUnsupported
+}
+
+// RaiseOpenFileLimit is not available on this platform
+func RaiseOpenFileLimit(n uint64) (uint64, error) {
+	return 0, errLimitsUnsupported
+}
@@ -0,0 +1,10 @@
+//go:build unix && !freebsd && !dragonfly
+
+package runner
+
+import "syscall"
+
+// setRlimitCur sets a soft limit
+func setRlimitCur(rl *syscall.Rlimit, n uint64) {
+	rl.Cur = n
+}
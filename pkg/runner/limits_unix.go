@@ -0,0 +1,34 @@
+//go:build unix
+
+package runner
+
+import "syscall"
+
+// OpenFileLimit returns the soft limit on open file descriptors
+func OpenFileLimit() (uint64, error) {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return 0, err
+	}
+	return uint64(rl.Cur), nil
+}
+
+// RaiseOpenFileLimit raises the soft file descriptor limit to n, capped at
+// the hard limit, and returns the resulting soft limit
+func RaiseOpenFileLimit(n uint64) (uint64, error) {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return 0, err
+	}
+	if uint64(rl.Cur) >= n {
+		return uint64(rl.Cur), nil
+	}
+	if n > uint64(rl.Max) {
+		n = uint64(rl.Max)
+	}
+	setRlimitCur(&rl, n)
+	if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return 0, err
+	}
+	return OpenFileLimit()
+}
@@ -0,0 +1,127 @@
+package runner
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// luaAssert checks every response against a test's Lua assertion. The
+// assertion is either a single expression, e.g.
+//
+//	status == 200 and duration_ms < 250
+//
+// or a chunk that returns one. It sees the globals status, headers, body and
+// duration_ms. Returning false or nil, or raising an error (for example
+// with assert), fails the request; returning a string fails it with that
+// message. A chunk that returns nothing passes. An LState is not
+// safe for concurrent use, so each worker borrows one from a pool.
+type luaAssert struct {
+	proto  *lua.FunctionProto
+	states chan *lua.LState
+}
+
+// newLuaAssert compiles the assertion
+func newLuaAssert(src string, poolSize int) (*luaAssert, error) {
+	// Try it as an expression first so the common case needs no "return"
+	proto, err := compileLua("return " + src)
+	if err != nil {
+		if proto, err = compileLua(src); err != nil {
+			return nil, fmt.Errorf("compile Lua assertion: %w", err)
+		}
+	}
+	return &luaAssert{
+		proto:  proto,
+		states: make(chan *lua.LState, poolSize),
+	}, nil
+}
+
+func compileLua(src string) (*lua.FunctionProto, error) {
+	chunk, err := parse.Parse(strings.NewReader(src), "assert")
+	if err != nil {
+		return nil, err
+	}
+	return lua.Compile(chunk, "assert")
+}
+
+// newState returns a sandboxed state: no io, os or module loading
+func newState() *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		fn   lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.fn))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "require"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+	return L
+}
+
+// check runs the assertion against one response
+func (a *luaAssert) check(resp *http.Response, body []byte, duration time.Duration) error {
+	var L *lua.LState
+	select {
+	case L = <-a.states:
+	default:
+		L = newState()
+	}
+	defer func() {
+		select {
+		case a.states <- L:
+		default:
+			L.Close()
+		}
+	}()
+
+	headers := L.NewTable()
+	for k, v := range flattenHeaders(resp.Header) {
+		headers.RawSetString(k, lua.LString(v))
+	}
+	L.SetGlobal("status", lua.LNumber(resp.StatusCode))
+	L.SetGlobal("headers", headers)
+	L.SetGlobal("body", lua.LString(body))
+	L.SetGlobal("duration_ms", lua.LNumber(durationMs(duration)))
+
+	top := L.GetTop()
+	L.Push(L.NewFunctionFromProto(a.proto))
+	if err := L.PCall(0, lua.MultRet, nil); err != nil {
+		L.SetTop(top)
+		if apiErr, ok := err.(*lua.ApiError); ok {
+			// Drop the stack trace so identical failures group together
+			return fmt.Errorf("assertion failed: %s", apiErr.Object.String())
+		}
+		return fmt.Errorf("assertion failed: %w", err)
+	}
+	if L.GetTop() == top {
+		return nil
+	}
+	out := L.Get(top + 1)
+	L.SetTop(top)
+
+	switch out.Type() {
+	case lua.LTNil:
+		return fmt.Errorf("assertion failed")
+	case lua.LTBool:
+		if !lua.LVAsBool(out) {
+			return fmt.Errorf("assertion failed")
+		}
+	case lua.LTString:
+		if msg := out.String(); msg != "" {
+			return fmt.Errorf("assertion failed: %s", msg)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package runner
+
+import (
+	"context"
+	"net/http"
+)
+
+// DoFunc sends one HTTP request, like http.Client.Do
+type DoFunc func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps the sending of each HTTP request. It may change the
+// request before calling next, and observe or replace the response after.
+// Returning an error fails the request. Middleware runs inside the timed
+// section, so anything slow shows up in the response times.
+//
+//	r.Use(func(next runner.DoFunc) runner.DoFunc {
+//		return func(req *http.Request) (*http.Response, error) {
+//			req.Header.Set("X-Signature", sign(req))
+//			return next(req)
+//		}
+//	})
+type Middleware func(next DoFunc) DoFunc
+
+// Use adds middleware applied to every request of the HTTP and connect
+// modes. The first middleware added is the outermost. Use must not be
+// called while a test is running.
+func (r *Runner) Use(mw ...Middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// chain wraps do in the runner's middleware
+func (r *Runner) chain(do DoFunc) DoFunc {
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		do = r.middleware[i](do)
+	}
+	return do
+}
+
+type requestIndexKey struct{}
+
+// RequestIndex returns the index within its test of the request a
+// middleware is handling
+func RequestIndex(ctx context.Context) (int, bool) {
+	idx, ok := ctx.Value(requestIndexKey{}).(int)
+	return idx, ok
+}
@@ -0,0 +1,36 @@
+package runner
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// negotiates reports whether a test asks for a particular representation,
+// so its responses' media type and language are worth recording
+func negotiates(config api.TestConfiguration) bool {
+	for name := range config.Headers {
+		switch http.CanonicalHeaderKey(name) {
+		case "Accept", "Accept-Language":
+			return true
+		}
+	}
+	return false
+}
+
+// representation describes what a response was served as, e.g.
+// "application/json" or "text/html; de"
+func representation(resp *http.Response) string {
+	served := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(served); err == nil {
+		served = mediaType
+	}
+	if served == "" {
+		served = "-"
+	}
+	if lang := resp.Header.Get("Content-Language"); lang != "" {
+		served += "; " + lang
+	}
+	return served
+}
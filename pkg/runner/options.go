@@ -0,0 +1,126 @@
+package runner
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// Option configures a Runner. Options can be given to New for every test the
+// runner runs, or to RunTest for a single test.
+type Option func(*Runner)
+
+// MetricsSink receives measurements while tests run, e.g. to export them to
+// a monitoring system. Both methods are called from the goroutine that
+// aggregates a test's results, never concurrently for the same test.
+type MetricsSink interface {
+	// ObserveRequest is called for every completed request of a test
+	ObserveRequest(config api.TestConfiguration, res api.RequestResult)
+
+	// ObserveTest is called with each test's final result
+	ObserveTest(result api.TestResult)
+}
+
+// New creates a runner configured by opts. Without options it logs to the
+// standard logger and uses the same defaults as NewRunner.
+func New(opts ...Option) *Runner {
+	r := NewRunner(false, log.Default())
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// WithLogger sets the logger for progress and debug output
+func WithLogger(logger *log.Logger) Option {
+	return func(r *Runner) { r.Logger = logger }
+}
+
+// WithVerbose enables debug logging
+func WithVerbose(verbose bool) Option {
+	return func(r *Runner) { r.Verbose = verbose }
+}
+
+// WithTransport sends HTTP requests through rt instead of a transport built
+// from each test's connection settings, which are then ignored
+func WithTransport(rt http.RoundTripper) Option {
+	return func(r *Runner) { r.transport = rt }
+}
+
+// WithClock makes the runner tell time and wait with clock, so tests can
+// simulate pacing, think times and retries deterministically. Connection
+// timings, bandwidth caps and the test's overall time budget still follow
+// real time.
+func WithClock(clock Clock) Option {
+	return func(r *Runner) { r.clock = clock }
+}
+
+// WithMiddleware adds request middleware, as Use does
+func WithMiddleware(mw ...Middleware) Option {
+	return func(r *Runner) {
+		// Never append into a slice another runner may share
+		r.middleware = append(r.middleware[:len(r.middleware):len(r.middleware)], mw...)
+	}
+}
+
+// WithMetricsSink reports every request and test result to sink
+func WithMetricsSink(sink MetricsSink) Option {
+	return func(r *Runner) { r.sinks = append(r.sinks[:len(r.sinks):len(r.sinks)], sink) }
+}
+
+// WithSampleCallback calls fn with every request result as it is aggregated
+func WithSampleCallback(fn func(api.RequestResult)) Option {
+	return WithMetricsSink(sampleCallback(fn))
+}
+
+// WithSampleLimit keeps up to n raw latency samples in each result
+func WithSampleLimit(n int) Option {
+	return func(r *Runner) { r.SampleLimit = n }
+}
+
+// WithOutlierPercentile sets the outlier cutoff; zero disables outlier stats
+func WithOutlierPercentile(p float64) Option {
+	return func(r *Runner) { r.OutlierPercentile = p }
+}
+
+// WithProgressInterval logs a summary of a running test every d
+func WithProgressInterval(d time.Duration) Option {
+	return func(r *Runner) { r.ProgressInterval = d }
+}
+
+// WithMetricsOut writes JSON-line snapshots of running tests to w
+func WithMetricsOut(w io.Writer) Option {
+	return func(r *Runner) { r.MetricsOut = w }
+}
+
+// WithRequestLog writes every request as a JSON line to w
+func WithRequestLog(w io.Writer) Option {
+	return func(r *Runner) { r.RequestLog = w }
+}
+
+// WithSecrets masks values wherever results or logs could expose them
+func WithSecrets(secrets ...string) Option {
+	return func(r *Runner) { r.Secrets = append(r.Secrets[:len(r.Secrets):len(r.Secrets)], secrets...) }
+}
+
+// sampleCallback adapts a function to a MetricsSink
+type sampleCallback func(api.RequestResult)
+
+func (f sampleCallback) ObserveRequest(_ api.TestConfiguration, res api.RequestResult) { f(res) }
+func (f sampleCallback) ObserveTest(api.TestResult)                                    {}
+
+// with returns r itself when there are no options, otherwise a copy with
+// opts applied
+func (r *Runner) with(opts []Option) *Runner {
+	if len(opts) == 0 {
+		return r
+	}
+	c := *r
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return &c
+}
@@ -0,0 +1,138 @@
+package runner
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// workerPool is a set of long-lived goroutines that run submitted jobs.
+// A test borrows as many workers as its concurrency; the pool grows to the
+// largest concurrency seen and keeps its goroutines until closed.
+type workerPool struct {
+	jobs chan func()
+	mu   sync.Mutex
+	size int
+	wg   sync.WaitGroup
+}
+
+func newWorkerPool() *workerPool {
+	return &workerPool{jobs: make(chan func())}
+}
+
+// grow makes sure at least n workers exist
+func (p *workerPool) grow(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for ; p.size < n; p.size++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for job := range p.jobs {
+				job()
+			}
+		}()
+	}
+}
+
+// run executes n copies of job on pool workers and returns a channel that
+// is closed once all of them have returned
+func (p *workerPool) run(n int, job func()) <-chan struct{} {
+	p.grow(n)
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		p.jobs <- func() {
+			defer wg.Done()
+			job()
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// close stops the workers once they finish their current jobs
+func (p *workerPool) close() {
+	close(p.jobs)
+	p.wg.Wait()
+}
+
+// suite holds what is shared between the tests of one suite: the worker
+// pool and one transport per distinct set of connection options.
+type suite struct {
+	pool *workerPool
+
+	mu         sync.Mutex
+	transports map[string]*http.Transport
+}
+
+// BeginSuite makes subsequent tests share workers and HTTP transports
+// (including idle connections) until EndSuite is called. Each test still gets
+// its own client, so cookies and variables never leak between tests.
+func (r *Runner) BeginSuite() {
+	r.EndSuite()
+	r.suite = &suite{
+		pool:       newWorkerPool(),
+		transports: make(map[string]*http.Transport),
+	}
+}
+
+// EndSuite stops the shared workers and closes the shared connections
+func (r *Runner) EndSuite() {
+	if r.suite == nil {
+		return
+	}
+	r.suite.pool.close()
+	for _, t := range r.suite.transports {
+		t.CloseIdleConnections()
+	}
+	r.suite = nil
+}
+
+// transport returns the suite's transport for the test's connection options,
+// creating it on first use
+func (s *suite) transport(config api.TestConfiguration) (*http.Transport, error) {
+	key := transportKey(config)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.transports[key]; ok {
+		return t, nil
+	}
+	t, err := newTransport(config)
+	if err != nil {
+		return nil, err
+	}
+	s.transports[key] = t
+	return t, nil
+}
+
+// transportKey identifies the options that shape a transport; tests with
+// equal keys can share one
+func transportKey(config api.TestConfiguration) string {
+	var timeouts api.Timeouts
+	if config.Timeouts != nil {
+		timeouts = *config.Timeouts
+	}
+	return fmt.Sprintf("%s|%t|%d|%d|%d|%s|%s|%s|%s|%v",
+		config.SNIName,
+		config.DisableKeepAlives || config.Mode == api.ModeConnect,
+		config.MaxIdleConnsPerHost,
+		config.IdleConnTimeoutSecs,
+		config.MaxConnsPerHost,
+		config.IPFamily,
+		config.LocalAddr,
+		strings.Join(config.LocalAddrPool, ","),
+		config.Bandwidth,
+		timeouts,
+	)
+}
@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// preflight is a CORS preflight request and what it asked permission for
+type preflight struct {
+	origin, method string
+	headers        []string
+}
+
+// newPreflight turns req into the preflight a browser on origin would send
+// before it: an OPTIONS request naming its method and non-safelisted headers
+func newPreflight(req *http.Request, origin string) (*http.Request, *preflight) {
+	p := &preflight{origin: origin, method: req.Method}
+	for name, values := range req.Header {
+		if !corsSafelisted(name, values) {
+			p.headers = append(p.headers, strings.ToLower(name))
+		}
+	}
+	sort.Strings(p.headers)
+
+	pre := req.Clone(req.Context())
+	pre.Method = http.MethodOptions
+	pre.Body, pre.GetBody, pre.ContentLength = nil, nil, 0
+	pre.Header = http.Header{}
+	pre.Header.Set("Origin", origin)
+	pre.Header.Set("Access-Control-Request-Method", req.Method)
+	if len(p.headers) > 0 {
+		pre.Header.Set("Access-Control-Request-Headers", strings.Join(p.headers, ","))
+	}
+	return pre, p
+}
+
+// corsSafelisted reports whether a browser sends a header without asking
+// in the preflight first
+func corsSafelisted(name string, values []string) bool {
+	switch http.CanonicalHeaderKey(name) {
+	case "Accept", "Accept-Language", "Content-Language", "Origin":
+		return true
+	case "Content-Type":
+		mediaType, _, err := mime.ParseMediaType(strings.Join(values, ","))
+		return err == nil && (mediaType == "application/x-www-form-urlencoded" ||
+			mediaType == "multipart/form-data" || mediaType == "text/plain")
+	}
+	return false
+}
+
+// check fails a preflight response that would stop a browser from sending
+// the request
+func (p *preflight) check(resp *http.Response) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("cors: preflight returned %s", resp.Status)
+	}
+	if allowed := resp.Header.Get("Access-Control-Allow-Origin"); allowed != "*" && allowed != p.origin {
+		return fmt.Errorf("cors: origin %s not allowed (Access-Control-Allow-Origin: %q)", p.origin, allowed)
+	}
+
+	switch p.method {
+	case http.MethodGet, http.MethodHead, http.MethodPost:
+	default:
+		methods := headerList(resp.Header, "Access-Control-Allow-Methods")
+		if !methods["*"] && !methods[strings.ToLower(p.method)] {
+			return fmt.Errorf("cors: method %s not allowed", p.method)
+		}
+	}
+
+	allowed := headerList(resp.Header, "Access-Control-Allow-Headers")
+	for _, name := range p.headers {
+		// The wildcard never covers Authorization
+		if !allowed[name] && !(allowed["*"] && name != "authorization") {
+			return fmt.Errorf("cors: header %s not allowed", name)
+		}
+	}
+	return nil
+}
+
+// headerList parses a comma-separated response header into a set of
+// lower-cased tokens
+func headerList(h http.Header, name string) map[string]bool {
+	set := make(map[string]bool)
+	for _, value := range h.Values(name) {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				set[strings.ToLower(token)] = true
+			}
+		}
+	}
+	return set
+}
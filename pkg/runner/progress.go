@@ -0,0 +1,162 @@
+package runner
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// defaultMetricsInterval is how often snapshots are written to MetricsOut
+// when no ProgressInterval is set
+const defaultMetricsInterval = time.Second
+
+// metricsOutMu serialises writes to MetricsOut, which every copy of a runner
+// shares
+var metricsOutMu sync.Mutex
+
+// progress reports on a running test every interval: how many requests are
+// done, and the throughput, latency and error rate over the last interval
+// alone, so a test going bad shows up while it runs. It logs a one-line
+// summary when the runner has a ProgressInterval and writes a JSON line to
+// the runner's MetricsOut when set.
+type progress struct {
+	r      *Runner
+	config api.TestConfiguration
+	total  int
+	log    bool
+
+	stop chan struct{}
+	done sync.WaitGroup
+
+	mu      sync.Mutex
+	count   int // requests done in the whole test
+	window  *histogram
+	windowN int // requests done in the current interval
+	failed  int // of them, failures
+	started time.Time
+	last    time.Time
+}
+
+// startProgress begins reporting on a test of total requests, or returns nil
+// when the runner reports no progress
+func (r *Runner) startProgress(config api.TestConfiguration, total int) *progress {
+	if r.ProgressInterval <= 0 && r.MetricsOut == nil {
+		return nil
+	}
+	interval := r.ProgressInterval
+	if interval <= 0 {
+		interval = defaultMetricsInterval
+	}
+	now := time.Now()
+	p := &progress{
+		r:       r,
+		config:  config,
+		total:   total,
+		log:     r.ProgressInterval > 0,
+		stop:    make(chan struct{}),
+		window:  newHistogram(),
+		started: now,
+		last:    now,
+	}
+
+	p.done.Add(1)
+	go func() {
+		defer p.done.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.report(true)
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// add counts a finished request
+func (p *progress) add(res api.RequestResult) {
+	if p == nil {
+		return
+	}
+	failed := res.Error != nil || (isHTTPMode(p.config.Mode) && (res.Status < 200 || res.Status >= 400))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.count++
+	p.windowN++
+	p.window.Record(res.Duration)
+	if failed {
+		p.failed++
+	}
+}
+
+// snapshot returns the stats of the interval just ended and starts a new one
+func (p *progress) snapshot() api.IntervalStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	s := api.IntervalStats{
+		Test:     p.config.Name,
+		TestID:   p.config.ID,
+		Time:     now,
+		Elapsed:  now.Sub(p.started).Seconds(),
+		Done:     p.count,
+		Total:    p.total,
+		Requests: p.windowN,
+		Errors:   p.failed,
+		Latency:  p.window.Stats(),
+	}
+	if secs := now.Sub(p.last).Seconds(); secs > 0 {
+		s.RPS = float64(p.windowN) / secs
+	}
+	if p.windowN > 0 {
+		s.ErrorRate = float64(p.failed) / float64(p.windowN) * 100
+	}
+	p.window, p.windowN, p.failed, p.last = newHistogram(), 0, 0, now
+	return s
+}
+
+// report logs and writes the interval just ended; the final, partial
+// interval is only written, as the summary follows it anyway
+func (p *progress) report(log bool) {
+	s := p.snapshot()
+	if log && p.log {
+		done, p95 := 0.0, 0.0
+		if s.Total > 0 {
+			done = float64(s.Done) / float64(s.Total) * 100
+		}
+		if s.Latency != nil {
+			p95 = s.Latency.P95
+		}
+		p.r.logInfo("[%s] %d/%d (%.0f%%)  %.1f req/s  p95 %.2f ms  errors %.2f%%",
+			time.Duration(s.Elapsed*float64(time.Second)).Round(time.Second), s.Done, s.Total, done, s.RPS, p95, s.ErrorRate)
+	}
+	if p.r.MetricsOut != nil {
+		line, err := json.Marshal(s)
+		if err != nil {
+			return
+		}
+		metricsOutMu.Lock()
+		defer metricsOutMu.Unlock()
+		if _, err := p.r.MetricsOut.Write(append(line, '\n')); err != nil {
+			p.r.logDebug("Writing live metrics: %v", err)
+		}
+	}
+}
+
+// finish stops reporting, writing out the last interval
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	p.done.Wait()
+	if p.windowN > 0 {
+		p.report(false)
+	}
+}
@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// Protocol executes the requests of one test. A test selects its protocol
+// with its mode; HTTP, connect and handshake are built in and further
+// protocols (MQTT, Redis, raw TCP, ...) register themselves with
+// RegisterProtocol, so the runner loop never changes to support them.
+//
+// Execute is called concurrently by every worker of the test.
+type Protocol interface {
+	// Setup prepares the protocol for a test, e.g. by opening clients
+	Setup(ctx context.Context, config api.TestConfiguration) error
+
+	// Execute performs request reqIdx and reports its outcome. Results with
+	// a nil Error count as successes; a non-zero Status is recorded in the
+	// status code distribution. Returning a zero RequestResult means the
+	// request was not attempted because ctx ended, and it is discarded.
+	Execute(ctx context.Context, reqIdx int) api.RequestResult
+
+	// Teardown releases whatever Setup acquired
+	Teardown() error
+}
+
+// ProtocolFactory creates a fresh protocol instance for one test
+type ProtocolFactory func() Protocol
+
+var (
+	protocolsMu sync.RWMutex
+	protocols   = make(map[string]ProtocolFactory)
+)
+
+// RegisterProtocol makes a protocol available as a test mode. It is meant
+// to be called from the init function of a protocol package and panics if
+// the name is taken.
+func RegisterProtocol(mode string, factory ProtocolFactory) {
+	protocolsMu.Lock()
+	defer protocolsMu.Unlock()
+
+	if isBuiltinMode(mode) {
+		panic(fmt.Sprintf("runner: protocol %q is built in", mode))
+	}
+	if _, dup := protocols[mode]; dup {
+		panic(fmt.Sprintf("runner: protocol %q registered twice", mode))
+	}
+	protocols[mode] = factory
+}
+
+// Protocols lists the registered (non built-in) protocol modes
+func Protocols() []string {
+	protocolsMu.RLock()
+	defer protocolsMu.RUnlock()
+
+	modes := make([]string, 0, len(protocols))
+	for mode := range protocols {
+		modes = append(modes, mode)
+	}
+	sort.Strings(modes)
+	return modes
+}
+
+// isBuiltinMode reports whether the runner implements a mode itself
+func isBuiltinMode(mode string) bool {
+	switch mode {
+	case api.ModeHTTP, api.ModeConnect, api.ModeHandshake, api.ModeCache, api.ModePreflight:
+		return true
+	}
+	return false
+}
+
+// isHTTPMode reports whether a mode sends HTTP requests through httpProtocol
+func isHTTPMode(mode string) bool {
+	switch mode {
+	case api.ModeHTTP, api.ModeConnect, api.ModeCache, api.ModePreflight:
+		return true
+	}
+	return false
+}
+
+// newProtocol returns the protocol for the test's mode
+func (r *Runner) newProtocol(config api.TestConfiguration, varCtx *VariableContext) (Protocol, error) {
+	switch {
+	case isHTTPMode(config.Mode):
+		return &httpProtocol{runner: r, varCtx: varCtx}, nil
+	case config.Mode == api.ModeHandshake:
+		return &handshakeProtocol{}, nil
+	}
+
+	protocolsMu.RLock()
+	factory, ok := protocols[config.Mode]
+	protocolsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported test mode %q", config.Mode)
+	}
+	return factory(), nil
+}
@@ -0,0 +1,54 @@
+package runner
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/lazarkap/buzzbench.io/internal/redact"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// requestLogMu serialises writes to RequestLog, which every copy of a runner
+// shares
+var requestLogMu sync.Mutex
+
+// logRequest writes one request to the runner's RequestLog, redacted like
+// the test's errors
+func (r *Runner) logRequest(config api.TestConfiguration, res api.RequestResult, red *redact.Redactor) {
+	rec := api.RequestRecord{
+		Test:       config.Name,
+		Time:       res.Timestamp,
+		DurationMs: float64(res.Duration.Microseconds()) / 1000,
+		Status:     res.Status,
+		Endpoint:   res.Endpoint,
+		RequestID:  res.RequestID,
+		Item:       red.String(res.Item),
+	}
+	if res.Error != nil {
+		rec.Error = red.String(res.Error.Error())
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+
+	requestLogMu.Lock()
+	defer requestLogMu.Unlock()
+	if _, err := r.RequestLog.Write(append(line, '\n')); err != nil {
+		r.logDebug("Writing request log: %v", err)
+	}
+}
+
+// flushRequestLog writes out what a buffered RequestLog holds, once a test
+// is done
+func (r *Runner) flushRequestLog() {
+	f, ok := r.RequestLog.(interface{ Flush() error })
+	if !ok {
+		return
+	}
+	requestLogMu.Lock()
+	defer requestLogMu.Unlock()
+	if err := f.Flush(); err != nil {
+		r.logDebug("Writing request log: %v", err)
+	}
+}
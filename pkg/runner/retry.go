@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultRetryWait is waited before a retry when a response has no usable
+// Retry-After
+const defaultRetryWait = time.Second
+
+// retries records the retries of one request
+type retries struct {
+	count int
+	wait  time.Duration
+}
+
+// send sends req and, with RetryAfter, resends it while the response is a
+// 429 or 503, after the wait it asks for. It returns the last response and
+// when its attempt started. ok is false if ctx ended during a wait.
+func (p *httpProtocol) send(ctx context.Context, do DoFunc, req *http.Request) (resp *http.Response, start time.Time, r retries, ok bool, err error) {
+	timeout := time.Duration(p.config.TimeoutSecs) * time.Second
+	clock := p.runner.clk()
+	start = clock.Now()
+	resp, err = do(req)
+	for err == nil && r.count < p.config.RetryAfter && retryable(resp.StatusCode) {
+		wait, hasRetryAfter := retryAfter(resp.Header.Get("Retry-After"), clock.Now())
+		if !hasRetryAfter {
+			wait = defaultRetryWait
+		}
+		if timeout > 0 && wait > timeout {
+			break // give up as a client with this timeout would
+		}
+		retry, rerr := retryRequest(req)
+		if rerr != nil {
+			break
+		}
+		if p.backoff != nil {
+			p.backoff.observe(req.URL.Host, resp, clock.Now())
+		}
+
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if !sleepContext(ctx, clock, wait) {
+			return nil, start, r, false, nil
+		}
+		r.count++
+		r.wait += wait
+
+		req = retry
+		start = clock.Now()
+		resp, err = do(req)
+	}
+	return resp, start, r, true, err
+}
+
+// retryable reports whether a status asks the client to retry later
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable
+}
+
+// retryRequest returns a copy of req that can be sent again
+func retryRequest(req *http.Request) (*http.Request, error) {
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return retry, nil
+}
@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// eachLen returns the number of requests an each list makes
+func eachLen(e *api.EachConfig) int {
+	if len(e.Rows) > 0 {
+		return len(e.Rows)
+	}
+	return len(e.Values)
+}
+
+// eachItem describes request i's value or row, for reporting failures
+func eachItem(e *api.EachConfig, i int) string {
+	if len(e.Rows) > 0 {
+		return strings.Join(e.Rows[i], ",")
+	}
+	return e.Values[i]
+}
+
+// rowOverrides locates the columns of an each table that replace the test's
+// method, URL and body per request; -1 when the table has no such column
+type rowOverrides struct {
+	rows              [][]string
+	base              *url.URL
+	baseURL           string
+	method, url, body int
+}
+
+// newRowOverrides returns nil when the test has no table or the table
+// overrides nothing
+func newRowOverrides(config api.TestConfiguration) (*rowOverrides, error) {
+	if config.Each == nil || len(config.Each.Rows) == 0 {
+		return nil, nil
+	}
+	o := &rowOverrides{rows: config.Each.Rows, baseURL: config.URL, method: -1, url: -1, body: -1}
+	for i, column := range config.Each.Columns {
+		switch strings.ToLower(strings.TrimSpace(column)) {
+		case "method":
+			o.method = i
+		case "url", "path":
+			o.url = i
+		case "body":
+			o.body = i
+		}
+	}
+	if o.method < 0 && o.url < 0 && o.body < 0 {
+		return nil, nil
+	}
+	if o.url >= 0 {
+		var err error
+		if o.base, err = url.Parse(config.URL); err != nil {
+			return nil, fmt.Errorf("invalid url: %w", err)
+		}
+	}
+	return o, nil
+}
+
+// apply replaces config's method, URL and body with those of request
+// reqIdx's row; empty cells keep the test's
+func (o *rowOverrides) apply(config *api.TestConfiguration, reqIdx int) {
+	row := o.rows[reqIdx%len(o.rows)]
+	cell := func(i int) string {
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	if m := cell(o.method); m != "" {
+		config.Method = strings.ToUpper(m)
+	}
+	if u := cell(o.url); u != "" {
+		config.URL = resolveEndpoint(o.base, o.baseURL, u)
+	}
+	if o.body >= 0 && o.body < len(row) && row[o.body] != "" {
+		config.Body = row[o.body]
+	}
+}
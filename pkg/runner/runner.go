@@ -0,0 +1,1477 @@
+// Package runner executes BuzzBench load tests. It is the engine behind the
+// buzzbench command and can be embedded in other Go programs and test
+// harnesses:
+//
+//	r := runner.New(runner.WithLogger(logger))
+//	result, err := r.RunTest(ctx, api.TestConfiguration{
+//		Name:        "health",
+//		URL:         "https://example.com/health",
+//		Method:      "GET",
+//		Requests:    1000,
+//		Concurrency: 20,
+//		TimeoutSecs: 10,
+//	})
+//
+// The exported API follows semantic versioning: it only changes
+// incompatibly with a new major version.
+package runner
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lazarkap/buzzbench.io/internal/redact"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// Runner handles test execution
+type Runner struct {
+	Verbose bool
+	Logger  *log.Logger
+
+	// SampleLimit keeps up to this many raw latency samples in each result,
+	// chosen by reservoir sampling. Zero keeps none.
+	SampleLimit int
+
+	// OutlierPercentile is the latency percentile above which samples count as
+	// outliers for the trimmed and winsorized means. Zero disables them.
+	OutlierPercentile float64
+
+	// Secrets are values masked wherever results or logs could expose them,
+	// in addition to each test's auth token and sensitive variables.
+	// RedactParams names extra query parameters whose values are masked.
+	Secrets      []string
+	RedactParams []string
+
+	// RaiseFileLimit lets a test raise the soft open-file limit when its
+	// concurrency needs more descriptors than currently allowed
+	RaiseFileLimit bool
+
+	// ProgressInterval is how often a running test logs a one-line summary
+	// of its last interval. Zero logs none.
+	ProgressInterval time.Duration
+
+	// MetricsOut receives a JSON line with an api.IntervalStats snapshot of
+	// a running test every ProgressInterval, or every second without one
+	MetricsOut io.Writer
+
+	// RequestLog receives a JSON line with an api.RequestRecord for every
+	// request. A Flush method, if it has one, is called after each test.
+	RequestLog io.Writer
+
+	// suite is set between BeginSuite and EndSuite
+	suite *suite
+
+	// middleware wraps every HTTP request; see Use
+	middleware []Middleware
+
+	// transport, sinks and clock are set by WithTransport, WithMetricsSink
+	// and WithClock
+	transport http.RoundTripper
+	sinks     []MetricsSink
+	clock     Clock
+}
+
+// Clock is the source of time a runner schedules and measures requests
+// with; see WithClock
+type Clock = api.Clock
+
+// clk returns the runner's clock, the system clock unless WithClock set one
+func (r *Runner) clk() Clock {
+	if r.clock == nil {
+		return api.SystemClock
+	}
+	return r.clock
+}
+
+// since returns the time elapsed since t on the runner's clock
+func (r *Runner) since(t time.Time) time.Duration {
+	return r.clk().Now().Sub(t)
+}
+
+// DefaultOutlierPercentile is the outlier cutoff used by NewRunner
+const DefaultOutlierPercentile = 99
+
+// NewRunner creates a new test runner
+func NewRunner(verbose bool, logger *log.Logger) *Runner {
+	return &Runner{
+		Verbose:           verbose,
+		Logger:            logger,
+		OutlierPercentile: DefaultOutlierPercentile,
+	}
+}
+
+// Variable represents a test variable definition
+type Variable struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`       // string, integer, float, boolean, uuid, timestamp
+	Strategy   string   `json:"strategy"`   // static, sequential, random, choice, uuid, uuidv7, ulid, idempotency_key, timestamp, template
+	Value      string   `json:"value"`      // for static
+	StartValue int      `json:"startValue"` // for sequential
+	EndValue   int      `json:"endValue"`   // for sequential
+	Increment  int      `json:"increment"`  // for sequential
+	Format     string   `json:"format"`     // for sequential: printf layout, e.g. "user-%05d"; for timestamp: unix, unixmilli, rfc3339 (default) or a Go layout
+	Offset     string   `json:"offset"`     // for timestamp: added to the current time, e.g. "-1h"
+	MinValue   int      `json:"minValue"`   // for random
+	MaxValue   int      `json:"maxValue"`   // for random
+	Template   string   `json:"template"`   // for template
+	Length     int      `json:"length"`     // for random strings, default 16
+	Charset    string   `json:"charset"`    // for random strings: alnum (default), hex, base64url or the characters to use
+	Values     []string `json:"values"`     // for choice
+	Selection  string   `json:"selection"`  // for choice: round_robin (default) or random
+	Sensitive  bool     `json:"sensitive"`  // mask the value in logs and results
+	Scope      string   `json:"scope"`      // "user": one value per virtual user of a replay or scenario
+	Reuse      int      `json:"reuse"`      // for idempotency_key: consecutive requests sharing each key, default 1
+	current    int      // internal counter for sequential
+
+	offset time.Duration // parsed Offset for timestamp
+	seed   uuid.UUID     // per-run namespace of idempotency keys
+}
+
+// VariableContext holds the current state for variable generation
+type VariableContext struct {
+	Variables    map[string]*Variable
+	RequestIndex int
+	Rand         *rand.Rand // Pre-seeded random generator
+	Mutex        sync.Mutex // For thread-safe updates
+	Sensitive    bool       // Whether any variable is marked sensitive
+
+	// Users is the number of virtual users replaying a recording; request i
+	// belongs to user i % Users. userValues holds user-scoped values.
+	Users      int
+	userMu     sync.Mutex
+	userValues map[userKey]string
+
+	// In a scenario each worker is a virtual user: steps converts a request
+	// index to its iteration and iterationVUs maps an iteration to the VU
+	// running it. Values extracted from responses are stored per VU.
+	steps        int
+	iterationVUs sync.Map
+}
+
+// userKey identifies a user-scoped variable value
+type userKey struct {
+	name string
+	user int
+}
+
+// userValue returns the value of a user-scoped variable for a user,
+// generating it on first use
+func (ctx *VariableContext) userValue(name string, user int, generate func() (string, error)) (string, error) {
+	key := userKey{name, user}
+	ctx.userMu.Lock()
+	value, ok := ctx.userValues[key]
+	ctx.userMu.Unlock()
+	if ok {
+		return value, nil
+	}
+
+	// Generated unlocked: strategies take ctx.Mutex. If two requests race,
+	// the first value stored wins.
+	value, err := generate()
+	if err != nil {
+		return "", err
+	}
+	ctx.userMu.Lock()
+	defer ctx.userMu.Unlock()
+	if stored, ok := ctx.userValues[key]; ok {
+		return stored, nil
+	}
+	if ctx.userValues == nil {
+		ctx.userValues = make(map[userKey]string)
+	}
+	ctx.userValues[key] = value
+	return value, nil
+}
+
+// storedValue returns a user's stored value of a variable, if any
+func (ctx *VariableContext) storedValue(name string, user int) (string, bool) {
+	ctx.userMu.Lock()
+	defer ctx.userMu.Unlock()
+	value, ok := ctx.userValues[userKey{name, user}]
+	return value, ok
+}
+
+// setUserValue stores a value for a user, replacing any earlier one
+func (ctx *VariableContext) setUserValue(name string, user int, value string) {
+	ctx.userMu.Lock()
+	defer ctx.userMu.Unlock()
+	if ctx.userValues == nil {
+		ctx.userValues = make(map[userKey]string)
+	}
+	ctx.userValues[userKey{name, user}] = value
+}
+
+// intn returns a random int in [0, n); Rand is shared by every worker
+func (ctx *VariableContext) intn(n int) int {
+	ctx.Mutex.Lock()
+	defer ctx.Mutex.Unlock()
+	return ctx.Rand.Intn(n)
+}
+
+// float64 returns a random float in [0, 1)
+func (ctx *VariableContext) float64() float64 {
+	ctx.Mutex.Lock()
+	defer ctx.Mutex.Unlock()
+	return ctx.Rand.Float64()
+}
+
+// charsets are the named character sets for random string variables
+var charsets = map[string]string{
+	"alnum":     "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+	"hex":       "0123456789abcdef",
+	"base64url": "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_",
+}
+
+// randomString returns length characters drawn from charset, which is a
+// name from charsets or the characters themselves
+func (ctx *VariableContext) randomString(length int, charset string) string {
+	if length <= 0 {
+		length = 16
+	}
+	chars, ok := charsets[charset]
+	if !ok {
+		chars = charset
+	}
+	if chars == "" {
+		chars = charsets["alnum"]
+	}
+
+	b := make([]byte, length)
+	ctx.Mutex.Lock()
+	for i := range b {
+		b[i] = chars[ctx.Rand.Intn(len(chars))]
+	}
+	ctx.Mutex.Unlock()
+	return string(b)
+}
+
+// RunTest executes a performance test based on the provided configuration.
+// Options apply to this test only. Cancelling ctx ends the test early; the
+// result then covers the requests completed so far.
+func (r *Runner) RunTest(ctx context.Context, config api.TestConfiguration, opts ...Option) (api.TestResult, error) {
+	r = r.with(opts)
+
+	if config.Each != nil {
+		if eachLen(config.Each) == 0 {
+			return api.TestResult{}, errors.New("each: no values to iterate over")
+		}
+		config.Requests = eachLen(config.Each)
+		config.UseVariables = true
+	}
+	if len(config.Steps) > 0 {
+		if err := validateSteps(config); err != nil {
+			return api.TestResult{}, err
+		}
+		config.UseVariables = true
+	}
+	if len(config.VUSetup) > 0 {
+		if len(config.Replay) > 0 || config.Each != nil {
+			return api.TestResult{}, errors.New("vu_setup can't be combined with replay or each")
+		}
+		config.UseVariables = true
+	}
+	if len(config.Replay) > 0 {
+		if len(config.Endpoints) > 0 || config.Each != nil {
+			return api.TestResult{}, errors.New("replay can't be combined with endpoints or each")
+		}
+		config.Requests = len(config.Replay)
+		if config.ReplayUsers > 0 {
+			// Request i is step i / ReplayUsers of user i % ReplayUsers
+			config.Requests *= config.ReplayUsers
+			config.UseVariables = true
+		}
+	}
+
+	// Initialize variable context if needed
+	var varCtx *VariableContext
+	if config.UseVariables {
+		r.logDebug("Using variables for this test")
+		var err error
+		if varCtx, err = r.setupVariableContext(config); err != nil {
+			return api.TestResult{}, err
+		}
+	}
+	red := r.redactor(config, varCtx)
+
+	r.logDebug("Starting test: %s", config.Name)
+	r.logDebug("URL: %s", red.String(config.URL))
+	r.logDebug("Method: %s", config.Method)
+	r.logDebug("Requests: %d", config.Requests)
+	r.logDebug("Concurrency: %d", config.Concurrency)
+
+	proto, err := r.newProtocol(config, varCtx)
+	if err != nil {
+		return api.TestResult{}, err
+	}
+	var setup vuSetupper
+	if len(config.VUSetup) > 0 {
+		var ok bool
+		if setup, ok = proto.(vuSetupper); !ok {
+			return api.TestResult{}, fmt.Errorf("vu_setup is not supported in %s mode", config.Mode)
+		}
+	}
+
+	if err := r.checkLimits(config); err != nil {
+		return api.TestResult{}, err
+	}
+	if err := validateClickHouse(config.ClickHouse); err != nil {
+		return api.TestResult{}, err
+	}
+	if err := validateKafka(config.Kafka); err != nil {
+		return api.TestResult{}, err
+	}
+
+	// Create context with timeout: roughly a second per request per worker,
+	// plus any injected delay, and at least the duration of a fixed-rate schedule
+	perWorker := config.Requests / config.Concurrency
+	budget := time.Duration(perWorker+10)*time.Second +
+		time.Duration(perWorker*(config.DelayMs+config.JitterMs))*time.Millisecond
+	if len(config.Steps) > 0 {
+		// Requests counts iterations, each several requests and pauses
+		budget = time.Duration(perWorker+1)*iterationBudget(config.Steps) + 10*time.Second
+	}
+	budget += iterationBudget(config.VUSetup)
+	if config.RateRPS > 0 {
+		if scheduled := time.Duration((float64(config.Requests)/config.RateRPS + 10) * float64(time.Second)); scheduled > budget {
+			budget = scheduled
+		}
+	}
+	timed := config.ReplaySpeed > 0 && len(config.Replay) > 0
+	if timed {
+		last := config.Replay[len(config.Replay)-1].Offset
+		if scheduled := time.Duration((last/config.ReplaySpeed + 10) * float64(time.Second)); scheduled > budget {
+			budget = scheduled
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+
+	result := api.TestResult{
+		TestConfigurationID: config.ID,
+		URL:                 red.String(config.URL),
+		Method:              config.Method,
+		Requests:            config.Requests,
+		Concurrency:         config.Concurrency,
+		TargetRPS:           config.RateRPS,
+		StatusCodes:         make(map[string]int),
+		ErrorCounts:         make(map[string]int),
+		Errors:              []api.ErrorData{},
+		Timeline:            []api.TimelinePoint{},
+	}
+	if len(config.Steps) > 0 {
+		result.Requests *= len(config.Steps)
+	}
+
+	if err := proto.Setup(ctx, config); err != nil {
+		return api.TestResult{}, err
+	}
+	defer func() {
+		if err := proto.Teardown(); err != nil {
+			r.logInfo("Protocol teardown: %v", err)
+		}
+	}()
+
+	// Fixed-size channels: memory stays flat however many requests the test sends
+	resultChan := make(chan api.RequestResult, config.Concurrency*4)
+	requestChan := make(chan int, config.Concurrency)
+
+	// The measured phase begins once every VU has run its setup
+	measured := make(chan struct{})
+
+	// offset returns when request i is scheduled, from the start of a paced test
+	paced := timed || config.RateRPS > 0
+	offset := func(i int) time.Duration {
+		var at float64 // seconds from start
+		switch {
+		case timed:
+			at = config.Replay[i/max(config.ReplayUsers, 1)].Offset / config.ReplaySpeed
+		case config.RateRPS > 0:
+			at = float64(i) / config.RateRPS
+		}
+		return time.Duration(at * float64(time.Second))
+	}
+	// Set before the first request is handed out, read by the workers
+	var scheduleStart time.Time
+
+	// Prepare request indices, paced when a fixed arrival rate or a replay
+	// speed is configured
+	go func() {
+		defer close(requestChan)
+		select {
+		case <-measured:
+		case <-ctx.Done():
+			return
+		}
+		scheduleStart = r.clk().Now()
+		for i := 0; i < config.Requests; i++ {
+			if at := offset(i); at > 0 && !sleepContext(ctx, r.clk(), scheduleStart.Add(at).Sub(r.clk().Now())) {
+				return
+			}
+			select {
+			case requestChan <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Workers come from the suite's pool when there is one, otherwise from a
+	// pool that lives for this test only
+	pool := newWorkerPool()
+	if r.suite != nil {
+		pool = r.suite.pool
+	} else {
+		defer pool.close()
+	}
+	var vus atomic.Int64
+	var ready sync.WaitGroup
+	tally := newSetupTally()
+	if setup != nil {
+		ready.Add(config.Concurrency)
+	}
+	done := pool.run(config.Concurrency, func() {
+		vu := int(vus.Add(1)) - 1
+		if setup != nil {
+			start := r.clk().Now()
+			err := setup.setupVU(ctx, vu)
+			tally.add(r.since(start), err)
+			ready.Done()
+			if err != nil {
+				r.logInfo("VU %d setup failed: %v", vu, err)
+				return
+			}
+		}
+		for {
+			select {
+			case reqIdx, ok := <-requestChan:
+				if !ok {
+					return // Channel closed
+				}
+				if len(config.Steps) > 0 {
+					r.runIteration(ctx, proto, config, varCtx, vu, reqIdx, resultChan)
+					continue
+				}
+				if setup != nil {
+					varCtx.iterationVUs.Store(reqIdx, vu)
+				}
+				res := proto.Execute(ctx, reqIdx)
+				if setup != nil {
+					varCtx.iterationVUs.Delete(reqIdx)
+				}
+				if res.Timestamp.IsZero() {
+					continue // not attempted before the test ended
+				}
+				if paced {
+					// A request that waited for a free worker started late
+					// through no fault of its own; measuring from the
+					// schedule keeps that wait in the latency users see
+					res.Intended = scheduleStart.Add(offset(reqIdx))
+				}
+				if config.Each != nil {
+					res.Item = eachItem(config.Each, reqIdx)
+				}
+				resultChan <- res
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+
+	if setup != nil {
+		ready.Wait()
+		result.VUSetup = tally.finish()
+		if result.VUSetup.Failed == config.Concurrency {
+			cancel()
+			<-done
+			return api.TestResult{}, fmt.Errorf("vu setup failed for all %d virtual users: %w", config.Concurrency, tally.first)
+		}
+	}
+	close(measured)
+
+	// Close result channel when all workers are done
+	go func() {
+		<-done
+		close(resultChan)
+	}()
+
+	// Process results as they arrive
+	telem := startTelemetry()
+	startTime := r.clk().Now()
+	agg := newAggregator(r, config, red, &result)
+	prog := r.startProgress(config, result.Requests)
+	export := r.startClickHouse(config)
+	events := r.startKafka(config)
+	for res := range resultChan {
+		agg.add(res)
+		prog.add(res)
+		if r.RequestLog != nil {
+			r.logRequest(config, res, red)
+		}
+		export.add(config, res, red)
+		events.add(config, res, red)
+		for _, sink := range r.sinks {
+			sink.ObserveRequest(config, res)
+		}
+	}
+	endTime := r.clk().Now()
+	prog.finish()
+	if r.RequestLog != nil {
+		r.flushRequestLog()
+	}
+	r.finishClickHouse(export, red)
+	r.finishKafka(events, red)
+	agg.finish(endTime.Sub(startTime))
+	result.Generator = telem.finish()
+
+	if config.Prometheus != nil && len(config.Prometheus.Queries) > 0 {
+		result.ServerMetrics = r.collectServerMetrics(config.Prometheus, startTime, endTime)
+	}
+
+	r.logDebug("Test completed successfully")
+	r.logDebug("Success Rate: %.2f%%", result.SuccessRate)
+	r.logDebug("Avg Response Time: %.2f ms", result.AvgResponseTime)
+	r.logDebug("Min Response Time: %.2f ms", result.MinResponseTime)
+	r.logDebug("Max Response Time: %.2f ms", result.MaxResponseTime)
+	r.logDebug("P95 Response Time: %.2f ms", result.P95ResponseTime)
+	r.logDebug("Requests Per Second: %.2f", result.RequestsPerSecond)
+
+	for _, sink := range r.sinks {
+		sink.ObserveTest(result)
+	}
+
+	return result, nil
+}
+
+// httpProtocol is the built-in protocol for regular HTTP tests and connect mode
+type httpProtocol struct {
+	runner *Runner
+	varCtx *VariableContext
+	config api.TestConfiguration
+	client *http.Client
+	do     DoFunc
+	tmpl   *http.Request
+	hooks  *hooks
+	lua    *luaAssert
+
+	endpoints *endpointSet        // nil unless the test has endpoints or steps
+	scenario  bool                // endpoints are steps, chosen by request index
+	replay    []api.ReplayRequest // recorded requests with resolved URLs
+	rows      *rowOverrides       // nil unless an each table overrides requests
+	cache     *validatorCache     // cache mode only
+	bustID    string              // per-run prefix of cache-busting values
+	negotiate bool                // the test sends Accept or Accept-Language
+	reused    sync.Map            // URL template -> *url.URL first sent for it
+	sessions  *sessions           // per-VU clients and extract rules of a scenario
+	backoff   *hostBackoff        // tests with Backoff only
+	chaos     *chaos              // tests with Chaos only
+	agents    *userAgents         // tests with UserAgents only
+}
+
+// Setup builds the test's HTTP client and, for tests without variables, the
+// request every worker clones
+func (p *httpProtocol) Setup(ctx context.Context, config api.TestConfiguration) error {
+	p.config = config
+
+	client, err := p.runner.newHTTPClient(config)
+	if err != nil {
+		return fmt.Errorf("configure transport: %w", err)
+	}
+	p.client = client
+	p.do = p.runner.chain(client.Do)
+	if config.Mode == api.ModeCache {
+		p.cache = newValidatorCache()
+	}
+	if config.Backoff {
+		p.backoff = newHostBackoff(p.runner.clk())
+	}
+	if len(config.UserAgents) > 0 {
+		if p.agents, err = newUserAgents(config.UserAgents); err != nil {
+			return err
+		}
+	}
+	if config.Chaos != nil {
+		if p.chaos, err = newChaos(*config.Chaos, p.runner.clk()); err != nil {
+			return err
+		}
+	}
+	p.negotiate = negotiates(config)
+	if config.Mode == api.ModePreflight && config.Origin == "" {
+		return fmt.Errorf("preflight mode requires an origin")
+	}
+	switch config.CacheBust {
+	case "", api.CacheBustReuse:
+	case api.CacheBustUnique:
+		p.bustID = strconv.FormatInt(time.Now().UnixNano(), 36)
+	default:
+		return fmt.Errorf("invalid cache_bust %q (want %q or %q)", config.CacheBust, api.CacheBustUnique, api.CacheBustReuse)
+	}
+
+	if len(config.Endpoints) > 0 {
+		p.endpoints, err = newEndpointSet(config)
+		if err != nil {
+			return err
+		}
+	}
+	if len(config.Steps) > 0 {
+		p.endpoints, err = newStepSet(config)
+		if err != nil {
+			return err
+		}
+		p.scenario = true
+	}
+	if len(config.Steps) > 0 || len(config.VUSetup) > 0 {
+		if p.sessions, err = newSessions(p.runner, client, config); err != nil {
+			return err
+		}
+	}
+	if p.rows, err = newRowOverrides(config); err != nil {
+		return err
+	}
+	if len(config.Replay) > 0 {
+		base, err := url.Parse(config.URL)
+		if err != nil {
+			return fmt.Errorf("invalid url: %w", err)
+		}
+		p.replay = make([]api.ReplayRequest, len(config.Replay))
+		for i, rr := range config.Replay {
+			rr.URL = resolveEndpoint(base, config.URL, rr.URL)
+			p.replay[i] = rr
+		}
+	}
+
+	// Tests without variables send the same request every time, or the same
+	// request per endpoint; build them once. Replayed requests all differ.
+	switch {
+	case p.varCtx != nil || p.replay != nil:
+	case p.endpoints != nil:
+		for _, c := range p.endpoints.configs {
+			tmpl, err := buildRequest(context.Background(), c, c.URL, c.Body)
+			if err != nil {
+				return fmt.Errorf("build request for %s: %w", c.URL, err)
+			}
+			p.endpoints.tmpls = append(p.endpoints.tmpls, tmpl)
+		}
+	default:
+		p.tmpl, err = buildRequest(context.Background(), config, config.URL, config.Body)
+		if err != nil {
+			return fmt.Errorf("build request: %w", err)
+		}
+	}
+
+	if config.Script != "" {
+		vars := p.varCtx
+		if vars == nil {
+			// Scripts can still use the built-in variables
+			vars = &VariableContext{
+				Variables: make(map[string]*Variable),
+				Rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+			}
+		}
+		p.hooks, err = p.runner.newHooks(config.Script, config.Concurrency, func(name string, reqIdx int) (string, error) {
+			return p.runner.getVariableValue(name, vars, reqIdx)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.LuaAssert != "" {
+		p.lua, err = newLuaAssert(config.LuaAssert, config.Concurrency)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Teardown closes idle connections unless a suite or the caller owns the
+// transport
+func (p *httpProtocol) Teardown() error {
+	if p.client != nil && p.runner.suite == nil && p.runner.transport == nil {
+		p.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+// Execute sends a single request
+func (p *httpProtocol) Execute(ctx context.Context, reqIdx int) api.RequestResult {
+	config, tmpl := p.config, p.tmpl
+	endpoint := 0
+	if p.endpoints != nil {
+		if p.scenario {
+			endpoint = reqIdx % len(p.endpoints.configs)
+		} else {
+			endpoint = p.endpoints.pick()
+		}
+		config = p.endpoints.configs[endpoint]
+		if p.endpoints.tmpls != nil {
+			tmpl = p.endpoints.tmpls[endpoint]
+		}
+	}
+	if p.replay != nil {
+		rr := p.replay[reqIdx/max(config.ReplayUsers, 1)%len(p.replay)]
+		config.URL = rr.URL
+		if rr.Method != "" {
+			config.Method = rr.Method
+		}
+		if rr.Body != "" {
+			config.Body = rr.Body
+		}
+	}
+	if p.rows != nil {
+		p.rows.apply(&config, reqIdx)
+	}
+
+	// Simulated network latency is spent before the request and is not
+	// part of the measured response time
+	if ctx.Err() != nil || !sleepContext(ctx, p.runner.clk(), networkDelay(config.DelayMs, config.JitterMs)) {
+		return api.RequestResult{}
+	}
+
+	var req *http.Request
+	var err error
+	reqBody := config.Body
+
+	if tmpl != nil {
+		// Nothing varies per request: clone the prebuilt request
+		req = tmpl.Clone(ctx)
+		if tmpl.GetBody != nil {
+			req.Body, _ = tmpl.GetBody()
+		}
+	} else {
+		// Apply variables to URL, body and headers
+		reqURL := config.URL
+		reqURL, err = p.runner.processVariables(reqURL, p.varCtx, reqIdx)
+		if err == nil && hasBody(config.Method, reqBody) {
+			reqBody, err = p.runner.processVariables(reqBody, p.varCtx, reqIdx)
+		}
+		reqConfig := config
+		if err == nil {
+			reqConfig, err = p.expandConfig(config, reqIdx)
+		}
+		if err == nil {
+			req, err = buildRequest(ctx, reqConfig, reqURL, reqBody)
+		}
+		if err == nil && config.CacheBust == api.CacheBustReuse {
+			req.URL = p.reuseURL(config.URL, req.URL)
+		}
+	}
+	if err == nil && p.bustID != "" {
+		// Before the hooks, so request signing covers the parameter
+		if req.URL.RawQuery != "" {
+			req.URL.RawQuery += "&"
+		}
+		req.URL.RawQuery += "_bb=" + p.bustID + "-" + strconv.Itoa(reqIdx)
+	}
+
+	var agent string
+	if err == nil && p.agents != nil && req.Header.Get("User-Agent") == "" {
+		// Before the hooks, so they see the request as sent
+		var ua string
+		agent, ua = p.agents.pick()
+		req.Header.Set("User-Agent", ua)
+	}
+
+	if err == nil && p.hooks != nil {
+		err = p.hooks.beforeRequest(req, reqBody, reqIdx)
+	}
+
+	var cors *preflight
+	if err == nil && config.Mode == api.ModePreflight {
+		req, cors = newPreflight(req, config.Origin)
+	}
+
+	if err != nil {
+		return api.RequestResult{
+			Duration:  0,
+			Status:    0,
+			Error:     err,
+			Timestamp: p.runner.clk().Now(),
+			Endpoint:  endpoint,
+		}
+	}
+
+	var requestID string
+	if config.RequestIDHeader != "" && config.RequestIDHeader != api.NoRequestIDHeader {
+		requestID = uuid.New().String()
+		req.Header.Set(config.RequestIDHeader, requestID)
+	}
+
+	conditional := p.cache != nil && p.cache.apply(req)
+
+	var timing connTiming
+	if config.Mode == api.ModeConnect {
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), timing.trace()))
+	}
+	if len(p.runner.middleware) > 0 {
+		req = req.WithContext(context.WithValue(req.Context(), requestIndexKey{}, reqIdx))
+	}
+
+	do, vu := p.do, 0
+	if p.sessions != nil {
+		vu = p.varCtx.user(reqIdx)
+		do = p.sessions.do[vu]
+	}
+
+	// Like the network delay, time held back by backoff is not measured
+	var throttled time.Duration
+	if p.backoff != nil {
+		var ok bool
+		if throttled, ok = p.backoff.wait(ctx, req.URL.Host); !ok {
+			return api.RequestResult{}
+		}
+	}
+
+	var fault string
+	if p.chaos != nil {
+		if fault = p.chaos.pick(); fault != "" {
+			var release func()
+			var ok bool
+			if req, fault, release, ok = p.chaos.inject(ctx, req, fault); !ok {
+				return api.RequestResult{}
+			}
+			defer release()
+		}
+	}
+
+	resp, reqStart, retried, ok, err := p.send(ctx, do, req)
+	if !ok {
+		return api.RequestResult{}
+	}
+	if chaosErr := chaosError(fault); chaosErr != nil {
+		// Whatever came back, the request was broken on purpose
+		if err == nil {
+			resp.Body.Close()
+		}
+		err = chaosErr
+	}
+	var bodyBytes int64
+	if err == nil && (config.Bandwidth != "" || config.AcceptEncoding != "") {
+		// A throttled client is only slow if it actually consumes the body,
+		// and an encoding's cost includes transferring it
+		var copyErr error
+		if bodyBytes, copyErr = io.Copy(io.Discard, resp.Body); copyErr != nil {
+			err = copyErr
+			resp.Body.Close()
+		}
+	}
+	reqDuration := p.runner.since(reqStart)
+	connectDuration, tlsDuration := timing.durations()
+
+	result := api.RequestResult{
+		Duration:        reqDuration,
+		Timestamp:       reqStart,
+		RequestID:       requestID,
+		ConnectDuration: connectDuration,
+		TLSDuration:     tlsDuration,
+		Endpoint:        endpoint,
+		Conditional:     conditional,
+		Throttled:       throttled,
+		Retries:         retried.count,
+		RetryWait:       retried.wait,
+		Fault:           fault,
+		UserAgent:       agent,
+	}
+
+	if err != nil {
+		var urlErr *url.Error
+		if p.varCtx != nil && p.varCtx.Sensitive && errors.As(err, &urlErr) {
+			// The expanded URL may carry sensitive values; report the template
+			urlErr.URL = config.URL
+		}
+		result.Error = err
+	} else {
+		result.Status = resp.StatusCode
+		if p.backoff != nil {
+			result.RateLimited = p.backoff.observe(req.URL.Host, resp, p.runner.clk().Now())
+		}
+		if p.negotiate {
+			result.Representation = representation(resp)
+		}
+		if config.AcceptEncoding != "" {
+			result.BodyBytes = bodyBytes
+			result.ContentEncoding = resp.Header.Get("Content-Encoding")
+		}
+		if config.ResponseIDHeader != "" && resp.StatusCode >= 400 {
+			result.ServerRequestID = resp.Header.Get(config.ResponseIDHeader)
+		}
+		if p.cache != nil && resp.StatusCode == http.StatusOK {
+			p.cache.store(req, resp)
+		}
+		// Assertions run after the clock stops
+		if cors != nil {
+			result.Error = cors.check(resp)
+		}
+		if result.Error == nil && p.scenario {
+			result.Error = p.sessions.extract(resp, p.sessions.extractors[endpoint], vu, p.varCtx)
+		}
+		if result.Error == nil {
+			result.Error = p.assert(resp, req, reqDuration, reqIdx)
+		}
+		resp.Body.Close()
+	}
+
+	return result
+}
+
+// expandConfig returns config with variables applied to its auth token,
+// header values and query parameter values
+func (p *httpProtocol) expandConfig(config api.TestConfiguration, reqIdx int) (api.TestConfiguration, error) {
+	var err error
+	config.AuthToken, err = p.runner.processVariables(config.AuthToken, p.varCtx, reqIdx)
+	if err != nil {
+		return config, err
+	}
+	if config.Headers, err = p.expandValues(config.Headers, reqIdx); err != nil {
+		return config, err
+	}
+	config.QueryParams, err = p.expandValues(config.QueryParams, reqIdx)
+	return config, err
+}
+
+// reuseURL returns the URL first sent for a URL template, so that with
+// cache_bust "reuse" every request repeats it instead of varying
+func (p *httpProtocol) reuseURL(template string, u *url.URL) *url.URL {
+	first, _ := p.reused.LoadOrStore(template, u)
+	reused := *first.(*url.URL)
+	return &reused
+}
+
+// expandValues returns a copy of m with variables applied to its values
+func (p *httpProtocol) expandValues(m map[string]string, reqIdx int) (map[string]string, error) {
+	if len(m) == 0 {
+		return m, nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		var err error
+		if out[k], err = p.runner.processVariables(v, p.varCtx, reqIdx); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// assert runs the test's script and Lua assertions on a response
+func (p *httpProtocol) assert(resp *http.Response, req *http.Request, duration time.Duration, reqIdx int) error {
+	if (p.hooks == nil || !p.hooks.hasAfter) && p.lua == nil {
+		return nil
+	}
+	body, err := readHookBody(resp)
+	if err != nil {
+		return err
+	}
+	if p.hooks != nil {
+		if err := p.hooks.afterResponse(resp, body, req, duration, reqIdx); err != nil {
+			return err
+		}
+	}
+	if p.lua != nil {
+		return p.lua.check(resp, body, duration)
+	}
+	return nil
+}
+
+// hasBody reports whether a request with this method and configured body
+// carries one: POST, PUT and PATCH always do, GET and HEAD never, and any
+// other method (DELETE, OPTIONS, custom ones) when the test sets a body
+func hasBody(method, body string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead:
+		return false
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	}
+	return body != ""
+}
+
+// buildRequest creates the HTTP request for a test with the given URL and body,
+// setting every header that does not change between requests.
+func buildRequest(ctx context.Context, config api.TestConfiguration, reqURL, reqBody string) (*http.Request, error) {
+	var body io.Reader
+	if hasBody(config.Method, reqBody) {
+		if reqBody == "" {
+			reqBody = "{}"
+		}
+		body = strings.NewReader(reqBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, config.Method, reqURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(config.QueryParams) > 0 {
+		// Appended so the URL's own parameters keep their order
+		query := make(url.Values, len(config.QueryParams))
+		for k, v := range config.QueryParams {
+			query.Set(k, v)
+		}
+		if req.URL.RawQuery != "" {
+			req.URL.RawQuery += "&"
+		}
+		req.URL.RawQuery += query.Encode()
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if config.Origin != "" {
+		req.Header.Set("Origin", config.Origin)
+	}
+
+	if config.AcceptEncoding != "" {
+		// Set explicitly, the transport leaves compressed bodies as they are
+		req.Header.Set("Accept-Encoding", config.AcceptEncoding)
+	}
+
+	if config.AuthToken != "" {
+		req.Header.Set("Authorization", config.AuthToken)
+	}
+
+	for k, v := range config.Headers {
+		if http.CanonicalHeaderKey(k) == "Host" {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+
+	if config.HostHeader != "" {
+		req.Host = config.HostHeader
+	}
+
+	return req, nil
+}
+
+// connTiming records connection-establishment phases of a single request.
+// With happy eyeballs several dials race from their own goroutines, so
+// their start times are kept per address and the first to connect wins.
+type connTiming struct {
+	mu            sync.Mutex
+	connectStarts map[string]time.Time
+	tlsStart      time.Time
+	connect, tls  time.Duration
+}
+
+// trace returns an httptrace hook that fills in the timing fields
+func (t *connTiming) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		ConnectStart: func(network, addr string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.connectStarts == nil {
+				t.connectStarts = make(map[string]time.Time)
+			}
+			t.connectStarts[network+" "+addr] = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if start, ok := t.connectStarts[network+" "+addr]; ok && err == nil && t.connect == 0 {
+				t.connect = time.Since(start)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if err == nil {
+				t.tls = time.Since(t.tlsStart)
+			}
+		},
+	}
+}
+
+// durations returns the connect and TLS handshake times; a losing dial may
+// still be reporting when the request is done
+func (t *connTiming) durations() (connect, tls time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.connect, t.tls
+}
+
+// durationMs converts a duration to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// setupVariableContext initializes the variable context for the test
+func (r *Runner) setupVariableContext(config api.TestConfiguration) (*VariableContext, error) {
+	// Create a new random source with a seed based on current time
+	source := rand.NewSource(time.Now().UnixNano())
+	rnd := rand.New(source)
+
+	ctx := &VariableContext{
+		Variables: make(map[string]*Variable),
+		Rand:      rnd,
+		Mutex:     sync.Mutex{},
+	}
+
+	if len(config.Replay) > 0 {
+		ctx.Users = config.ReplayUsers
+	}
+	if len(config.Steps) > 0 || len(config.VUSetup) > 0 {
+		// Every worker is a virtual user with its own session; a plain test
+		// has one-request iterations
+		ctx.Users = config.Concurrency
+		ctx.steps = max(len(config.Steps), 1)
+	}
+	if each := config.Each; each != nil && len(each.Rows) > 0 {
+		// One each variable per column
+		for i, column := range each.Columns {
+			values := make([]string, len(each.Rows))
+			for j, row := range each.Rows {
+				if i < len(row) {
+					values[j] = row[i]
+				}
+			}
+			name := strings.TrimSpace(column)
+			ctx.Variables[name] = &Variable{Name: name, Strategy: "each", Values: values}
+		}
+	} else if each != nil {
+		name := each.Variable
+		if name == "" {
+			name = "item"
+		}
+		ctx.Variables[name] = &Variable{Name: name, Strategy: "each", Values: each.Values}
+	}
+	if config.Variables == "" {
+		return ctx, nil
+	}
+
+	// Parse variables JSON
+	var variables []*Variable
+	if err := json.Unmarshal([]byte(config.Variables), &variables); err != nil {
+		r.logInfo("Error parsing variables: %v", err)
+		return ctx, nil
+	}
+
+	// Initialize variables
+	for _, v := range variables {
+		// Set defaults if needed
+		if v.Strategy == "sequential" && v.Increment <= 0 {
+			v.Increment = 1
+		}
+		if v.Strategy == "sequential" {
+			v.current = v.StartValue
+			if v.Format != "" && !validSequentialFormat(v.Format) {
+				return nil, fmt.Errorf("variable %s: format %q must contain exactly one integer verb, e.g. %%05d", v.Name, v.Format)
+			}
+		}
+		if v.Strategy == "idempotency_key" {
+			v.Reuse = max(v.Reuse, 1)
+			v.seed = uuid.New()
+		}
+		if v.Strategy == "timestamp" && v.Offset != "" {
+			offset, err := time.ParseDuration(v.Offset)
+			if err != nil {
+				return nil, fmt.Errorf("variable %s: invalid offset: %w", v.Name, err)
+			}
+			v.offset = offset
+		}
+		if v.Sensitive {
+			ctx.Sensitive = true
+		}
+		ctx.Variables[v.Name] = v
+	}
+
+	return ctx, nil
+}
+
+// printfVerbRe matches a printf directive, or %% as an escaped percent sign
+var printfVerbRe = regexp.MustCompile(`%%|%[-+# 0]*[0-9]*(\.[0-9]*)?(.|$)`)
+
+// validSequentialFormat reports whether format formats one integer, so
+// requests never carry %!(EXTRA ...) or %!s(int=...)
+func validSequentialFormat(format string) bool {
+	verbs := 0
+	for _, m := range printfVerbRe.FindAllString(format, -1) {
+		if m == "%%" {
+			continue
+		}
+		if verbs++; !strings.ContainsAny(m[len(m)-1:], "bdoOxXv") {
+			return false
+		}
+	}
+	return verbs == 1
+}
+
+// redactor returns the redactor for a test: the runner's secrets, the auth
+// token (with and without its scheme), the integrated auth password and
+// static sensitive variable values
+func (r *Runner) redactor(config api.TestConfiguration, varCtx *VariableContext) *redact.Redactor {
+	secrets := append([]string{}, r.Secrets...)
+	if config.Prometheus != nil {
+		secrets = append(secrets, config.Prometheus.BearerToken)
+	}
+	if config.IntegratedAuth != nil {
+		secrets = append(secrets, config.IntegratedAuth.Password)
+	}
+	if config.ClickHouse != nil && config.ClickHouse.PasswordEnv != "" {
+		secrets = append(secrets, os.Getenv(config.ClickHouse.PasswordEnv))
+	}
+	if config.Kafka != nil && config.Kafka.PasswordEnv != "" {
+		secrets = append(secrets, os.Getenv(config.Kafka.PasswordEnv))
+	}
+	if config.AuthToken != "" {
+		secrets = append(secrets, config.AuthToken)
+		if _, credentials, ok := strings.Cut(config.AuthToken, " "); ok {
+			secrets = append(secrets, strings.TrimSpace(credentials))
+		}
+	}
+	if varCtx != nil {
+		for _, v := range varCtx.Variables {
+			if v.Sensitive && v.Strategy == "static" {
+				secrets = append(secrets, v.Value)
+			}
+			if v.Sensitive && v.Strategy == "choice" {
+				secrets = append(secrets, v.Values...)
+			}
+		}
+	}
+	return redact.New(secrets, append(redact.DefaultParams, r.RedactParams...))
+}
+
+// placeholderRe matches {{name}} variable placeholders
+var placeholderRe = regexp.MustCompile(`\{\{([^}]+)\}\}`)
+
+// processVariables replaces variables in a string with their values
+func (r *Runner) processVariables(input string, ctx *VariableContext, requestIndex int) (string, error) {
+	if input == "" || ctx == nil || !strings.Contains(input, "{{") {
+		return input, nil
+	}
+	return r.expand(input, ctx, requestIndex, nil)
+}
+
+// maxVariableDepth bounds how deeply variables may reference each other
+const maxVariableDepth = 16
+
+// errVariableNesting marks variables that reference each other in a cycle
+// or too deeply; the request fails rather than going out with a literal
+// placeholder
+var errVariableNesting = errors.New("invalid variable nesting")
+
+// expand replaces the placeholders in input. stack lists the variables
+// whose values are being expanded, outermost first, to detect cycles.
+func (r *Runner) expand(input string, ctx *VariableContext, requestIndex int, stack []string) (string, error) {
+	var nestErr error
+	out := placeholderRe.ReplaceAllStringFunc(input, func(match string) string {
+		// Extract variable name from {{name}}
+		varName := match[2 : len(match)-2]
+		varValue, err := r.resolveVariable(varName, ctx, requestIndex, stack)
+		if errors.Is(err, errVariableNesting) {
+			if nestErr == nil {
+				nestErr = err
+			}
+			return match
+		}
+		if err != nil {
+			r.logInfo("Variable error: %v", err)
+			return match // Return original if error
+		}
+		return varValue
+	})
+	return out, nestErr
+}
+
+// getVariableValue generates a value for a variable, expanding any variables
+// the value itself references
+func (r *Runner) getVariableValue(name string, ctx *VariableContext, requestIndex int) (string, error) {
+	return r.resolveVariable(name, ctx, requestIndex, nil)
+}
+
+func (r *Runner) resolveVariable(name string, ctx *VariableContext, requestIndex int, stack []string) (string, error) {
+	for _, outer := range stack {
+		if outer == name {
+			return "", fmt.Errorf("%w: cycle %s -> %s", errVariableNesting, strings.Join(stack, " -> "), name)
+		}
+	}
+	if len(stack) >= maxVariableDepth {
+		return "", fmt.Errorf("%w: more than %d deep at %s", errVariableNesting, maxVariableDepth, name)
+	}
+
+	resolve := func() (string, error) {
+		value, err := r.generateValue(name, ctx, requestIndex)
+		if err != nil || !strings.Contains(value, "{{") {
+			return value, err
+		}
+		return r.expand(value, ctx, requestIndex, append(stack, name))
+	}
+	if ctx.steps > 0 {
+		// A value extracted by an earlier step of this VU wins
+		if value, ok := ctx.storedValue(name, ctx.user(requestIndex)); ok {
+			return value, nil
+		}
+	}
+	if v := ctx.Variables[name]; v != nil && v.Scope == "user" && ctx.Users > 0 {
+		return ctx.userValue(name, ctx.user(requestIndex), resolve)
+	}
+	return resolve()
+}
+
+// user returns the virtual user a request belongs to
+func (ctx *VariableContext) user(requestIndex int) int {
+	if ctx.steps > 0 {
+		if vu, ok := ctx.iterationVUs.Load(requestIndex / ctx.steps); ok {
+			return vu.(int)
+		}
+		return 0
+	}
+	if ctx.Users <= 0 {
+		return 0
+	}
+	return requestIndex % ctx.Users
+}
+
+// generateValue generates a value for a variable based on its definition
+func (r *Runner) generateValue(name string, ctx *VariableContext, requestIndex int) (string, error) {
+	// Special built-in variables
+	if name == "$index" {
+		return strconv.Itoa(requestIndex), nil
+	} else if name == "$random" {
+		return strconv.Itoa(ctx.intn(10000)), nil
+	} else if name == "$user" {
+		return strconv.Itoa(ctx.user(requestIndex)), nil
+	}
+
+	// Look up the variable definition
+	v, exists := ctx.Variables[name]
+	if !exists {
+		return "", fmt.Errorf("undefined variable: %s", name)
+	}
+
+	// Process based on strategy
+	switch v.Strategy {
+	case "static":
+		return v.Value, nil
+
+	case "sequential":
+		// Safely get and increment the value
+		ctx.Mutex.Lock()
+		current := v.current
+
+		// Increment for next use
+		v.current += v.Increment
+
+		// Handle wrapping around if we exceed end value
+		if v.EndValue > v.StartValue && v.current > v.EndValue {
+			v.current = v.StartValue
+		}
+		ctx.Mutex.Unlock()
+
+		if v.Format != "" {
+			return fmt.Sprintf(v.Format, current), nil
+		}
+		return strconv.Itoa(current), nil
+
+	case "random":
+		if v.Type == "integer" {
+			return strconv.Itoa(ctx.intn(v.MaxValue-v.MinValue+1) + v.MinValue), nil
+		} else if v.Type == "float" {
+			val := float64(v.MinValue) + ctx.float64()*float64(v.MaxValue-v.MinValue)
+			return fmt.Sprintf("%.2f", val), nil
+		} else {
+			// For non-numeric, generate a random string
+			return ctx.randomString(v.Length, v.Charset), nil
+		}
+
+	case "choice":
+		if len(v.Values) == 0 {
+			return "", fmt.Errorf("variable %s has no values", v.Name)
+		}
+		if v.Selection == "random" {
+			return v.Values[ctx.intn(len(v.Values))], nil
+		}
+		ctx.Mutex.Lock()
+		i := v.current % len(v.Values)
+		v.current++
+		ctx.Mutex.Unlock()
+		return v.Values[i], nil
+
+	case "uuid":
+		return uuid.New().String(), nil
+
+	case "uuidv7":
+		id, err := uuid.NewV7()
+		if err != nil {
+			return "", err
+		}
+		return id.String(), nil
+
+	case "ulid":
+		return newULID(r.clk().Now())
+
+	case "idempotency_key":
+		// Derived from the request index, so every reference in a request
+		// and every request of a reuse group gets the same key
+		group := strconv.Itoa(requestIndex / v.Reuse)
+		return uuid.NewSHA1(v.seed, []byte(group)).String(), nil
+
+	case "each":
+		// Set up from the test's each list: request i gets value i
+		return v.Values[requestIndex%len(v.Values)], nil
+
+	case "timestamp":
+		return formatTimestamp(r.clk().Now().Add(v.offset), v.Format), nil
+
+	case "template":
+		// Placeholders in the template, including other variables, are
+		// expanded by resolveVariable
+		return v.Template, nil
+
+	default:
+		return "", fmt.Errorf("unsupported variable strategy: %s", v.Strategy)
+	}
+}
+
+// formatTimestamp renders t as epoch seconds or milliseconds, RFC3339, or
+// with a custom Go time layout
+func formatTimestamp(t time.Time, format string) string {
+	switch strings.ToLower(format) {
+	case "", "rfc3339":
+		return t.Format(time.RFC3339)
+	case "rfc3339nano":
+		return t.Format(time.RFC3339Nano)
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixmilli":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return t.Format(format)
+}
+
+// logInfo logs information if verbose mode is enabled
+func (r *Runner) logInfo(format string, v ...interface{}) {
+	r.Logger.Printf(format, v...)
+}
+
+// logDebug logs debug information if verbose mode is enabled
+func (r *Runner) logDebug(format string, v ...interface{}) {
+	if r.Verbose {
+		r.Logger.Printf("[DEBUG] "+format, v...)
+	}
+}
@@ -0,0 +1,152 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// Think time distributions
+const (
+	thinkFixed       = "fixed"
+	thinkUniform     = "uniform"
+	thinkNormal      = "normal"
+	thinkExponential = "exponential"
+)
+
+// validateSteps checks a scenario before it runs
+func validateSteps(config api.TestConfiguration) error {
+	if len(config.Endpoints) > 0 || len(config.Replay) > 0 || config.Each != nil {
+		return fmt.Errorf("steps can't be combined with endpoints, replay or each")
+	}
+	for i, step := range config.Steps {
+		t := step.ThinkTime
+		if t == nil {
+			continue
+		}
+		switch t.Distribution {
+		case "", thinkFixed, thinkNormal, thinkExponential:
+		case thinkUniform:
+			if t.MaxMs < t.MinMs {
+				return fmt.Errorf("step %d: think_time max_ms is below min_ms", i+1)
+			}
+		default:
+			return fmt.Errorf("step %d: unknown think_time distribution %q", i+1, t.Distribution)
+		}
+	}
+	return nil
+}
+
+// newStepSet resolves a scenario's steps against the test URL. Unlike
+// endpoints, steps are chosen by request index rather than at random.
+func newStepSet(config api.TestConfiguration) (*endpointSet, error) {
+	configs, err := stepConfigs(config, config.Steps)
+	if err != nil {
+		return nil, err
+	}
+	return &endpointSet{configs: configs}, nil
+}
+
+// stepConfigs returns the test's configuration for each step
+func stepConfigs(config api.TestConfiguration, steps []api.Step) ([]api.TestConfiguration, error) {
+	base, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+
+	var configs []api.TestConfiguration
+	for _, step := range steps {
+		c := config
+		c.Steps, c.VUSetup = nil, nil
+		c.URL = resolveEndpoint(base, config.URL, step.URL)
+		if step.Method != "" {
+			c.Method = step.Method
+		}
+		if step.Body != "" {
+			c.Body = step.Body
+		}
+		configs = append(configs, c)
+	}
+	return configs, nil
+}
+
+// runIteration sends one iteration of a scenario: its steps in order, each
+// followed by its think time. Request indexes run iteration*len(steps)+step.
+// The last request's result carries the iteration's duration; iterations cut
+// short by the end of the test report none. vu is the worker running the
+// iteration, whose session the steps share.
+func (r *Runner) runIteration(ctx context.Context, proto Protocol, config api.TestConfiguration, varCtx *VariableContext, vu, iteration int, results chan<- api.RequestResult) {
+	varCtx.iterationVUs.Store(iteration, vu)
+	defer varCtx.iterationVUs.Delete(iteration)
+
+	start := r.clk().Now()
+	failed := false
+	for i, step := range config.Steps {
+		res := proto.Execute(ctx, iteration*len(config.Steps)+i)
+		if res.Timestamp.IsZero() {
+			return // not attempted before the test ended
+		}
+		failed = failed || res.Error != nil || res.Status < 200 || res.Status >= 400
+
+		pause := thinkTime(step.ThinkTime)
+		last := i == len(config.Steps)-1
+		if last {
+			// Known before the pause, so the result needn't wait for it
+			res.IterationDuration = r.since(start) + pause
+			res.IterationFailed = failed
+		}
+		results <- res
+		if !sleepContext(ctx, r.clk(), pause) && !last {
+			return
+		}
+	}
+}
+
+// thinkTime draws a pause from a step's think time
+func thinkTime(t *api.ThinkTime) time.Duration {
+	if t == nil {
+		return 0
+	}
+	var ms float64
+	switch t.Distribution {
+	case "", thinkFixed:
+		ms = float64(t.MeanMs)
+	case thinkUniform:
+		ms = float64(t.MinMs) + rand.Float64()*float64(t.MaxMs-t.MinMs)
+	case thinkNormal:
+		ms = float64(t.MeanMs) + rand.NormFloat64()*float64(t.StdDevMs)
+	case thinkExponential:
+		ms = rand.ExpFloat64() * float64(t.MeanMs)
+	}
+	ms = max(ms, float64(t.MinMs))
+	if t.MaxMs > 0 {
+		ms = min(ms, float64(t.MaxMs))
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// iterationBudget bounds how long one iteration may take: a second per
+// step plus its longest likely think time
+func iterationBudget(steps []api.Step) time.Duration {
+	var d time.Duration
+	for _, step := range steps {
+		d += time.Second
+		if t := step.ThinkTime; t != nil {
+			ms := max(t.MaxMs, t.MeanMs)
+			if t.MaxMs == 0 {
+				switch t.Distribution {
+				case thinkNormal:
+					ms = t.MeanMs + 4*t.StdDevMs
+				case thinkExponential:
+					ms = 5 * t.MeanMs
+				}
+			}
+			d += time.Duration(ms) * time.Millisecond
+		}
+	}
+	return d
+}
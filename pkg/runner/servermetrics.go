@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"context"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/internal/prometheus"
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// defaultPromStep is the query resolution when a test doesn't set one
+const defaultPromStep = 5 * time.Second
+
+// collectServerMetrics runs the test's Prometheus queries over [start, end].
+// A failing query is recorded on its metric rather than failing the test.
+func (r *Runner) collectServerMetrics(cfg *api.PrometheusConfig, start, end time.Time) []api.ServerMetric {
+	step := defaultPromStep
+	if cfg.StepSecs > 0 {
+		step = time.Duration(cfg.StepSecs) * time.Second
+	}
+	// Widen the window by a step so short tests still cover a scrape
+	start, end = start.Add(-step), end.Add(step)
+
+	client := prometheus.NewClient(cfg.Endpoint, cfg.BearerToken)
+	var metrics []api.ServerMetric
+	for _, q := range cfg.Queries {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		series, err := client.QueryRange(ctx, q.Query, start, end, step)
+		cancel()
+		if err != nil {
+			r.logInfo("Prometheus query %q failed: %v", q.Name, err)
+			metrics = append(metrics, api.ServerMetric{Name: q.Name, Query: q.Query, Error: err.Error()})
+			continue
+		}
+
+		for _, s := range series {
+			metrics = append(metrics, summarizeSeries(q.Name+prometheus.LabelString(s.Labels), q.Query, s.Points))
+		}
+	}
+	return metrics
+}
+
+// summarizeSeries converts a series into a ServerMetric with min/avg/max
+func summarizeSeries(name, query string, points []prometheus.Point) api.ServerMetric {
+	m := api.ServerMetric{Name: name, Query: query}
+	var sum float64
+	for i, p := range points {
+		if i == 0 || p.Value < m.Min {
+			m.Min = p.Value
+		}
+		if i == 0 || p.Value > m.Max {
+			m.Max = p.Value
+		}
+		sum += p.Value
+		m.Points = append(m.Points, api.MetricPoint{
+			Timestamp: float64(p.Time.UnixMilli()) / 1000,
+			Value:     p.Value,
+		})
+	}
+	if len(points) > 0 {
+		m.Avg = sum / float64(len(points))
+	}
+	return m
+}
@@ -0,0 +1,275 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// sessions isolates the virtual users of a scenario or of a test with VU
+// setup. Each VU sends through its own client and so keeps its own cookies;
+// values its steps extract from responses are stored under the VU in the
+// VariableContext.
+type sessions struct {
+	do         []DoFunc      // per VU
+	extractors [][]extractor // per step
+
+	setup           []api.TestConfiguration // per setup step
+	setupExtractors [][]extractor
+}
+
+// extractor takes one named value from a response
+type extractor struct {
+	name   string
+	source string // "json", "header" or "regex"
+	path   []string
+	header string
+	re     *regexp.Regexp
+}
+
+// newSessions builds a client per VU sharing client's transport, and
+// parses the steps' extract rules
+func newSessions(r *Runner, client *http.Client, config api.TestConfiguration) (*sessions, error) {
+	s := &sessions{do: make([]DoFunc, config.Concurrency)}
+	for i := range s.do {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, err
+		}
+		c := *client
+		c.Jar = jar
+		s.do[i] = r.chain(c.Do)
+	}
+
+	var err error
+	if s.extractors, err = stepExtractors(config.Steps, "step"); err != nil {
+		return nil, err
+	}
+	if s.setupExtractors, err = stepExtractors(config.VUSetup, "vu_setup step"); err != nil {
+		return nil, err
+	}
+	if s.setup, err = stepConfigs(config, config.VUSetup); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// stepExtractors parses the extract rules of each step
+func stepExtractors(steps []api.Step, what string) ([][]extractor, error) {
+	var all [][]extractor
+	for i, step := range steps {
+		var xs []extractor
+		for name, rule := range step.Extract {
+			x, err := newExtractor(name, rule)
+			if err != nil {
+				return nil, fmt.Errorf("%s %d: %w", what, i+1, err)
+			}
+			xs = append(xs, x)
+		}
+		all = append(all, xs)
+	}
+	return all, nil
+}
+
+func newExtractor(name, rule string) (extractor, error) {
+	source, arg, ok := strings.Cut(rule, ":")
+	x := extractor{name: name, source: source}
+	switch {
+	case !ok || arg == "":
+		return x, fmt.Errorf("extract %s: want json:path, header:name or regex:pattern, got %q", name, rule)
+	case source == "json":
+		x.path = strings.Split(arg, ".")
+	case source == "header":
+		x.header = arg
+	case source == "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return x, fmt.Errorf("extract %s: %w", name, err)
+		}
+		x.re = re
+	default:
+		return x, fmt.Errorf("extract %s: unknown source %q", name, source)
+	}
+	return x, nil
+}
+
+// extract stores the values a step takes from its response for a VU. The
+// body is replaced so assertions can still read it.
+func (s *sessions) extract(resp *http.Response, xs []extractor, vu int, ctx *VariableContext) error {
+	if len(xs) == 0 {
+		return nil
+	}
+	body, err := readHookBody(resp)
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	for _, x := range xs {
+		value, ok := x.value(resp, body)
+		if !ok {
+			return fmt.Errorf("extract %s: not found in response", x.name)
+		}
+		ctx.setUserValue(x.name, vu, value)
+	}
+	return nil
+}
+
+// value finds the extractor's value in a response
+func (x extractor) value(resp *http.Response, body []byte) (string, bool) {
+	switch x.source {
+	case "header":
+		v := resp.Header.Get(x.header)
+		return v, v != ""
+	case "regex":
+		m := x.re.FindSubmatch(body)
+		if m == nil {
+			return "", false
+		}
+		if len(m) > 1 {
+			return string(m[1]), true
+		}
+		return string(m[0]), true
+	}
+
+	var v any
+	if json.Unmarshal(body, &v) != nil {
+		return "", false
+	}
+	for _, key := range x.path {
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", false
+			}
+			v = node[i]
+		default:
+			return "", false
+		}
+	}
+	switch v := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	}
+	raw, _ := json.Marshal(v)
+	return string(raw), true
+}
+
+// vuSetupper is implemented by protocols that can run a test's VU setup
+type vuSetupper interface {
+	setupVU(ctx context.Context, vu int) error
+}
+
+// setupVU sends a VU's setup steps through its session. Setup requests
+// belong to no iteration; they resolve their VU through iteration -1-vu, so
+// their request indexes are negative. Hooks and assertions don't run for
+// them: a setup step fails on a transport error, a status of 400 or more,
+// or a value it can't extract.
+func (p *httpProtocol) setupVU(ctx context.Context, vu int) error {
+	iteration := -1 - vu
+	p.varCtx.iterationVUs.Store(iteration, vu)
+	defer p.varCtx.iterationVUs.Delete(iteration)
+	reqIdx := iteration * p.varCtx.steps
+
+	for i, config := range p.sessions.setup {
+		step := p.config.VUSetup[i]
+		name := step.Name
+		if name == "" {
+			name = fmt.Sprintf("step %d", i+1)
+		}
+		if err := p.setupStep(ctx, config, vu, reqIdx, p.sessions.setupExtractors[i]); err != nil {
+			return fmt.Errorf("vu setup %s: %w", name, err)
+		}
+		if !sleepContext(ctx, p.runner.clk(), thinkTime(step.ThinkTime)) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// setupStep sends one setup request
+func (p *httpProtocol) setupStep(ctx context.Context, config api.TestConfiguration, vu, reqIdx int, xs []extractor) error {
+	config, err := p.expandConfig(config, reqIdx)
+	if err != nil {
+		return err
+	}
+	reqURL, err := p.runner.processVariables(config.URL, p.varCtx, reqIdx)
+	if err != nil {
+		return err
+	}
+	reqBody, err := p.runner.processVariables(config.Body, p.varCtx, reqIdx)
+	if err != nil {
+		return err
+	}
+	req, err := buildRequest(ctx, config, reqURL, reqBody)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.sessions.do[vu](req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return p.sessions.extract(resp, xs, vu, p.varCtx)
+}
+
+// setupTally collects the outcome of the VUs' setup
+type setupTally struct {
+	mu       sync.Mutex
+	stats    api.VUSetupStats
+	duration *histogram
+	first    error
+}
+
+func newSetupTally() *setupTally {
+	return &setupTally{duration: newHistogram()}
+}
+
+func (t *setupTally) add(d time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.VUs++
+	if err == nil {
+		t.duration.Record(d)
+		return
+	}
+	t.stats.Failed++
+	if t.stats.Errors == nil {
+		t.stats.Errors = make(map[string]int)
+	}
+	t.stats.Errors[err.Error()]++
+	if t.first == nil {
+		t.first = err
+	}
+}
+
+// finish returns the setup's statistics; Duration covers successful setups
+func (t *setupTally) finish() *api.VUSetupStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := t.stats
+	stats.Duration = t.duration.Stats()
+	return &stats
+}
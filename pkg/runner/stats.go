@@ -0,0 +1,144 @@
+package runner
+
+import (
+	"math"
+	"math/bits"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// subBuckets is the number of linear buckets per power of two; 128 gives
+// under 1% relative error on reported percentiles.
+const subBuckets = 128
+
+// histogram is a fixed-size log-linear latency histogram with microsecond
+// resolution. Memory use is constant regardless of how many values are recorded.
+type histogram struct {
+	counts   [64 * subBuckets]uint64
+	count    uint64
+	sumUs    float64
+	min, max int64
+}
+
+func newHistogram() *histogram {
+	return &histogram{min: math.MaxInt64}
+}
+
+// bucketIndex maps a microsecond value to its bucket
+func bucketIndex(us int64) int {
+	if us < subBuckets {
+		return int(us)
+	}
+	shift := bits.Len64(uint64(us)) - 8 // keep 7 significant bits below the leading one
+	return (shift+1)*subBuckets + int(us>>uint(shift)) - subBuckets
+}
+
+// bucketValue returns the midpoint of a bucket in microseconds
+func bucketValue(idx int) float64 {
+	if idx < subBuckets {
+		return float64(idx)
+	}
+	shift := idx/subBuckets - 1
+	lower := int64(idx%subBuckets+subBuckets) << uint(shift)
+	return float64(lower) + float64(int64(1)<<uint(shift))/2
+}
+
+// Record adds a duration to the histogram
+func (h *histogram) Record(d time.Duration) {
+	us := d.Microseconds()
+	if us < 0 {
+		us = 0
+	}
+	h.counts[bucketIndex(us)]++
+	h.count++
+	h.sumUs += float64(us)
+	if us < h.min {
+		h.min = us
+	}
+	if us > h.max {
+		h.max = us
+	}
+}
+
+// Count returns the number of recorded values
+func (h *histogram) Count() int {
+	return int(h.count)
+}
+
+// Mean returns the exact mean in milliseconds
+func (h *histogram) Mean() float64 {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sumUs / float64(h.count) / 1000
+}
+
+// Percentile returns the nearest-rank percentile p (0-100) in milliseconds
+func (h *histogram) Percentile(p float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(p / 100 * float64(h.count)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			// Never report beyond the exact extremes
+			v := math.Min(math.Max(bucketValue(i), float64(h.min)), float64(h.max))
+			return v / 1000
+		}
+	}
+	return float64(h.max) / 1000
+}
+
+// Stats summarises the histogram, or returns nil when it is empty
+func (h *histogram) Stats() *api.LatencyStats {
+	if h.count == 0 {
+		return nil
+	}
+	return &api.LatencyStats{
+		Count: h.Count(),
+		Avg:   h.Mean(),
+		Min:   float64(h.min) / 1000,
+		Max:   float64(h.max) / 1000,
+		P50:   h.Percentile(50),
+		P90:   h.Percentile(90),
+		P95:   h.Percentile(95),
+		P99:   h.Percentile(99),
+	}
+}
+
+// OutlierStats computes the mean with values above the given percentile
+// excluded (trimmed) and clamped to it (winsorized), plus how many values lay
+// above it. All results except the count are in milliseconds.
+func (h *histogram) OutlierStats(cutoffPct float64) (trimmed, winsorized, cutoff float64, outliers int) {
+	if h.count == 0 || cutoffPct <= 0 || cutoffPct >= 100 {
+		return 0, 0, 0, 0
+	}
+	cutoff = h.Percentile(cutoffPct)
+	cutoffIdx := bucketIndex(int64(cutoff * 1000))
+
+	var keptSum, kept float64
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		if i > cutoffIdx {
+			outliers += int(c)
+			continue
+		}
+		keptSum += bucketValue(i) * float64(c)
+		kept += float64(c)
+	}
+
+	if kept > 0 {
+		trimmed = keptSum / kept / 1000
+	}
+	winsorized = (keptSum/1000 + cutoff*float64(outliers)) / float64(h.count)
+	return trimmed, winsorized, cutoff, outliers
+}
@@ -0,0 +1,136 @@
+package runner
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// telemetryInterval is how often the generator samples its own resource use
+const telemetryInterval = time.Second
+
+// saturatedCPUPercent is the generator CPU use above which results are
+// likely bounded by the client machine rather than the target
+const saturatedCPUPercent = 90
+
+// snapshot is a reading of the counters used to compute resource use
+type snapshot struct {
+	at         time.Time
+	process    time.Duration // CPU time used by this process
+	sysBusy    uint64        // machine-wide busy jiffies, when known
+	sysTotal   uint64        // machine-wide total jiffies, when known
+	netRx      uint64        // bytes received on all interfaces, when known
+	netTx      uint64        // bytes sent on all interfaces, when known
+	hasProcess bool
+	hasSystem  bool
+	hasNetwork bool
+}
+
+func takeSnapshot() snapshot {
+	s := snapshot{at: time.Now()}
+	s.process, s.hasProcess = processCPUTime()
+	s.sysBusy, s.sysTotal, s.hasSystem = systemCPUJiffies()
+	s.netRx, s.netTx, s.hasNetwork = networkBytes()
+	return s
+}
+
+// telemetry samples the generator's CPU, memory and network use while a
+// test runs
+type telemetry struct {
+	stop chan struct{}
+	done sync.WaitGroup
+
+	stats api.GeneratorStats
+	first snapshot
+	last  snapshot
+
+	// Averages are weighted by interval length, since the last interval
+	// ends whenever the test does
+	cpuSum, sysSum   float64
+	cpuSecs, sysSecs float64
+}
+
+// startTelemetry begins sampling until stop is called
+func startTelemetry() *telemetry {
+	t := &telemetry{stop: make(chan struct{})}
+	t.first = takeSnapshot()
+	t.last = t.first
+
+	t.done.Add(1)
+	go func() {
+		defer t.done.Done()
+		ticker := time.NewTicker(telemetryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.sample()
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+	return t
+}
+
+// sample records one interval's utilisation
+func (t *telemetry) sample() {
+	now := takeSnapshot()
+	wall := now.at.Sub(t.last.at)
+	if wall <= 0 {
+		return
+	}
+
+	// A short interval's CPU use is mostly noise, so it only sets the peak
+	// when the run had no full one
+	full := wall >= telemetryInterval/2 || t.stats.Samples == 0
+	if now.hasProcess && t.last.hasProcess {
+		pct := float64(now.process-t.last.process) / float64(wall) / float64(runtime.NumCPU()) * 100
+		t.cpuSum += pct * wall.Seconds()
+		t.cpuSecs += wall.Seconds()
+		if full && pct > t.stats.PeakCPUPercent {
+			t.stats.PeakCPUPercent = pct
+		}
+	}
+	if now.hasSystem && t.last.hasSystem && now.sysTotal > t.last.sysTotal {
+		pct := float64(now.sysBusy-t.last.sysBusy) / float64(now.sysTotal-t.last.sysTotal) * 100
+		t.sysSum += pct * wall.Seconds()
+		t.sysSecs += wall.Seconds()
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	if mb := float64(mem.Sys) / (1 << 20); mb > t.stats.PeakMemoryMB {
+		t.stats.PeakMemoryMB = mb
+	}
+	if g := runtime.NumGoroutine(); g > t.stats.PeakGoroutines {
+		t.stats.PeakGoroutines = g
+	}
+
+	t.stats.Samples++
+	t.last = now
+}
+
+// finish stops sampling and returns the averages and peaks over the run
+func (t *telemetry) finish() *api.GeneratorStats {
+	close(t.stop)
+	t.done.Wait()
+	t.sample()
+
+	if t.cpuSecs > 0 {
+		t.stats.CPUPercent = t.cpuSum / t.cpuSecs
+	}
+	if t.sysSecs > 0 {
+		t.stats.SystemCPUPercent = t.sysSum / t.sysSecs
+	}
+	if t.first.hasNetwork && t.last.hasNetwork {
+		if secs := t.last.at.Sub(t.first.at).Seconds(); secs > 0 {
+			t.stats.NetRxMbps = float64(t.last.netRx-t.first.netRx) * 8 / secs / 1e6
+			t.stats.NetTxMbps = float64(t.last.netTx-t.first.netTx) * 8 / secs / 1e6
+		}
+	}
+	t.stats.Saturated = t.stats.PeakCPUPercent >= saturatedCPUPercent
+	return &t.stats
+}
@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// systemCPUJiffies reads the machine-wide busy and total CPU time from /proc/stat
+func systemCPUJiffies() (busy, total uint64, ok bool) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return 0, 0, false
+	}
+	fields := strings.Fields(scanner.Text())
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, 0, false
+	}
+	for i, field := range fields[1:] {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		total += v
+		// idle and iowait are the 4th and 5th counters
+		if i != 3 && i != 4 {
+			busy += v
+		}
+	}
+	return busy, total, true
+}
+
+// networkBytes sums received and sent bytes over all non-loopback interfaces
+func networkBytes() (rx, tx uint64, ok bool) {
+	f, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, counters, found := strings.Cut(scanner.Text(), ":")
+		if !found || strings.TrimSpace(name) == "lo" {
+			continue
+		}
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			continue
+		}
+		r, errR := strconv.ParseUint(fields[0], 10, 64)
+		t, errT := strconv.ParseUint(fields[8], 10, 64)
+		if errR != nil || errT != nil {
+			continue
+		}
+		rx += r
+		tx += t
+	}
+	return rx, tx, true
+}
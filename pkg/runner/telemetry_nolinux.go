@@ -0,0 +1,13 @@
+//go:build !linux
+
+package runner
+
+// systemCPUJiffies is only available on Linux
+func systemCPUJiffies() (busy, total uint64, ok bool) {
+	return 0, 0, false
+}
+
+// networkBytes is only available on Linux
+func networkBytes() (rx, tx uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,155 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bandwidthPresets maps named client profiles to kilobits per second
+var bandwidthPresets = map[string]int64{
+	"modem": 56,
+	"2g":    250,
+	"3g":    1600,
+	"4g":    9000,
+}
+
+// parseBandwidth converts a bandwidth setting into bytes per second.
+// Accepted forms: a preset name (modem, 2g, 3g, 4g), a plain number of
+// kilobits per second, or a number with a kbps / mbps suffix.
+func parseBandwidth(s string) (int64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+	if kbps, ok := bandwidthPresets[s]; ok {
+		return kbps * 1000 / 8, nil
+	}
+
+	multiplier := int64(1000)
+	switch {
+	case strings.HasSuffix(s, "mbps"):
+		s, multiplier = strings.TrimSuffix(s, "mbps"), 1000*1000
+	case strings.HasSuffix(s, "kbps"):
+		s = strings.TrimSuffix(s, "kbps")
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q", s)
+	}
+	return int64(n * float64(multiplier) / 8), nil
+}
+
+// networkDelay returns the delay to inject before a request: DelayMs plus a
+// uniformly distributed jitter of ±JitterMs, never negative.
+func networkDelay(delayMs, jitterMs int) time.Duration {
+	d := delayMs
+	if jitterMs > 0 {
+		d += rand.Intn(2*jitterMs+1) - jitterMs
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(d) * time.Millisecond
+}
+
+// sleepContext sleeps for d on clock or until ctx is done, reporting whether
+// the full delay elapsed
+func sleepContext(ctx context.Context, clock Clock, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	select {
+	case <-clock.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// throttledConn limits reads and writes on a connection to a fixed byte rate
+// in each direction, simulating a slow client link.
+type throttledConn struct {
+	net.Conn
+	read, write *rateLimiter
+}
+
+func newThrottledConn(conn net.Conn, bytesPerSec int64) net.Conn {
+	return &throttledConn{
+		Conn:  conn,
+		read:  newRateLimiter(bytesPerSec),
+		write: newRateLimiter(bytesPerSec),
+	}
+}
+
+func (c *throttledConn) Read(p []byte) (int, error) {
+	if max := c.read.chunk(); len(p) > max {
+		p = p[:max]
+	}
+	n, err := c.Conn.Read(p)
+	c.read.wait(n)
+	return n, err
+}
+
+func (c *throttledConn) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		end := written + c.write.chunk()
+		if end > len(p) {
+			end = len(p)
+		}
+		n, err := c.Conn.Write(p[written:end])
+		written += n
+		c.write.wait(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// rateLimiter paces a single stream so that the bytes transferred never
+// run ahead of the configured rate.
+type rateLimiter struct {
+	bytesPerSec int64
+	start       time.Time
+	total       int64
+}
+
+func newRateLimiter(bytesPerSec int64) *rateLimiter {
+	return &rateLimiter{bytesPerSec: bytesPerSec, start: time.Now()}
+}
+
+// chunk returns the largest transfer allowed at once (about 1/10s of traffic)
+func (l *rateLimiter) chunk() int {
+	if c := int(l.bytesPerSec / 10); c > 0 {
+		return c
+	}
+	return 1
+}
+
+// wait records n transferred bytes and sleeps until the rate allows them
+func (l *rateLimiter) wait(n int) {
+	if n <= 0 {
+		return
+	}
+	// Time the stream sat idle, e.g. between requests on a kept-alive
+	// connection, mustn't become credit for an unthrottled burst
+	if now := time.Now(); now.After(l.due()) {
+		l.start, l.total = now, 0
+	}
+	l.total += int64(n)
+	if d := time.Until(l.due()); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// due returns when the bytes transferred so far are paid for
+func (l *rateLimiter) due() time.Time {
+	return l.start.Add(time.Duration(float64(l.total) / float64(l.bytesPerSec) * float64(time.Second)))
+}
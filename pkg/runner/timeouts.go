@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// Error classes of timed-out requests, by the phase they timed out in
+const (
+	ErrorClassDialTimeout   = "dial_timeout"
+	ErrorClassTLSTimeout    = "tls_timeout"
+	ErrorClassHeaderTimeout = "header_timeout"
+	ErrorClassTimeout       = "timeout"
+)
+
+// timeoutError classifies a timed-out request by phase, returning the class
+// and a fixed message so they group in ErrorCounts
+func timeoutError(err error) (class, message string, ok bool) {
+	var (
+		netErr net.Error
+		opErr  *net.OpError
+	)
+	msg := err.Error()
+	switch {
+	// The transport's phase timeouts match context.DeadlineExceeded too, so
+	// they are told apart by their messages first
+	case strings.Contains(msg, "TLS handshake timeout"):
+		return ErrorClassTLSTimeout, "TLS handshake timed out", true
+	case strings.Contains(msg, "timeout awaiting response headers"):
+		return ErrorClassHeaderTimeout, "no response headers in time", true
+	// The client's overall timeout replaces the error of whatever phase it
+	// interrupted
+	case strings.Contains(msg, "Client.Timeout exceeded"):
+		return ErrorClassTimeout, "request exceeded the test's timeout", true
+	// A dial's i/o timeout matches context.DeadlineExceeded since Go 1.23,
+	// so it is checked before it
+	case errors.As(err, &opErr) && opErr.Op == "dial" && opErr.Timeout():
+		return ErrorClassDialTimeout, "TCP connect timed out", true
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout, "request exceeded the test's timeout", true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorClassTimeout, "request exceeded the test's timeout", true
+	}
+	return "", "", false
+}
+
+// countTimeout adds a timeout of class to stats
+func countTimeout(stats *api.TimeoutStats, class string) {
+	switch class {
+	case ErrorClassDialTimeout:
+		stats.Dial++
+	case ErrorClassTLSTimeout:
+		stats.TLSHandshake++
+	case ErrorClassHeaderTimeout:
+		stats.ResponseHeader++
+	case ErrorClassTimeout:
+		stats.Total++
+	}
+}
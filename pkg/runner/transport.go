@@ -0,0 +1,227 @@
+package runner
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// newHTTPClient builds the HTTP client shared by all workers of a test,
+// with a transport configured from the test's connection options. Inside a
+// suite the transport comes from the suite and outlives the client; one set
+// with WithTransport replaces both.
+func (r *Runner) newHTTPClient(config api.TestConfiguration) (*http.Client, error) {
+	transport := r.transport
+	if transport == nil {
+		var t *http.Transport
+		var err error
+		if r.suite != nil {
+			t, err = r.suite.transport(config)
+		} else {
+			t, err = newTransport(config)
+		}
+		if err != nil {
+			return nil, err
+		}
+		transport = t
+	}
+
+	if config.IntegratedAuth != nil {
+		var err error
+		if transport, err = newIntegratedAuth(*config.IntegratedAuth, transport); err != nil {
+			return nil, err
+		}
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(config.TimeoutSecs) * time.Second,
+	}, nil
+}
+
+// newTransport builds a transport configured from the test's connection options
+func newTransport(config api.TestConfiguration) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if config.SNIName != "" {
+		transport.TLSClientConfig = &tls.Config{ServerName: config.SNIName}
+	}
+
+	// Connect mode measures connection establishment, so nothing may be reused
+	transport.DisableKeepAlives = config.DisableKeepAlives || config.Mode == api.ModeConnect
+	if config.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+		if transport.MaxIdleConns < config.MaxIdleConnsPerHost {
+			transport.MaxIdleConns = config.MaxIdleConnsPerHost
+		}
+	}
+	if config.IdleConnTimeoutSecs > 0 {
+		transport.IdleConnTimeout = time.Duration(config.IdleConnTimeoutSecs) * time.Second
+	}
+	if config.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = config.MaxConnsPerHost
+	}
+	if t := config.Timeouts; t != nil {
+		if t.TLSHandshakeMs > 0 {
+			transport.TLSHandshakeTimeout = time.Duration(t.TLSHandshakeMs) * time.Millisecond
+		}
+		if t.ResponseHeaderMs > 0 {
+			transport.ResponseHeaderTimeout = time.Duration(t.ResponseHeaderMs) * time.Millisecond
+		}
+	}
+
+	dial, err := newDialFunc(config)
+	if err != nil {
+		return nil, err
+	}
+	transport.DialContext = dial
+	if config.Bandwidth != "" {
+		// HTTP/2 would multiplex every worker over one throttled connection,
+		// turning the per-connection cap into a per-host one
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return transport, nil
+}
+
+// dialFunc matches http.Transport.DialContext
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// newDialFunc returns a dialer honouring the test's IP family and local address settings.
+// When a pool of source addresses is configured, each new connection uses the next one.
+func newDialFunc(config api.TestConfiguration) (dialFunc, error) {
+	addrs := config.LocalAddrPool
+	if config.LocalAddr != "" {
+		addrs = append([]string{config.LocalAddr}, addrs...)
+	}
+
+	ips, err := expandAddrs(addrs)
+	if err != nil {
+		return nil, err
+	}
+	var forced string
+	switch config.IPFamily {
+	case "":
+	case "4":
+		forced = "tcp4"
+	case "6":
+		forced = "tcp6"
+	default:
+		return nil, fmt.Errorf("invalid IP family %q (want 4 or 6)", config.IPFamily)
+	}
+	for _, ip := range ips {
+		// A source address of the other family can't reach the target
+		if isV4 := ip.To4() != nil; forced != "" && isV4 != (forced == "tcp4") {
+			return nil, fmt.Errorf("local address %s is not IPv%s", ip, config.IPFamily)
+		}
+	}
+
+	timeout := defaultDialTimeout
+	if config.Timeouts != nil && config.Timeouts.DialMs > 0 {
+		timeout = time.Duration(config.Timeouts.DialMs) * time.Millisecond
+	}
+	dialers := []*net.Dialer{newDialer(nil, timeout)}
+	if len(ips) > 0 {
+		dialers = dialers[:0]
+		for _, ip := range ips {
+			dialers = append(dialers, newDialer(&net.TCPAddr{IP: ip}, timeout))
+		}
+	}
+
+	bytesPerSec, err := parseBandwidth(config.Bandwidth)
+	if err != nil {
+		return nil, err
+	}
+
+	var next uint64
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if forced != "" {
+			network = forced
+		}
+		dialer := dialers[(atomic.AddUint64(&next, 1)-1)%uint64(len(dialers))]
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil || bytesPerSec == 0 {
+			return conn, err
+		}
+		return newThrottledConn(conn, bytesPerSec), nil
+	}, nil
+}
+
+// defaultDialTimeout is http.DefaultTransport's connect timeout
+const defaultDialTimeout = 30 * time.Second
+
+// newDialer returns a dialer with the same defaults as http.DefaultTransport,
+// but for the connect timeout
+func newDialer(localAddr *net.TCPAddr, timeout time.Duration) *net.Dialer {
+	d := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+	}
+	if localAddr != nil {
+		d.LocalAddr = localAddr
+	}
+	return d
+}
+
+// maxPoolSize caps how many addresses a CIDR entry may expand to
+const maxPoolSize = 65536
+
+// expandAddrs parses source addresses, expanding CIDR entries (e.g. 10.0.0.0/28)
+// into their individual host addresses.
+func expandAddrs(addrs []string) ([]net.IP, error) {
+	var ips []net.IP
+	for _, a := range addrs {
+		if !strings.Contains(a, "/") {
+			ip := net.ParseIP(a)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid local address %q", a)
+			}
+			ips = append(ips, ip)
+			continue
+		}
+
+		ip, ipNet, err := net.ParseCIDR(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid local address range %q: %w", a, err)
+		}
+		// An IPv4 subnet's first and last addresses are its network and
+		// broadcast addresses, except in /31 and /32
+		first := ip.Mask(ipNet.Mask)
+		ones, bits := ipNet.Mask.Size()
+		hostsOnly := bits == 32 && ones < 31
+		if hostsOnly {
+			first = nextIP(first)
+		}
+		for ip := first; ipNet.Contains(ip); ip = nextIP(ip) {
+			if hostsOnly && !ipNet.Contains(nextIP(ip)) {
+				break
+			}
+			if len(ips) >= maxPoolSize {
+				return nil, fmt.Errorf("local address pool exceeds %d addresses", maxPoolSize)
+			}
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+// nextIP returns the address following ip
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
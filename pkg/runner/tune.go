@@ -0,0 +1,144 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/lazarkap/buzzbench.io/pkg/api"
+)
+
+// TuneOptions controls the search for the concurrency that holds a target
+// p95 latency
+type TuneOptions struct {
+	TargetP95      float64 // p95 latency to hold, in ms
+	MaxErrorRate   float64 // highest acceptable error rate, in percent
+	MinConcurrency int     // lowest concurrency to try
+	MaxConcurrency int     // highest concurrency to try
+	StepSeconds    int     // approximate duration of each step
+	Tolerance      float64 // a p95 within this fraction below the target holds it
+	MaxSteps       int     // give up converging after this many steps
+}
+
+// DefaultTuneOptions returns sensible defaults for concurrency tuning
+func DefaultTuneOptions() TuneOptions {
+	return TuneOptions{
+		MaxErrorRate:   1,
+		MinConcurrency: 1,
+		MaxConcurrency: 1000,
+		StepSeconds:    10,
+		Tolerance:      0.1,
+		MaxSteps:       15,
+	}
+}
+
+// Bounds on how far one step moves the concurrency. Latency barely reacts
+// to load until the target saturates, so the ratio of target to measured p95
+// alone would overshoot by far.
+const (
+	maxTuneGrowth = 2.0
+	minTuneShrink = 0.5
+)
+
+// TuneConcurrency looks for the concurrency at which the test's p95 latency
+// sits just under opts.TargetP95, and reports the throughput reached there.
+// Each step runs the test for about StepSeconds at one concurrency, then
+// scales the concurrency by the ratio of target to measured p95, within the
+// bounds of the last passing and first failing steps. It stops once a step's
+// p95 is within Tolerance under the target, or the bounds meet. It returns
+// the result of the passing step with the highest throughput, with
+// TunedConcurrency and TargetP95 set, together with every step run in order.
+func (r *Runner) TuneConcurrency(ctx context.Context, config api.TestConfiguration, opts TuneOptions) (api.TestResult, []api.TestResult, error) {
+	defaults := DefaultTuneOptions()
+	if opts.TargetP95 <= 0 {
+		return api.TestResult{}, nil, fmt.Errorf("target p95 must be positive, got %g ms", opts.TargetP95)
+	}
+	if opts.MinConcurrency <= 0 {
+		opts.MinConcurrency = defaults.MinConcurrency
+	}
+	if opts.MaxConcurrency < opts.MinConcurrency {
+		return api.TestResult{}, nil, fmt.Errorf("invalid concurrency range %d-%d", opts.MinConcurrency, opts.MaxConcurrency)
+	}
+	if opts.StepSeconds <= 0 {
+		opts.StepSeconds = defaults.StepSeconds
+	}
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = defaults.Tolerance
+	}
+	if opts.MaxSteps <= 0 {
+		opts.MaxSteps = defaults.MaxSteps
+	}
+
+	var (
+		steps []api.TestResult
+		best  api.TestResult
+		found bool
+	)
+
+	// The bracket: the highest concurrency that held the target and the
+	// lowest that didn't
+	lo, hi := 0, opts.MaxConcurrency+1
+	conc := min(max(config.Concurrency, opts.MinConcurrency), opts.MaxConcurrency)
+	// Throughput per worker of the last step, to size the next one; before
+	// the first, workers are assumed to wait the whole target per request
+	perWorker := 1000 / opts.TargetP95
+
+	for len(steps) < opts.MaxSteps {
+		step := config
+		step.Concurrency = conc
+		step.RateRPS = 0
+		step.Requests = max(conc*5, int(math.Ceil(perWorker*float64(conc)*float64(opts.StepSeconds))))
+
+		r.logInfo("Tuning step at concurrency %d (%d requests)", conc, step.Requests)
+		result, err := r.RunTest(ctx, step)
+		if err != nil {
+			return api.TestResult{}, steps, err
+		}
+		steps = append(steps, result)
+		if result.RequestsPerSecond > 0 {
+			perWorker = result.RequestsPerSecond / float64(conc)
+		}
+
+		errorRate := 100 - result.SuccessRate
+		ok := errorRate <= opts.MaxErrorRate && result.P95ResponseTime <= opts.TargetP95
+		r.logInfo("  %.1f RPS, p95 %.2f ms, errors %.2f%% -> %s",
+			result.RequestsPerSecond, result.P95ResponseTime, errorRate, passFail(ok))
+		if ok {
+			lo = max(lo, conc)
+			if !found || result.RequestsPerSecond > best.RequestsPerSecond {
+				best, found = result, true
+			}
+			if result.P95ResponseTime >= opts.TargetP95*(1-opts.Tolerance) {
+				break // holding the target
+			}
+		} else {
+			hi = min(hi, conc)
+		}
+		if hi-lo <= 1 || (ok && conc == opts.MaxConcurrency) || (!ok && conc == opts.MinConcurrency) {
+			break
+		}
+
+		// Scale by how far p95 is from the target; too many errors count as
+		// far over it
+		factor := minTuneShrink
+		if errorRate <= opts.MaxErrorRate && result.P95ResponseTime > 0 {
+			factor = min(max(opts.TargetP95/result.P95ResponseTime, minTuneShrink), maxTuneGrowth)
+		}
+		next := int(math.Round(float64(conc) * factor))
+		// Stay strictly inside the bracket, so every step narrows it
+		next = min(max(next, lo+1), hi-1)
+		conc = min(max(next, opts.MinConcurrency), opts.MaxConcurrency)
+	}
+
+	if !found {
+		lowest := opts.MaxConcurrency
+		for _, s := range steps {
+			lowest = min(lowest, s.Concurrency)
+		}
+		return api.TestResult{}, steps, fmt.Errorf("no step held p95 under %.1f ms with errors under %.2f%%, down to concurrency %d",
+			opts.TargetP95, opts.MaxErrorRate, lowest)
+	}
+	best.TunedConcurrency = best.Concurrency
+	best.TargetP95 = opts.TargetP95
+	return best, steps, nil
+}
@@ -0,0 +1,99 @@
+package runner
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// userAgentPresets are current User-Agent strings of common clients
+var userAgentPresets = map[string]string{
+	"chrome":         "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36",
+	"edge":           "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36 Edg/131.0.0.0",
+	"firefox":        "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:133.0) Gecko/20100101 Firefox/133.0",
+	"safari":         "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.1 Safari/605.1.15",
+	"chrome-android": "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Mobile Safari/537.36",
+	"safari-ios":     "Mozilla/5.0 (iPhone; CPU iPhone OS 18_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.1 Mobile/15E148 Safari/604.1",
+	"googlebot":      "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+	"curl":           "curl/8.7.1",
+}
+
+// userAgentMixes are presets standing for several clients, weighted roughly
+// by their share of web traffic
+var userAgentMixes = map[string]map[string]float64{
+	"browsers": {"chrome": 30, "chrome-android": 28, "safari-ios": 18, "safari": 8, "edge": 8, "firefox": 4},
+	"desktop":  {"chrome": 64, "edge": 14, "safari": 12, "firefox": 8},
+	"mobile":   {"chrome-android": 60, "safari-ios": 40},
+}
+
+// userAgents picks the User-Agent of each request of a test with UserAgents
+type userAgents struct {
+	labels     []string // preset name, or the User-Agent itself
+	values     []string
+	cumulative []float64 // running sum of the weights
+}
+
+// newUserAgents resolves a test's user_agents. An entry containing a space
+// or a slash is a User-Agent; anything else names a preset. Every entry
+// gets the same share of requests, split by weight within a mix.
+func newUserAgents(entries []string) (*userAgents, error) {
+	u := &userAgents{}
+	total := 0.0
+	add := func(label, value string, weight float64) {
+		total += weight
+		u.labels = append(u.labels, label)
+		u.values = append(u.values, value)
+		u.cumulative = append(u.cumulative, total)
+	}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+			return nil, fmt.Errorf("empty user agent")
+		case strings.ContainsAny(entry, " /"):
+			add(entry, entry, 1)
+		case userAgentPresets[entry] != "":
+			add(entry, userAgentPresets[entry], 1)
+		case userAgentMixes[entry] != nil:
+			mix := userAgentMixes[entry]
+			sum := 0.0
+			for _, w := range mix {
+				sum += w
+			}
+			names := make([]string, 0, len(mix))
+			for name := range mix {
+				names = append(names, name)
+			}
+			sort.Strings(names) // a fixed order, for the weights' running sum
+			for _, name := range names {
+				add(name, userAgentPresets[name], mix[name]/sum)
+			}
+		default:
+			return nil, fmt.Errorf("unknown user agent preset %q (want one of %s, or a User-Agent string)", entry, strings.Join(UserAgentPresets(), ", "))
+		}
+	}
+	return u, nil
+}
+
+// UserAgentPresets lists the preset names accepted in user_agents
+func UserAgentPresets() []string {
+	var names []string
+	for name := range userAgentPresets {
+		names = append(names, name)
+	}
+	for name := range userAgentMixes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pick returns a random User-Agent, weighted, and its label
+func (u *userAgents) pick() (label, value string) {
+	i := 0
+	if len(u.cumulative) > 1 {
+		i = sort.SearchFloat64s(u.cumulative, rand.Float64()*u.cumulative[len(u.cumulative)-1])
+	}
+	return u.labels[i], u.values[i]
+}